@@ -0,0 +1,103 @@
+// Address families of expanded BSM tokens
+package bsm
+
+import (
+	"fmt"
+	"net"
+)
+
+// AddressFamily denotes the type of an IP address found in expanded
+// tokens. These tokens store the address length (4 or 16) as its type.
+type AddressFamily int
+
+// address families
+const (
+	AddressFamilyUnknown AddressFamily = iota
+	AddressFamilyIPv4
+	AddressFamilyIPv6
+)
+
+func (f AddressFamily) String() string {
+	switch f {
+	case AddressFamilyIPv4:
+		return "IPv4"
+	case AddressFamilyIPv6:
+		return "IPv6"
+	}
+	return "unknown"
+}
+
+// NormalizeIP returns the canonical form of the given IP address: 4
+// bytes for IPv4 addresses (including IPv4-mapped IPv6 addresses) and
+// 16 bytes for IPv6 addresses. Other values are returned unchanged.
+// All parsers return normalized addresses, so an address of length 4 in
+// an expanded token always results in a 4 byte IP.
+func NormalizeIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+// ipAddress returns a normalized copy of the given address bytes (which
+// must not be aliased by parsed tokens).
+func ipAddress(input []byte) net.IP {
+	return NormalizeIP(append(net.IP(nil), input...))
+}
+
+// checkAddress makes sure the raw address field of an expanded token
+// holds an address of the declared type (see StrictAddresses): a 16 byte
+// field must not hold an IPv4-mapped address. The all-zero address is
+// valid for both types.
+func checkAddress(field []byte) error {
+	if len(field) == net.IPv6len && net.IP(field).To4() != nil {
+		return fmt.Errorf("%w: IPv4 address %s stored as 16 byte address", ErrAddressMismatch, net.IP(field).To4())
+	}
+	return nil
+}
+
+// addressFamily maps an address type/length field to an AddressFamily.
+func addressFamily(length uint32) AddressFamily {
+	switch length {
+	case 4:
+		return AddressFamilyIPv4
+	case 16:
+		return AddressFamilyIPv6
+	}
+	return AddressFamilyUnknown
+}
+
+// AddressFamily returns the type of the machine address.
+func (t ExpandedHeaderToken32bit) AddressFamily() AddressFamily {
+	return addressFamily(t.AddressType)
+}
+
+// AddressFamily returns the type of the machine address.
+func (t ExpandedHeaderToken64bit) AddressFamily() AddressFamily {
+	return addressFamily(t.AddressType)
+}
+
+// AddressFamily returns the type of the IP address.
+func (t ExpandedInAddrToken) AddressFamily() AddressFamily {
+	return addressFamily(uint32(t.IpAddressType))
+}
+
+// AddressFamily returns the type of the terminal machine address.
+func (t ExpandedSubjectToken32bit) AddressFamily() AddressFamily {
+	return addressFamily(t.TerminalAddressLength)
+}
+
+// AddressFamily returns the type of the terminal machine address.
+func (t ExpandedSubjectToken64bit) AddressFamily() AddressFamily {
+	return addressFamily(t.TerminalAddressLength)
+}
+
+// AddressFamily returns the type of the terminal machine address.
+func (t ExpandedProcessToken32bit) AddressFamily() AddressFamily {
+	return addressFamily(t.TerminalAddressLength)
+}
+
+// AddressFamily returns the type of the terminal machine address.
+func (t ExpandedProcessToken64bit) AddressFamily() AddressFamily {
+	return addressFamily(t.TerminalAddressLength)
+}
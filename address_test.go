@@ -0,0 +1,107 @@
+// test address families of expanded BSM tokens
+package bsm
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestAddressFamily(t *testing.T) {
+	testData := map[uint32]AddressFamily{
+		4:  AddressFamilyIPv4,
+		16: AddressFamilyIPv6,
+		6:  AddressFamilyUnknown,
+		0:  AddressFamilyUnknown,
+	}
+	for length, expected := range testData {
+		header := ExpandedHeaderToken32bit{AddressType: length}
+		if header.AddressFamily() != expected {
+			t.Errorf("address type %d: expected %s, got %s", length, expected, header.AddressFamily())
+		}
+		subject := ExpandedSubjectToken64bit{TerminalAddressLength: length}
+		if subject.AddressFamily() != expected {
+			t.Errorf("address length %d: expected %s, got %s", length, expected, subject.AddressFamily())
+		}
+		inAddr := ExpandedInAddrToken{IpAddressType: byte(length)}
+		if inAddr.AddressFamily() != expected {
+			t.Errorf("address type %d: expected %s, got %s", length, expected, inAddr.AddressFamily())
+		}
+	}
+}
+
+func TestNormalizeIP(t *testing.T) {
+	mapped := net.ParseIP("::ffff:192.168.1.10") // 16 bytes
+	if ip := NormalizeIP(mapped); len(ip) != 4 || ip.String() != "192.168.1.10" {
+		t.Errorf("unexpected normalized address: %v (%d bytes)", ip, len(ip))
+	}
+	if ip := NormalizeIP(net.ParseIP("2001:db8::1")); len(ip) != 16 {
+		t.Errorf("unexpected normalized IPv6 address: %v (%d bytes)", ip, len(ip))
+	}
+	if ip := NormalizeIP(net.IP{10, 0, 0, 1}); len(ip) != 4 {
+		t.Errorf("unexpected normalized IPv4 address: %v (%d bytes)", ip, len(ip))
+	}
+	if ip := NormalizeIP(nil); ip != nil {
+		t.Error("expected nil, got", ip)
+	}
+
+	// parsed addresses are normalized
+	records, err := ParseAll(bytes.NewBuffer(rootLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject := records[1].Tokens[0].(ExpandedSubjectToken32bit)
+	if subject.TerminalAddressLength != 4 || len(subject.TerminalMachineAddress) != 4 {
+		t.Errorf("expected a 4 byte address, got %d bytes", len(subject.TerminalMachineAddress))
+	}
+}
+
+func TestStrictAddresses(t *testing.T) {
+	defer func(strict bool) { StrictAddresses = strict }(StrictAddresses)
+	StrictAddresses = true
+
+	// correct expanded subject token with an IPv6 address
+	data, err := ExpandedSubjectToken32bit{TerminalMachineAddress: net.ParseIP("2001:db8::1")}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = ParseToken(data); err != nil {
+		t.Error(err)
+	}
+
+	// IPv4 address stored in the 16 byte field of an IPv6 address
+	copy(data[37:53], net.ParseIP("::ffff:10.0.0.1"))
+	_, _, err = ParseToken(data)
+	if !errors.Is(err, ErrAddressMismatch) {
+		t.Error("expected address mismatch, got", err)
+	}
+
+	// the check is disabled by default
+	StrictAddresses = false
+	if _, _, err = ParseToken(data); err != nil {
+		t.Error(err)
+	}
+
+	// all-zero addresses are valid
+	StrictAddresses = true
+	data, _ = SubjectToken32bit{}.Marshal()
+	if _, _, err = ParseToken(data); err != nil {
+		t.Error(err)
+	}
+	data, _ = ExpandedSubjectToken32bit{TerminalMachineAddress: net.IPv6zero}.Marshal()
+	if _, _, err = ParseToken(data); err != nil {
+		t.Error(err)
+	}
+
+	// expanded process tokens are checked as well
+	data, _ = ExpandedProcessToken32bit{TerminalMachineAddress: net.ParseIP("2001:db8::1")}.Marshal()
+	copy(data[37:53], net.ParseIP("::ffff:10.0.0.1"))
+	if _, _, err = ParseToken(data); !errors.Is(err, ErrAddressMismatch) {
+		t.Error("expected address mismatch, got", err)
+	}
+	StrictAddresses = false
+	if _, _, err = ParseToken(data); err != nil {
+		t.Error(err)
+	}
+}
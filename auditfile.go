@@ -0,0 +1,52 @@
+// Access to (compressed) audit files
+package bsm
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// auditFile reads from a (possibly decompressed) audit file.
+type auditFile struct {
+	io.Reader
+	file       *os.File
+	decompress *gzip.Reader // nil for uncompressed files
+}
+
+// Close closes the decompressor (if any) and the underlying file.
+func (a *auditFile) Close() error {
+	var err error
+	if a.decompress != nil {
+		err = a.decompress.Close()
+	}
+	if cerr := a.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// OpenAuditFile opens the audit file with the given path. Gzipped files
+// (ending in .gz or starting with the gzip magic bytes) are decompressed
+// transparently. The result can be fed to RecordGenerator and has to be
+// closed by the caller.
+func OpenAuditFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	buffered := bufio.NewReader(file)
+	magic, _ := buffered.Peek(2) // short files can't be gzipped
+	if !strings.HasSuffix(path, ".gz") && !(len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b) {
+		return &auditFile{Reader: buffered, file: file}, nil
+	}
+
+	decompress, err := gzip.NewReader(buffered)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &auditFile{Reader: decompress, file: file, decompress: decompress}, nil
+}
@@ -0,0 +1,75 @@
+// test access to (compressed) audit files
+package bsm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// countRecords counts the records in the given audit file.
+func countRecords(t *testing.T, path string) int {
+	file, err := OpenAuditFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	count := 0
+	for result := range RecordGenerator(file) {
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+		count += 1
+	}
+	return count
+}
+
+func TestOpenAuditFile(t *testing.T) {
+	if n := countRecords(t, "start_stop.bsm"); n != 2 {
+		t.Error("expected 2 records in plain file, got", n)
+	}
+	if n := countRecords(t, "testdata/start_stop.bsm.gz"); n != 2 {
+		t.Error("expected 2 records in gzipped file, got", n)
+	}
+
+	// gzipped file detected by magic bytes
+	dir, err := ioutil.TempDir("", "go-bsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data, err := ioutil.ReadFile("testdata/start_stop.bsm.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "start_stop.bsm")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRecords(t, path); n != 2 {
+		t.Error("expected 2 records in gzipped file without suffix, got", n)
+	}
+
+	if _, err := OpenAuditFile(filepath.Join(dir, "missing.bsm")); err == nil {
+		t.Error("expected an error on missing file")
+	}
+	if _, err := OpenAuditFile("start_stop.bsm.gz"); err == nil {
+		t.Error("expected an error on missing gzipped file")
+	}
+}
+
+func TestOpenAuditFile_invalid_gzip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-bsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "broken.bsm.gz")
+	if err := ioutil.WriteFile(path, []byte("no gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenAuditFile(path); err == nil {
+		t.Error("expected an error on invalid gzip data")
+	}
+}
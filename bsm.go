@@ -3,37 +3,95 @@ package bsm
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net"
-	"strconv"
 )
 
-type empty interface{} // generic type for generator
+// Token is the common interface of all BSM tokens. The concrete
+// token types are kept, so type switches on them still work.
+type Token interface {
+	Type() byte // token ID
+}
+
+// Errors returned (wrapped) by the parser, to be checked with errors.Is.
+var (
+	ErrUnknownTokenID  = errors.New("unknown token ID")             // token type is not supported
+	ErrShortToken      = errors.New("token too short")              // not enough bytes to parse the token
+	ErrBadTrailerMagic = errors.New("invalid trailer magic number") // trailer magic is not 0xb105
+	ErrBadVersion      = errors.New("unsupported record version")   // version not in SupportedVersions
+	ErrTokenTooLarge   = errors.New("token too large")              // token exceeds MaxTokenSize
+	ErrAddressMismatch = errors.New("address mismatch")             // address does not match its length field (StrictAddresses)
+	ErrTrailerWarning  = errors.New("trailer warning")              // record accepted despite a bad or missing trailer (StrictTrailer)
+	ErrSocketFamily    = errors.New("unsupported socket family")    // IPv6 family in a socket token holding IPv4 addresses only
+)
+
+// MaxTokenSize limits the size (in bytes) of a single token. This protects
+// against huge allocations caused by corrupt or hostile length fields.
+var MaxTokenSize = 4 * 1024 * 1024
+
+// SupportedVersions holds the record versions (as found in header tokens)
+// accepted by ReadBsmRecord. By default these are the OpenBSM versions
+// (10 and 11). Set it to nil to accept all versions.
+var SupportedVersions = map[byte]bool{
+	10: true, // AUDIT_HEADER_VERSION_OPENBSM10
+	11: true, // AUDIT_HEADER_VERSION_OPENBSM11
+}
+
+// StrictAddresses enables the validation of terminal machine addresses
+// of expanded subject and process tokens: the address has to be of the
+// type given by the address length field, e.g. an IPv4-mapped address in
+// a 16 byte field indicates corruption. Non-expanded tokens always hold 4
+// byte addresses, so there is nothing to check. Disabled by default.
+var StrictAddresses = false
+
+// StrictTrailer makes ReadBsmRecord reject records with a bad trailer
+// magic or without trailer (default). If disabled, such records (e.g.
+// of partial captures) are returned together with an error wrapping
+// ErrTrailerWarning, which callers can ignore: a bad trailer magic is
+// accepted and a missing trailer ends the record at the next header
+// token or the end of input. Ending a record at the next header token
+// requires ReadBsmRecord to push the header back, which works for
+// inputs implementing io.Seeker (RecordGenerator handles all inputs).
+var StrictTrailer = true
+
+// TokenLayout denotes one of the conflicting descriptions of a token.
+type TokenLayout int
+
+// token layouts
+const (
+	LibbsmLayout  TokenLayout = iota // as written and read by libbsm (FreeBSD, Darwin)
+	ManpageLayout                    // as documented in audit.log(5)
+)
+
+// ExpandedInAddrLayout selects the layout of expanded in_addr tokens
+// (see ExpandedInAddrToken). It defaults to LibbsmLayout.
+var ExpandedInAddrLayout = LibbsmLayout
 
 // ArgToken32bit (or 'arg' token) contains information
 // about arguments of the system call.
 // These arguments are encoded in 32 bit
 type ArgToken32bit struct {
-	TokenID       byte   // Token ID (1 byte): 0x2d
-	ArgumentID    uint8  // argument ID/number (1 byte)
-	ArgumentValue uint32 // argument value (4 bytes)
-	Length        uint16 // length of the text (2 bytes)
-	Text          string // the string including nul (Length + 1 NUL bytes)
+	TokenID       byte   `json:"token_id"`       // Token ID (1 byte): 0x2d
+	ArgumentID    uint8  `json:"argument_id"`    // argument ID/number (1 byte)
+	ArgumentValue uint32 `json:"argument_value"` // argument value (4 bytes)
+	Length        uint16 `json:"length"`         // length of the text (2 bytes)
+	Text          string `json:"text"`           // the string including nul (Length + 1 NUL bytes)
 }
 
 // ArgToken64bit (or 'arg' token) contains information
 // about arguments of the system call.
 // These arguments are encoded in 32 bit
 type ArgToken64bit struct {
-	TokenID       byte   // Token ID (1 byte): 0x71
-	ArgumentID    uint8  // argument ID/number (1 byte)
-	ArgumentValue uint64 // argument value (8 bytes)
-	Length        uint16 // length of the text (2 bytes)
-	Text          string // the string including nul (Length + 1 NUL bytes)
+	TokenID       byte   `json:"token_id"`       // Token ID (1 byte): 0x71
+	ArgumentID    uint8  `json:"argument_id"`    // argument ID/number (1 byte)
+	ArgumentValue uint64 `json:"argument_value"` // argument value (8 bytes)
+	Length        uint16 `json:"length"`         // length of the text (2 bytes)
+	Text          string `json:"text"`           // the string including nul (Length + 1 NUL bytes)
 }
 
 // ArbitraryDataToken (or 'arbitrary data' token) contains a byte stream
@@ -42,11 +100,11 @@ type ArgToken64bit struct {
 // 'How to print' field is present to specify how to print the data, but
 // interpretation of that field is not currently defined.
 type ArbitraryDataToken struct {
-	TokenID    byte     // token ID (1 byte): 0x21
-	HowToPrint byte     // user-defined printing information (1 byte)
-	BasicUnit  uint8    // size of a unit in bytes (1 byte)
-	UnitCount  uint8    // number if units of data present (1 byte)
-	DataItems  [][]byte // user data
+	TokenID    byte     `json:"token_id"`     // token ID (1 byte): 0x21
+	HowToPrint byte     `json:"how_to_print"` // user-defined printing information (1 byte)
+	BasicUnit  uint8    `json:"basic_unit"`   // size of a unit in bytes (1 byte)
+	UnitCount  uint8    `json:"unit_count"`   // number if units of data present (1 byte)
+	DataItems  [][]byte `json:"data_items"`   // user data
 }
 
 // AttributeToken32bit (or 'attribute' token) describes the attributes of a file
@@ -56,13 +114,13 @@ type ArbitraryDataToken struct {
 // any, was used to reach the object. The device number is stored using 32 bit.
 // TODO: check if token ID may be 0x31
 type AttributeToken32bit struct {
-	TokenID          byte   // Token ID (1 byte): 0x3e
-	FileAccessMode   uint32 // mode_t associated with file (4 bytes)
-	OwnerUserID      uint32 // uid_t associated with file (4 bytes)
-	OwnerGroupID     uint32 // gid_t associated with file (4 bytes)
-	FileSystemID     uint32 // fsid_t associated with file (4 bytes)
-	FileSystemNodeID uint64 // ino_t associated with file (8 bytes)
-	Device           uint32 // Device major/minor number (4 bytes)
+	TokenID          byte   `json:"token_id"`            // Token ID (1 byte): 0x3e
+	FileAccessMode   uint32 `json:"file_access_mode"`    // mode_t associated with file (4 bytes)
+	OwnerUserID      uint32 `json:"owner_user_id"`       // uid_t associated with file (4 bytes)
+	OwnerGroupID     uint32 `json:"owner_group_id"`      // gid_t associated with file (4 bytes)
+	FileSystemID     uint32 `json:"file_system_id"`      // fsid_t associated with file (4 bytes)
+	FileSystemNodeID uint64 `json:"file_system_node_id"` // ino_t associated with file (8 bytes)
+	Device           uint32 `json:"device"`              // Device major/minor number (4 bytes)
 }
 
 // AttributeToken64bit (or 'attribute' token) describes the attributes of a file
@@ -71,37 +129,37 @@ type AttributeToken32bit struct {
 // 'path' tokens may also be present in an audit record indicating which path, if
 // any, was used to reach the object. The device number is stored using 64 bit.
 type AttributeToken64bit struct {
-	TokenID          byte   // Token ID (1 byte): 0x73
-	FileAccessMode   uint32 // mode_t associated with file (4 bytes)
-	OwnerUserID      uint32 // uid_t associated with file (4 bytes)
-	OwnerGroupID     uint32 // gid_t associated with file (4 bytes)
-	FileSystemID     uint32 // fsid_t associated with file (4 bytes)
-	FileSystemNodeID uint64 // ino_t associated with file (8 bytes)
-	Device           uint64 // Device major/minor number (8 bytes)
+	TokenID          byte   `json:"token_id"`            // Token ID (1 byte): 0x73
+	FileAccessMode   uint32 `json:"file_access_mode"`    // mode_t associated with file (4 bytes)
+	OwnerUserID      uint32 `json:"owner_user_id"`       // uid_t associated with file (4 bytes)
+	OwnerGroupID     uint32 `json:"owner_group_id"`      // gid_t associated with file (4 bytes)
+	FileSystemID     uint32 `json:"file_system_id"`      // fsid_t associated with file (4 bytes)
+	FileSystemNodeID uint64 `json:"file_system_node_id"` // ino_t associated with file (8 bytes)
+	Device           uint64 `json:"device"`              // Device major/minor number (8 bytes)
 }
 
 // ExecArgsToken (or 'exec_args' token) contains information about
 // arguments of the exec() system call.
 type ExecArgsToken struct {
-	TokenID byte     // Token ID (1 byte): 0x3c
-	Count   uint32   // number of arguments (4 bytes)
-	Text    []string // Count NUL-terminated strings
+	TokenID byte     `json:"token_id"` // Token ID (1 byte): 0x3c
+	Count   uint32   `json:"count"`    // number of arguments (4 bytes)
+	Text    []string `json:"text"`     // Count NUL-terminated strings
 }
 
 // ExecEnvToken (or 'exec_env' token) contains current environment
 // variables to an exec() system call.
 type ExecEnvToken struct {
-	TokenID byte     // Token ID (1 byte): 0x3d
-	Count   uint32   // number of variables (4 bytes)
-	Text    []string // Count NUL-terminated strings
+	TokenID byte     `json:"token_id"` // Token ID (1 byte): 0x3d
+	Count   uint32   `json:"count"`    // number of variables (4 bytes)
+	Text    []string `json:"text"`     // Count NUL-terminated strings
 }
 
 // ExitToken (or 'exit' token) contains process
 // exit/return code information.
 type ExitToken struct {
-	TokenID     byte   // Token ID (1 byte): 0x52
-	Status      uint32 // Process status on exit (4 bytes)
-	ReturnValue int32  // Process return value on exit (4 bytes)
+	TokenID     byte   `json:"token_id"`     // Token ID (1 byte): 0x52
+	Status      uint32 `json:"status"`       // Process status on exit (4 bytes)
+	ReturnValue int32  `json:"return_value"` // Process return value on exit (4 bytes)
 }
 
 // FileToken (or 'file' token) is used at the beginning and end of an audit
@@ -110,19 +168,19 @@ type ExitToken struct {
 // still observable, and gaps in the audit log can be identified.
 // BUG: unable to determine token ID (0x11 vs. 0x78 vs . ?)
 type FileToken struct {
-	TokenID        byte   // Token ID (1 byte):
-	Seconds        uint32 // file timestamp (4 bytes)
-	Microseconds   uint32 // file timestamp (4 bytes)
-	FileNameLength uint16 // file name of audit trail (2 bytes)
-	PathName       string // file name of audit trail (FileNameLength + 1 (NULL))
+	TokenID        byte   `json:"token_id"`         // Token ID (1 byte): 0x11
+	Seconds        uint32 `json:"seconds"`          // file timestamp, seconds (4 bytes)
+	Microseconds   uint32 `json:"microseconds"`     // file timestamp, microseconds (not nanoseconds as in headers, 4 bytes)
+	FileNameLength uint16 `json:"file_name_length"` // file name of audit trail (2 bytes)
+	PathName       string `json:"path_name"`        // file name of audit trail (FileNameLength + 1 (NULL))
 }
 
 // GroupsToken (or 'groups' token) contains a list of group IDs associated
 // with the audit event.
 type GroupsToken struct {
-	TokenID        byte     // Token ID (1 byte): 0x34
-	NumberOfGroups uint16   // Number of groups in token (2 bytes)
-	GroupList      []uint32 // List of N group IDs (N*4 bytes)
+	TokenID        byte     `json:"token_id"`         // Token ID (1 byte): 0x34
+	NumberOfGroups uint16   `json:"number_of_groups"` // Number of groups in token (2 bytes)
+	GroupList      []uint32 `json:"group_list"`       // List of N group IDs (N*4 bytes)
 }
 
 // HeaderToken32bit (or 'header' token is used to mark the beginning of a
@@ -131,13 +189,13 @@ type GroupsToken struct {
 // time at which the event occurred. This type uses 32 bits to encode time
 // information.
 type HeaderToken32bit struct {
-	TokenID         byte   // Token ID (1 byte): 0x14
-	RecordByteCount uint32 // number of bytes in record (4 bytes)
-	VersionNumber   byte   // BSM record version number (1 byte)
-	EventType       uint16 // event type (2 bytes)
-	EventModifier   uint16 // event sub-type (2 bytes)
-	Seconds         uint32 // record time stamp (4 bytes)
-	NanoSeconds     uint32 // record time stamp (4 bytes)
+	TokenID         byte   `json:"token_id"`          // Token ID (1 byte): 0x14
+	RecordByteCount uint32 `json:"record_byte_count"` // number of bytes in record (4 bytes)
+	VersionNumber   byte   `json:"version_number"`    // BSM record version number (1 byte)
+	EventType       uint16 `json:"event_type"`        // event type (2 bytes)
+	EventModifier   uint16 `json:"event_modifier"`    // event sub-type (2 bytes)
+	Seconds         uint32 `json:"seconds"`           // record time stamp (4 bytes)
+	NanoSeconds     uint32 `json:"nano_seconds"`      // record time stamp (4 bytes)
 }
 
 // HeaderToken64bit (or 'header' token) is used to mark the beginning of a
@@ -146,97 +204,102 @@ type HeaderToken32bit struct {
 // and the time at which the event occurred. This type uses 64 bits to
 // encode time information.
 type HeaderToken64bit struct {
-	TokenID         byte   // Token ID (1 byte): 0x74
-	RecordByteCount uint32 // number of bytes in record (4 bytes)
-	VersionNumber   byte   // BSM record version number (1 byte)
-	EventType       uint16 // event type (2 bytes)
-	EventModifier   uint16 // event sub-type (2 bytes)
-	Seconds         uint64 // record time stamp (8 bytes)
-	NanoSeconds     uint64 // record time stamp (8 bytes)
+	TokenID         byte   `json:"token_id"`          // Token ID (1 byte): 0x74
+	RecordByteCount uint32 `json:"record_byte_count"` // number of bytes in record (4 bytes)
+	VersionNumber   byte   `json:"version_number"`    // BSM record version number (1 byte)
+	EventType       uint16 `json:"event_type"`        // event type (2 bytes)
+	EventModifier   uint16 `json:"event_modifier"`    // event sub-type (2 bytes)
+	Seconds         uint64 `json:"seconds"`           // record time stamp (8 bytes)
+	NanoSeconds     uint64 `json:"nano_seconds"`      // record time stamp (8 bytes)
 }
 
 // ExpandedHeaderToken32bit (or 'expanded header' token) is an expanded
 // version of the 'header' token, with the addition of a machine IPv4 or
 // IPv6 address. This type uses 32 bits to encode time information.
 type ExpandedHeaderToken32bit struct {
-	TokenID         byte   // Token ID (1 byte): 0x15
-	RecordByteCount uint32 // number of bytes in record (4 bytes)
-	VersionNumber   byte   // BSM record version number (2 bytes)
-	EventType       uint16 // event type (2 bytes)
-	EventModifier   uint16 // event sub-type (2 bytes)
-	AddressType     uint32 // host address type and length (1 byte in manpage / 4 bytes in Solaris 10)
-	MachineAddress  net.IP // IPv4/6 address (4/16 bytes)
-	Seconds         uint32 // record time stamp (4 bytes)
-	NanoSeconds     uint32 // record time stamp (4 bytes)
+	TokenID         byte   `json:"token_id"`          // Token ID (1 byte): 0x15
+	RecordByteCount uint32 `json:"record_byte_count"` // number of bytes in record (4 bytes)
+	VersionNumber   byte   `json:"version_number"`    // BSM record version number (1 byte)
+	EventType       uint16 `json:"event_type"`        // event type (2 bytes)
+	EventModifier   uint16 `json:"event_modifier"`    // event sub-type (2 bytes)
+	AddressType     uint32 `json:"address_type"`      // host address type and length (1 byte in manpage / 4 bytes in Solaris 10)
+	MachineAddress  net.IP `json:"machine_address"`   // IPv4/6 address (4/16 bytes)
+	Seconds         uint32 `json:"seconds"`           // record time stamp (4 bytes)
+	NanoSeconds     uint32 `json:"nano_seconds"`      // record time stamp (4 bytes)
 }
 
 // ExpandedHeaderToken64bit (or 'expanded header' token) is an expanded
 // version of the 'header' token, with the addition of a machine IPv4 or
 // IPv6 address. This type uses 64 bits to encode time information.
 type ExpandedHeaderToken64bit struct {
-	TokenID         byte   // Token ID (1 byte): 0x79
-	RecordByteCount uint32 // number of bytes in record (4 bytes)
-	VersionNumber   byte   // BSM record version number (2 bytes)
-	EventType       uint16 // event type (2 bytes)
-	EventModifier   uint16 // event sub-type (2 bytes)
-	AddressType     uint32 // host address type and length (1 byte in manpage / 4 bytes in Solaris 10)
-	MachineAddress  net.IP // IPv4/6 address (4/16 bytes)
-	Seconds         uint64 // record time stamp (8 bytes)
-	NanoSeconds     uint64 // record time stamp (8 bytes)
+	TokenID         byte   `json:"token_id"`          // Token ID (1 byte): 0x79
+	RecordByteCount uint32 `json:"record_byte_count"` // number of bytes in record (4 bytes)
+	VersionNumber   byte   `json:"version_number"`    // BSM record version number (1 byte)
+	EventType       uint16 `json:"event_type"`        // event type (2 bytes)
+	EventModifier   uint16 `json:"event_modifier"`    // event sub-type (2 bytes)
+	AddressType     uint32 `json:"address_type"`      // host address type and length (1 byte in manpage / 4 bytes in Solaris 10)
+	MachineAddress  net.IP `json:"machine_address"`   // IPv4/6 address (4/16 bytes)
+	Seconds         uint64 `json:"seconds"`           // record time stamp (8 bytes)
+	NanoSeconds     uint64 `json:"nano_seconds"`      // record time stamp (8 bytes)
 }
 
 // InAddrToken (or 'in_addr' token) holds a (network byte order) IPv4 address.
-// BUGS: token layout documented in audit.log(5) appears to be in conflict with the libbsm(3) implementation of au_to_in_addr_ex(3).
 type InAddrToken struct {
-	TokenID   byte   // Token ID (1 byte): 0x2a
-	IpAddress net.IP // IPv4 address (4 bytes)
+	TokenID   byte   `json:"token_id"`   // Token ID (1 byte): 0x2a
+	IpAddress net.IP `json:"ip_address"` // IPv4 address (4 bytes)
 }
 
 // ExpandedInAddrToken (or 'expanded in_addr' token) holds a
-// (network byte order) IPv4 or IPv6 address.
-// TODO: determine value indicating address type
-// BUGS: token layout documented in audit.log(5) appears to be in conflict with the libbsm(3) implementation of au_to_in_addr_ex(3).
+// (network byte order) IPv4 or IPv6 address. The layout documented in
+// audit.log(5) conflicts with the one of libbsm(3):
+// * audit.log(5): token ID (1 byte), address type (1 byte), address (16 bytes)
+// * libbsm: token ID (1 byte), address type (4 bytes), address (4 or 16 bytes)
+// The address type is the length of the address (4 for IPv4, 16 for IPv6)
+// in both cases. libbsm's au_to_in_addr_ex(3) always writes IPv6 addresses,
+// its parser accepts both lengths. Use ExpandedInAddrLayout to choose.
 type ExpandedInAddrToken struct {
-	TokenID       byte   // Token ID (1 byte): 0x7e
-	IpAddressType byte   // type of IP address (libbsm also calls this 'length')
-	IpAddress     net.IP // IP address (4/16 bytes)
+	TokenID       byte   `json:"token_id"`        // Token ID (1 byte): 0x7e
+	IpAddressType byte   `json:"ip_address_type"` // type/length of IP address (1 byte in manpage / 4 bytes in libbsm)
+	IpAddress     net.IP `json:"ip_address"`      // IP address (4/16 bytes)
 }
 
 // IpToken (or 'ip' token) contains an IP(v4) packet header in network
 // byte order.
 type IpToken struct {
-	TokenID            byte   // Token ID (1 byte): 0x2b
-	VersionAndIHL      uint8  // Version and IP header length (1 byte)
-	TypeOfService      byte   // IP TOS field (1 byte)
-	Length             uint16 // IP packet length in network byte order (2 bytes)
-	ID                 uint16 // IP header ID for reassembly (2 bytes)
-	Offset             uint16 // IP fragment offset and flags, network byte order (2 bytes)
-	TTL                uint8  // IP Time-to-Live (1 byte)
-	Protocol           uint8  // IP protocol number (1 byte)
-	Checksum           uint16 // IP header checksum, network byte order (2 bytes)
-	SourceAddress      net.IP // IPv4 source address (4 bytes)
-	DestinationAddress net.IP // IPv4 destination addess (4 bytes)
+	TokenID            byte   `json:"token_id"`            // Token ID (1 byte): 0x2b
+	VersionAndIHL      uint8  `json:"version_and_ihl"`     // Version and IP header length (1 byte)
+	TypeOfService      byte   `json:"type_of_service"`     // IP TOS field (1 byte)
+	Length             uint16 `json:"length"`              // IP packet length in network byte order (2 bytes)
+	ID                 uint16 `json:"id"`                  // IP header ID for reassembly (2 bytes)
+	Offset             uint16 `json:"offset"`              // IP fragment offset and flags, network byte order (2 bytes)
+	TTL                uint8  `json:"ttl"`                 // IP Time-to-Live (1 byte)
+	Protocol           uint8  `json:"protocol"`            // IP protocol number (1 byte)
+	Checksum           uint16 `json:"checksum"`            // IP header checksum, network byte order (2 bytes)
+	SourceAddress      net.IP `json:"source_address"`      // IPv4 source address (4 bytes)
+	DestinationAddress net.IP `json:"destination_address"` // IPv4 destination addess (4 bytes)
 }
 
 // IPortToken (or 'iport' token) stores an IP port number in network byte order.
 type IPortToken struct {
-	TokenID    byte   // Token ID (1 byte): 0x2c
-	PortNumber uint16 // Port number in network byte order (2 bytes)
+	TokenID    byte   `json:"token_id"`    // Token ID (1 byte): 0x2c
+	PortNumber uint16 `json:"port_number"` // Port number in network byte order (2 bytes)
 }
 
-// PathToken (or 'path' token) contains a pathname.
+// PathToken (or 'path' token) contains a NUL-terminated pathname. Just like
+// in the text token, the length field counts the terminating NUL and the
+// decoded path does not include it.
 type PathToken struct {
-	TokenID    byte   // Token ID (1 byte): 0x23
-	PathLength uint16 // Length of path in bytes (2 bytes)
-	Path       string // Path name (PathLength bytes + 1 NUL)
+	TokenID    byte   `json:"token_id"`    // Token ID (1 byte): 0x23
+	PathLength uint16 `json:"path_length"` // length of path including NUL (2 bytes)
+	Path       string `json:"path"`        // Path name without NUL (PathLength - 1 bytes)
 }
 
 // PathAttrToken (or 'path_attr' token) contains a set of NUL-terminated path names.
 // TODO: verify Token ID
 type PathAttrToken struct {
-	TokenID byte     // Token ID (1 byte): 0x25 ?
-	Count   uint16   // Number of NUL-terminated string(s) in token (2 bytes)
-	Path    []string // count NUL-terminated string(s)
+	TokenID byte     `json:"token_id"` // Token ID (1 byte): 0x25 ?
+	Count   uint16   `json:"count"`    // Number of NUL-terminated string(s) in token (2 bytes)
+	Path    []string `json:"path"`     // count NUL-terminated string(s)
 }
 
 // ProcessToken32bit (or 'process' token) contains a description of the security
@@ -247,16 +310,16 @@ type PathAttrToken struct {
 // as user IDs and group IDs, but also audit information such as the audit
 // user ID and session. The terminal port ID is encoded using 32 bit.
 type ProcessToken32bit struct {
-	TokenID                byte   // Token ID (1 byte): 0x26
-	AuditID                uint32 // audit user ID (4 bytes)
-	EffectiveUserID        uint32 // effective user ID (4 bytes)
-	EffectiveGroupID       uint32 // effective group ID (4 bytes)
-	RealUserID             uint32 // real user ID (4 bytes)
-	RealGroupID            uint32 // real group ID (4 bytes)
-	ProcessID              uint32 // process ID (4 bytes)
-	SessionID              uint32 // session ID (4 bytes)
-	TerminalPortID         uint32 // terminal port ID (4 byte)
-	TerminalMachineAddress net.IP // IP(v4) address of machine (4 bytes)
+	TokenID                byte   `json:"token_id"`                 // Token ID (1 byte): 0x26
+	AuditID                uint32 `json:"audit_id"`                 // audit user ID (4 bytes)
+	EffectiveUserID        uint32 `json:"effective_user_id"`        // effective user ID (4 bytes)
+	EffectiveGroupID       uint32 `json:"effective_group_id"`       // effective group ID (4 bytes)
+	RealUserID             uint32 `json:"real_user_id"`             // real user ID (4 bytes)
+	RealGroupID            uint32 `json:"real_group_id"`            // real group ID (4 bytes)
+	ProcessID              uint32 `json:"process_id"`               // process ID (4 bytes)
+	SessionID              uint32 `json:"session_id"`               // session ID (4 bytes)
+	TerminalPortID         uint32 `json:"terminal_port_id"`         // terminal port ID (4 byte)
+	TerminalMachineAddress net.IP `json:"terminal_machine_address"` // IP(v4) address of machine (4 bytes)
 }
 
 // ProcessToken64bit (or 'process' token) contains a description of the security
@@ -267,16 +330,16 @@ type ProcessToken32bit struct {
 // as user IDs and group IDs, but also audit information such as the audit
 // user ID and session. The terminal port ID is encoded using 64 bit.
 type ProcessToken64bit struct {
-	TokenID                byte   // Token ID (1 byte): 0x77
-	AuditID                uint32 // audit user ID (4 bytes)
-	EffectiveUserID        uint32 // effective user ID (4 bytes)
-	EffectiveGroupID       uint32 // effective group ID (4 bytes)
-	RealUserID             uint32 // real user ID (4 bytes)
-	RealGroupID            uint32 // real group ID (4 bytes)
-	ProcessID              uint32 // process ID (4 bytes)
-	SessionID              uint32 // session ID (4 bytes)
-	TerminalPortID         uint64 // terminal port ID (8 byte)
-	TerminalMachineAddress net.IP // IP(v4) address of machine (4 bytes)
+	TokenID                byte   `json:"token_id"`                 // Token ID (1 byte): 0x77
+	AuditID                uint32 `json:"audit_id"`                 // audit user ID (4 bytes)
+	EffectiveUserID        uint32 `json:"effective_user_id"`        // effective user ID (4 bytes)
+	EffectiveGroupID       uint32 `json:"effective_group_id"`       // effective group ID (4 bytes)
+	RealUserID             uint32 `json:"real_user_id"`             // real user ID (4 bytes)
+	RealGroupID            uint32 `json:"real_group_id"`            // real group ID (4 bytes)
+	ProcessID              uint32 `json:"process_id"`               // process ID (4 bytes)
+	SessionID              uint32 `json:"session_id"`               // session ID (4 bytes)
+	TerminalPortID         uint64 `json:"terminal_port_id"`         // terminal port ID (8 byte)
+	TerminalMachineAddress net.IP `json:"terminal_machine_address"` // IP(v4) address of machine (4 bytes)
 }
 
 // ExpandedProcessToken32bit (or 'expanded process' token contains the contents
@@ -285,17 +348,17 @@ type ProcessToken64bit struct {
 // The terminal port ID is encoded using 32 bit.
 // TODO: check length of IP records (4 bytes for IPv6?)
 type ExpandedProcessToken32bit struct {
-	TokenID                byte   // Token ID (1 byte): 0x7b
-	AuditID                uint32 // audit user ID (4 bytes)
-	EffectiveUserID        uint32 // effective user ID (4 bytes)
-	EffectiveGroupID       uint32 // effective group ID (4 bytes)
-	RealUserID             uint32 // real user ID (4 bytes)
-	RealGroupID            uint32 // real group ID (4 bytes)
-	ProcessID              uint32 // process ID (4 bytes)
-	SessionID              uint32 // session ID (4 bytes)
-	TerminalPortID         uint32 // terminal port ID (4 byte)
-	TerminalAddressLength  uint32 // length of machine address (4 bytes)
-	TerminalMachineAddress net.IP // IP address of machine (4 or 16 bytes)
+	TokenID                byte   `json:"token_id"`                 // Token ID (1 byte): 0x7b
+	AuditID                uint32 `json:"audit_id"`                 // audit user ID (4 bytes)
+	EffectiveUserID        uint32 `json:"effective_user_id"`        // effective user ID (4 bytes)
+	EffectiveGroupID       uint32 `json:"effective_group_id"`       // effective group ID (4 bytes)
+	RealUserID             uint32 `json:"real_user_id"`             // real user ID (4 bytes)
+	RealGroupID            uint32 `json:"real_group_id"`            // real group ID (4 bytes)
+	ProcessID              uint32 `json:"process_id"`               // process ID (4 bytes)
+	SessionID              uint32 `json:"session_id"`               // session ID (4 bytes)
+	TerminalPortID         uint32 `json:"terminal_port_id"`         // terminal port ID (4 byte)
+	TerminalAddressLength  uint32 `json:"terminal_address_length"`  // length of machine address (4 bytes)
+	TerminalMachineAddress net.IP `json:"terminal_machine_address"` // IP address of machine (4 or 16 bytes)
 }
 
 // ExpandedProcessToken64bit (or 'expanded process' token contains the contents
@@ -304,17 +367,27 @@ type ExpandedProcessToken32bit struct {
 // The terminal port ID is encoded using 64 bit.
 // TODO: check length of IP records (4 bytes for IPv6?)
 type ExpandedProcessToken64bit struct {
-	TokenID                byte   // Token ID (1 byte): 0x7d
-	AuditID                uint32 // audit user ID (4 bytes)
-	EffectiveUserID        uint32 // effective user ID (4 bytes)
-	EffectiveGroupID       uint32 // effective group ID (4 bytes)
-	RealUserID             uint32 // real user ID (4 bytes)
-	RealGroupID            uint32 // real group ID (4 bytes)
-	ProcessID              uint32 // process ID (4 bytes)
-	SessionID              uint32 // session ID (4 bytes)
-	TerminalPortID         uint64 // terminal port ID (8 byte)
-	TerminalAddressLength  uint32 // length of machine address (4 bytes)
-	TerminalMachineAddress net.IP // IP address of machine (4 or 16 bytes)
+	TokenID                byte   `json:"token_id"`                 // Token ID (1 byte): 0x7d
+	AuditID                uint32 `json:"audit_id"`                 // audit user ID (4 bytes)
+	EffectiveUserID        uint32 `json:"effective_user_id"`        // effective user ID (4 bytes)
+	EffectiveGroupID       uint32 `json:"effective_group_id"`       // effective group ID (4 bytes)
+	RealUserID             uint32 `json:"real_user_id"`             // real user ID (4 bytes)
+	RealGroupID            uint32 `json:"real_group_id"`            // real group ID (4 bytes)
+	ProcessID              uint32 `json:"process_id"`               // process ID (4 bytes)
+	SessionID              uint32 `json:"session_id"`               // session ID (4 bytes)
+	TerminalPortID         uint64 `json:"terminal_port_id"`         // terminal port ID (8 byte)
+	TerminalAddressLength  uint32 `json:"terminal_address_length"`  // length of machine address (4 bytes)
+	TerminalMachineAddress net.IP `json:"terminal_machine_address"` // IP address of machine (4 or 16 bytes)
+}
+
+// RawToken holds the bytes of a token whose size is known (see
+// determineTokenSize) but which has no parser yet. This decouples reading
+// past a token from decoding it, so new token IDs don't break parsing of
+// whole files. Raw tokens are only returned in lenient mode (see
+// RecordReader.Lenient and TokenScanner.Lenient).
+type RawToken struct {
+	TokenID byte   `json:"token_id"` // Token ID (1 byte)
+	Raw     []byte `json:"raw"`      // complete token including token ID
 }
 
 // ReturnToken32bit (or 'return' token) contains a system call or library
@@ -322,9 +395,9 @@ type ExpandedProcessToken64bit struct {
 // associated with the global (C) variable errno. This type uses 32 bit
 // to encode the return value.
 type ReturnToken32bit struct {
-	TokenID     byte   // Token ID (1 byte): 0x27
-	ErrorNumber uint8  // errno number, or 0 if undefined (1 byte)
-	ReturnValue uint32 // return value (4 bytes)
+	TokenID     byte   `json:"token_id"`     // Token ID (1 byte): 0x27
+	ErrorNumber uint8  `json:"error_number"` // errno number, or 0 if undefined (1 byte)
+	ReturnValue uint32 `json:"return_value"` // return value (4 bytes)
 }
 
 // ReturnToken64bit (or 'return' token) contains a system call or library
@@ -332,9 +405,9 @@ type ReturnToken32bit struct {
 // associated with the global (C) variable errno. This type uses 64 bit
 // to encode the return value.
 type ReturnToken64bit struct {
-	TokenID     byte   // Token ID (1 byte): 0x72
-	ErrorNumber uint8  // errno number, or 0 if undefined (1 byte)
-	ReturnValue uint64 // return value (8 bytes)
+	TokenID     byte   `json:"token_id"`     // Token ID (1 byte): 0x72
+	ErrorNumber uint8  `json:"error_number"` // errno number, or 0 if undefined (1 byte)
+	ReturnValue uint64 `json:"return_value"` // return value (8 bytes)
 }
 
 // SeqToken ('seq' token) contains a unique and monotonically
@@ -342,36 +415,63 @@ type ReturnToken64bit struct {
 // bits, serial number arithmetic and caution should be used when
 // comparing sequence numbers.
 type SeqToken struct {
-	TokenID        byte   // Token ID (1 byte): 0x2f
-	SequenceNumber uint32 // audit event sequence number
-}
-
-// SocketToken (or 'socket' token) contains information about UNIX
-// domain and Internet sockets. Each token has four or eight fields.
-// Possible values for token IDs:
-// * BSM specification: 0x2e
-// * inet32 (IPv4) socket: 0x80
-// * inet128 (IPv6) socket: 0x81
-// * Unix socket: 0x82
-// BUG: last sentence is confusing
+	TokenID        byte   `json:"token_id"`        // Token ID (1 byte): 0x2f
+	SequenceNumber uint32 `json:"sequence_number"` // audit event sequence number
+}
+
+// SocketToken (or 'socket' token) contains information about an
+// Internet socket as described by the BSM specification (0x2e). Its
+// address field is always 4 bytes, regardless of the socket family.
+// FreeBSD and Darwin write dedicated tokens instead:
+// * inet32 (IPv4) socket: 0x80 (SocketInet32Token)
+// * inet128 (IPv6) socket: 0x81 (SocketInet128Token)
+// * Unix socket: 0x82 (SocketUnixToken)
+// IPv6 sockets can't be represented by this token, so IPv6 families
+// are rejected by ParseSocketToken.
 type SocketToken struct {
-	TokenID       byte   // Token ID (1 byte): 0x2e (BSM spec), 0x80 (inet32 socket), 0x81 (inet128 token), 0x82 (Unix token)
-	SocketFamily  uint16 // socket family (2 bytes)
-	LocalPort     uint16 // local port (2 bytes)
-	SocketAddress net.IP // socket address (4 bytes or 8 bytes for inet128 socket)
+	TokenID       byte   `json:"token_id"`       // Token ID (1 byte): 0x2e
+	SocketFamily  uint16 `json:"socket_family"`  // socket family (2 bytes)
+	LocalPort     uint16 `json:"local_port"`     // local port (2 bytes)
+	SocketAddress net.IP `json:"socket_address"` // socket address (4 bytes)
+}
+
+// SocketInet32Token (or 'inet32 socket' token) contains information
+// about an IPv4 socket as written by FreeBSD and Darwin (AUT_SOCKINET32).
+type SocketInet32Token struct {
+	TokenID       byte   `json:"token_id"`       // Token ID (1 byte): 0x80
+	SocketFamily  uint16 `json:"socket_family"`  // socket family (2 bytes)
+	LocalPort     uint16 `json:"local_port"`     // local port (2 bytes)
+	SocketAddress net.IP `json:"socket_address"` // IPv4 address (4 bytes)
+}
+
+// SocketInet128Token (or 'inet128 socket' token) contains information
+// about an IPv6 socket as written by FreeBSD and Darwin (AUT_SOCKINET128).
+type SocketInet128Token struct {
+	TokenID       byte   `json:"token_id"`       // Token ID (1 byte): 0x81
+	SocketFamily  uint16 `json:"socket_family"`  // socket family (2 bytes)
+	LocalPort     uint16 `json:"local_port"`     // local port (2 bytes)
+	SocketAddress net.IP `json:"socket_address"` // IPv6 address (16 bytes)
+}
+
+// SocketUnixToken (or 'unix socket' token) contains information
+// about a UNIX domain socket as written by FreeBSD and Darwin (AUT_SOCKUNIX).
+type SocketUnixToken struct {
+	TokenID      byte   `json:"token_id"`      // Token ID (1 byte): 0x82
+	SocketFamily uint16 `json:"socket_family"` // socket family (2 bytes)
+	Path         string `json:"path"`          // socket path without NUL (max. 104 bytes + NUL)
 }
 
 // ExpandedSocketToken (or 'expanded socket' token) contains
 // information about IPv4 and IPv6 sockets.
 type ExpandedSocketToken struct {
-	TokenID         byte   // Token ID (1 byte): 0x7f
-	SocketDomain    uint16 // socket domain (2 bytes)
-	SocketType      uint16 // socket type (2 bytes)
-	AddressType     uint16 // address type (IPv4/IPv6) (2 bytes)
-	LocalPort       uint16 // local port (2 bytes)
-	LocalIpAddress  net.IP // local IP address (4/16 bytes)
-	RemotePort      uint16 // remote port (2 bytes)
-	RemoteIpAddress net.IP // remote IP address (4/16 bytes)
+	TokenID         byte   `json:"token_id"`          // Token ID (1 byte): 0x7f
+	SocketDomain    uint16 `json:"socket_domain"`     // socket domain (2 bytes)
+	SocketType      uint16 `json:"socket_type"`       // socket type (2 bytes)
+	AddressType     uint16 `json:"address_type"`      // address type (IPv4/IPv6) (2 bytes)
+	LocalPort       uint16 `json:"local_port"`        // local port (2 bytes)
+	LocalIpAddress  net.IP `json:"local_ip_address"`  // local IP address (4/16 bytes)
+	RemotePort      uint16 `json:"remote_port"`       // remote port (2 bytes)
+	RemoteIpAddress net.IP `json:"remote_ip_address"` // remote IP address (4/16 bytes)
 }
 
 // SubjectToken32bit (or 'subject' token) contains information on the
@@ -381,16 +481,16 @@ type ExpandedSocketToken struct {
 // the process being described is the target of the operation, not the
 // authorizing party. This type uses 32 bit to encode the terminal port ID.
 type SubjectToken32bit struct {
-	TokenID                byte   // Token ID (1 byte): 0x24
-	AuditID                uint32 // audit user ID (4 bytes)
-	EffectiveUserID        uint32 // effective user ID (4 bytes)
-	EffectiveGroupID       uint32 // effective group ID (4 bytes)
-	RealUserID             uint32 // real user ID (4 bytes)
-	RealGroupID            uint32 // real group ID (4 bytes)
-	ProcessID              uint32 // process ID (4 bytes)
-	SessionID              uint32 // audit session ID (4 bytes)
-	TerminalPortID         uint32 // terminal port ID (4 bytes)
-	TerminalMachineAddress net.IP // IP address of machine (4 bytes)
+	TokenID                byte   `json:"token_id"`                 // Token ID (1 byte): 0x24
+	AuditID                uint32 `json:"audit_id"`                 // audit user ID (4 bytes)
+	EffectiveUserID        uint32 `json:"effective_user_id"`        // effective user ID (4 bytes)
+	EffectiveGroupID       uint32 `json:"effective_group_id"`       // effective group ID (4 bytes)
+	RealUserID             uint32 `json:"real_user_id"`             // real user ID (4 bytes)
+	RealGroupID            uint32 `json:"real_group_id"`            // real group ID (4 bytes)
+	ProcessID              uint32 `json:"process_id"`               // process ID (4 bytes)
+	SessionID              uint32 `json:"session_id"`               // audit session ID (4 bytes)
+	TerminalPortID         uint32 `json:"terminal_port_id"`         // terminal port ID (4 bytes)
+	TerminalMachineAddress net.IP `json:"terminal_machine_address"` // IP address of machine (4 bytes)
 }
 
 // SubjectToken64bit (or 'subject' token) contains information on the
@@ -400,16 +500,16 @@ type SubjectToken32bit struct {
 // process being described is the target of the operation, not the
 // authorizing party. This type uses 64 bit to encode the terminal port ID.
 type SubjectToken64bit struct {
-	TokenID                byte   // Token ID (1 byte): 0x75
-	AuditID                uint32 // audit user ID (4 bytes)
-	EffectiveUserID        uint32 // effective user ID (4 bytes)
-	EffectiveGroupID       uint32 // effective group ID (4 bytes)
-	RealUserID             uint32 // real user ID (4 bytes)
-	RealGroupID            uint32 // real group ID (4 bytes)
-	ProcessID              uint32 // process ID (4 bytes)
-	SessionID              uint32 // audit session ID (4 bytes)
-	TerminalPortID         uint64 // terminal port ID (8 bytes)
-	TerminalMachineAddress net.IP // IP address of machine (4 bytes)
+	TokenID                byte   `json:"token_id"`                 // Token ID (1 byte): 0x75
+	AuditID                uint32 `json:"audit_id"`                 // audit user ID (4 bytes)
+	EffectiveUserID        uint32 `json:"effective_user_id"`        // effective user ID (4 bytes)
+	EffectiveGroupID       uint32 `json:"effective_group_id"`       // effective group ID (4 bytes)
+	RealUserID             uint32 `json:"real_user_id"`             // real user ID (4 bytes)
+	RealGroupID            uint32 `json:"real_group_id"`            // real group ID (4 bytes)
+	ProcessID              uint32 `json:"process_id"`               // process ID (4 bytes)
+	SessionID              uint32 `json:"session_id"`               // audit session ID (4 bytes)
+	TerminalPortID         uint64 `json:"terminal_port_id"`         // terminal port ID (8 bytes)
+	TerminalMachineAddress net.IP `json:"terminal_machine_address"` // IP address of machine (4 bytes)
 }
 
 // ExpandedSubjectToken32bit (or 'expanded subject' token)
@@ -418,17 +518,17 @@ type SubjectToken64bit struct {
 // address information in the terminal ID.
 // This type uses 32 bit to encode the terminal port ID.
 type ExpandedSubjectToken32bit struct {
-	TokenID                byte   // Token ID (1 byte): 0x7a
-	AuditID                uint32 // audit user ID (4 bytes)
-	EffectiveUserID        uint32 // effective user ID (4 bytes)
-	EffectiveGroupID       uint32 // effective group ID (4 bytes)
-	RealUserID             uint32 // real user ID (4 bytes)
-	RealGroupID            uint32 // real group ID (4 bytes)
-	ProcessID              uint32 // process ID (4 bytes)
-	SessionID              uint32 // audit session ID (4 bytes)
-	TerminalPortID         uint32 // terminal port ID (4 bytes)
-	TerminalAddressLength  uint32 // length of machine address (4 bytes)
-	TerminalMachineAddress net.IP // IP address of machine (4/16 bytes)
+	TokenID                byte   `json:"token_id"`                 // Token ID (1 byte): 0x7a
+	AuditID                uint32 `json:"audit_id"`                 // audit user ID (4 bytes)
+	EffectiveUserID        uint32 `json:"effective_user_id"`        // effective user ID (4 bytes)
+	EffectiveGroupID       uint32 `json:"effective_group_id"`       // effective group ID (4 bytes)
+	RealUserID             uint32 `json:"real_user_id"`             // real user ID (4 bytes)
+	RealGroupID            uint32 `json:"real_group_id"`            // real group ID (4 bytes)
+	ProcessID              uint32 `json:"process_id"`               // process ID (4 bytes)
+	SessionID              uint32 `json:"session_id"`               // audit session ID (4 bytes)
+	TerminalPortID         uint32 `json:"terminal_port_id"`         // terminal port ID (4 bytes)
+	TerminalAddressLength  uint32 `json:"terminal_address_length"`  // length of machine address (4 bytes)
+	TerminalMachineAddress net.IP `json:"terminal_machine_address"` // IP address of machine (4/16 bytes)
 }
 
 // ExpandedSubjectToken64bit (or 'expanded subject' token)
@@ -436,67 +536,111 @@ type ExpandedSubjectToken32bit struct {
 // with the addition of type/length and variable size machine
 // address information in the terminal ID.
 // This type uses 64 bit to encode the terminal port ID.
-// TODO: check length of machine address field (4 bytes for IPv6?)
 type ExpandedSubjectToken64bit struct {
-	TokenID                byte   // Token ID (1 byte): 0x7c
-	AuditID                uint32 // audit user ID (4 bytes)
-	EffectiveUserID        uint32 // effective user ID (4 bytes)
-	EffectiveGroupID       uint32 // effective group ID (4 bytes)
-	RealUserID             uint32 // real user ID (4 bytes)
-	RealGroupID            uint32 // real group ID (4 bytes)
-	ProcessID              uint32 // process ID (4 bytes)
-	SessionID              uint32 // audit session ID (4 bytes)
-	TerminalPortID         uint64 // terminal port ID (8 bytes)
-	TerminalAddressLength  uint8  // length of machine address (1 byte)
-	TerminalMachineAddress net.IP // IP address of machine (4/16 bytes)
+	TokenID                byte   `json:"token_id"`                 // Token ID (1 byte): 0x7c
+	AuditID                uint32 `json:"audit_id"`                 // audit user ID (4 bytes)
+	EffectiveUserID        uint32 `json:"effective_user_id"`        // effective user ID (4 bytes)
+	EffectiveGroupID       uint32 `json:"effective_group_id"`       // effective group ID (4 bytes)
+	RealUserID             uint32 `json:"real_user_id"`             // real user ID (4 bytes)
+	RealGroupID            uint32 `json:"real_group_id"`            // real group ID (4 bytes)
+	ProcessID              uint32 `json:"process_id"`               // process ID (4 bytes)
+	SessionID              uint32 `json:"session_id"`               // audit session ID (4 bytes)
+	TerminalPortID         uint64 `json:"terminal_port_id"`         // terminal port ID (8 bytes)
+	TerminalAddressLength  uint32 `json:"terminal_address_length"`  // length of machine address (4 bytes)
+	TerminalMachineAddress net.IP `json:"terminal_machine_address"` // IP address of machine (4/16 bytes)
 }
 
 // SystemVIpcToken (or 'System V IPC' token) contains the System V
 // IPC message handle, semaphore handle or shared memory handle.
 type SystemVIpcToken struct {
-	TokenID      byte   // Token ID (1 byte): 0x22
-	ObjectIdType uint8  // Object ID (1 byte)
-	ObjectID     uint32 // Object ID (4 bytes)
+	TokenID      byte   `json:"token_id"`       // Token ID (1 byte): 0x22
+	ObjectIdType uint8  `json:"object_id_type"` // Object ID (1 byte)
+	ObjectID     uint32 `json:"object_id"`      // Object ID (4 bytes)
 }
 
 // SystemVIpcPermissionToken (or 'System V IPC permission' token)
 // contains a System V IPC access permissions.
 type SystemVIpcPermissionToken struct {
-	TokenID        byte   // Token ID (1 byte): 0x32
-	OwnerUserID    uint32 // User ID of IPC owner (4 bytes)
-	OwnerGroupID   uint32 // Group ID of IPC owner (4 bytes)
-	CreatorUserID  uint32 // User ID of IPC creator (4 bytes)
-	CreatorGroupID uint32 //  Group ID of IPC creator (4 bytes)
-	AccessMode     uint32 // Access mode (4 bytes)
-	SequenceNumber uint32 // Sequence number (4 bytes)
-	Key            uint32 // IPC key (4 bytes)
+	TokenID        byte   `json:"token_id"`         // Token ID (1 byte): 0x32
+	OwnerUserID    uint32 `json:"owner_user_id"`    // User ID of IPC owner (4 bytes)
+	OwnerGroupID   uint32 `json:"owner_group_id"`   // Group ID of IPC owner (4 bytes)
+	CreatorUserID  uint32 `json:"creator_user_id"`  // User ID of IPC creator (4 bytes)
+	CreatorGroupID uint32 `json:"creator_group_id"` //  Group ID of IPC creator (4 bytes)
+	AccessMode     uint32 `json:"access_mode"`      // Access mode (4 bytes)
+	SequenceNumber uint32 `json:"sequence_number"`  // Sequence number (4 bytes)
+	Key            uint32 `json:"key"`              // IPC key (4 bytes)
 }
 
 // TextToken (or 'text' token) contains a single NUL-terminated text string.
-// TODO: check actual length (documentation looks like off-by-one)
+// The length field counts the terminating NUL (e.g. "auditd::Audit startup"
+// is stored with a length of 22), the decoded text does not include it.
 type TextToken struct {
-	TokenID    byte   // Token ID (1 byte): 0x28
-	TextLength uint16 // length of text string including NUL (2 bytes)
-	Text       string // Text string incl. NUL (TextLength bytes)
+	TokenID    byte   `json:"token_id"`    // Token ID (1 byte): 0x28
+	TextLength uint16 `json:"text_length"` // length of text string including NUL (2 bytes)
+	Text       string `json:"text"`        // Text string without NUL (TextLength - 1 bytes)
 }
 
 // TrailerToken (or 'trailer' terminates) a BSM audit record. This token
 // contains a magic number, and length that can be used to validate that
 // the record was read properly.
 type TrailerToken struct {
-	TokenID         byte   // Token ID (1 byte): 0x13
-	TrailerMagic    uint16 // trailer magic number (2 bytes): 0xb105
-	RecordByteCount uint32 // number of bytes in record (4 bytes)
+	TokenID         byte   `json:"token_id"`          // Token ID (1 byte): 0x13
+	TrailerMagic    uint16 `json:"trailer_magic"`     // trailer magic number (2 bytes): 0xb105
+	RecordByteCount uint32 `json:"record_byte_count"` // number of bytes in record (4 bytes)
 }
 
 // ZonenameToken (or 'zonename' token) holds a NUL-terminated string
 // with the name of the zone or jail from which the record originated.
 type ZonenameToken struct {
-	TokenID        byte   // Token ID (1 byte): 0x60
-	ZonenameLength uint16 // length of zonename string including NUL (2 bytes)
-	Zonename       string // Zonename string including NUL
+	TokenID        byte   `json:"token_id"`        // Token ID (1 byte): 0x60
+	ZonenameLength uint16 `json:"zonename_length"` // length of zonename string including NUL (2 bytes)
+	Zonename       string `json:"zonename"`        // Zonename string without NUL (ZonenameLength - 1 bytes)
 }
 
+// ID returns the token ID of the respective token (Token interface).
+func (t ArgToken32bit) Type() byte             { return t.TokenID }
+func (t ArgToken64bit) Type() byte             { return t.TokenID }
+func (t ArbitraryDataToken) Type() byte        { return t.TokenID }
+func (t AttributeToken32bit) Type() byte       { return t.TokenID }
+func (t AttributeToken64bit) Type() byte       { return t.TokenID }
+func (t ExecArgsToken) Type() byte             { return t.TokenID }
+func (t ExecEnvToken) Type() byte              { return t.TokenID }
+func (t ExitToken) Type() byte                 { return t.TokenID }
+func (t FileToken) Type() byte                 { return t.TokenID }
+func (t GroupsToken) Type() byte               { return t.TokenID }
+func (t HeaderToken32bit) Type() byte          { return t.TokenID }
+func (t HeaderToken64bit) Type() byte          { return t.TokenID }
+func (t ExpandedHeaderToken32bit) Type() byte  { return t.TokenID }
+func (t ExpandedHeaderToken64bit) Type() byte  { return t.TokenID }
+func (t InAddrToken) Type() byte               { return t.TokenID }
+func (t ExpandedInAddrToken) Type() byte       { return t.TokenID }
+func (t IpToken) Type() byte                   { return t.TokenID }
+func (t IPortToken) Type() byte                { return t.TokenID }
+func (t PathToken) Type() byte                 { return t.TokenID }
+func (t PathAttrToken) Type() byte             { return t.TokenID }
+func (t ProcessToken32bit) Type() byte         { return t.TokenID }
+func (t ProcessToken64bit) Type() byte         { return t.TokenID }
+func (t RawToken) Type() byte                  { return t.TokenID }
+func (t ExpandedProcessToken32bit) Type() byte { return t.TokenID }
+func (t ExpandedProcessToken64bit) Type() byte { return t.TokenID }
+func (t ReturnToken32bit) Type() byte          { return t.TokenID }
+func (t ReturnToken64bit) Type() byte          { return t.TokenID }
+func (t SeqToken) Type() byte                  { return t.TokenID }
+func (t SocketToken) Type() byte               { return t.TokenID }
+func (t SocketInet32Token) Type() byte         { return t.TokenID }
+func (t SocketInet128Token) Type() byte        { return t.TokenID }
+func (t SocketUnixToken) Type() byte           { return t.TokenID }
+func (t ExpandedSocketToken) Type() byte       { return t.TokenID }
+func (t SubjectToken32bit) Type() byte         { return t.TokenID }
+func (t SubjectToken64bit) Type() byte         { return t.TokenID }
+func (t ExpandedSubjectToken32bit) Type() byte { return t.TokenID }
+func (t ExpandedSubjectToken64bit) Type() byte { return t.TokenID }
+func (t SystemVIpcToken) Type() byte           { return t.TokenID }
+func (t SystemVIpcPermissionToken) Type() byte { return t.TokenID }
+func (t TextToken) Type() byte                 { return t.TokenID }
+func (t TrailerToken) Type() byte              { return t.TokenID }
+func (t ZonenameToken) Type() byte             { return t.TokenID }
+
 // Go has this unexpected behaviour, where Uvarint() aborts
 // after reading the first byte if it is 0x00 (no matter
 // what comes later) and can eat max 2 bytes. I expected 8 since
@@ -505,7 +649,10 @@ type ZonenameToken struct {
 // Convert bytes to uint64 (and abstract away some quirks).
 func bytesToUint64(input []byte) (uint64, error) {
 	if 8 < len(input) {
-		return 0, errors.New("more than four bytes given -> risk of overflow")
+		return 0, errors.New("more than eight bytes given -> risk of overflow")
+	}
+	if 8 == len(input) {
+		return binary.BigEndian.Uint64(input), nil
 	}
 	result := uint64(0)
 	for i := 0; i < len(input); i++ {
@@ -522,6 +669,9 @@ func bytesToUint32(input []byte) (uint32, error) {
 	if 4 < len(input) {
 		return 0, errors.New("more than four bytes given -> risk of overflow")
 	}
+	if 4 == len(input) {
+		return binary.BigEndian.Uint32(input), nil
+	}
 	result := uint32(0)
 	for i := 0; i < len(input); i++ {
 		coeff := uint32(input[i])
@@ -532,11 +682,26 @@ func bytesToUint32(input []byte) (uint32, error) {
 	return result, nil
 }
 
+// Convert four bytes (two's complement) to int32.
+func bytesToInt32(input []byte) (int32, error) {
+	if 4 != len(input) {
+		return 0, errors.New("exactly four bytes needed to decode a signed 32 bit value")
+	}
+	data32, err := bytesToUint32(input)
+	if err != nil {
+		return 0, err
+	}
+	return int32(data32), nil
+}
+
 // Convert bytes to uint32 (and abstract away some quirks).
 func bytesToUint16(input []byte) (uint16, error) {
 	if 2 < len(input) {
 		return 0, errors.New("more than two bytes given -> risk of overflow")
 	}
+	if 2 == len(input) {
+		return binary.BigEndian.Uint16(input), nil
+	}
 	result := uint16(0)
 	for i := 0; i < len(input); i++ {
 		coeff := uint16(input[i])
@@ -547,13 +712,74 @@ func bytesToUint16(input []byte) (uint16, error) {
 	return result, nil
 }
 
+// tokenDecoder decodes the integer fields of tokens (including the length
+// fields used to determine token sizes). The zero value decodes standard
+// BSM, i.e. network byte order (big endian), and rejects unknown tokens.
+type tokenDecoder struct {
+	order   binary.ByteOrder // byte order of integer fields (nil: big endian)
+	lenient bool             // return tokens without parser as RawToken
+	scan    *stringsScan     // progress on the strings of the current token
+}
+
+// uint16 reads two bytes in the byte order of the decoder. Port numbers
+// must be read with bytesToUint16, they are always in network byte order.
+func (d tokenDecoder) uint16(input []byte) (uint16, error) {
+	if d.order != nil && 2 == len(input) {
+		return d.order.Uint16(input), nil
+	}
+	return bytesToUint16(input)
+}
+
+// uint32 reads four bytes in the byte order of the decoder.
+func (d tokenDecoder) uint32(input []byte) (uint32, error) {
+	if d.order != nil && 4 == len(input) {
+		return d.order.Uint32(input), nil
+	}
+	return bytesToUint32(input)
+}
+
+// uint64 reads eight bytes in the byte order of the decoder.
+func (d tokenDecoder) uint64(input []byte) (uint64, error) {
+	if d.order != nil && 8 == len(input) {
+		return d.order.Uint64(input), nil
+	}
+	return bytesToUint64(input)
+}
+
+// int32 reads four bytes (two's complement) in the byte order of the
+// decoder.
+func (d tokenDecoder) int32(input []byte) (int32, error) {
+	if 4 != len(input) {
+		return bytesToInt32(input) // reports the error
+	}
+	data32, err := d.uint32(input)
+	return int32(data32), err
+}
+
+// determineTokenSize determines the size of the current token in network
+// byte order (see tokenDecoder.determineTokenSize).
+func determineTokenSize(input []byte) (size, moreBytes int, err error) {
+	return tokenDecoder{}.determineTokenSize(input)
+}
+
 // Determine the size (in bytes) of the current token. This is a
 // utility function to determine the number of bytes (yet) to read
 // from the input buffer. The return values are:
 // * size - size of token in bytes
 // * moreBytes - number of more bytes to read to make determination
 // * err - any error that ocurred
-func determineTokenSize(input []byte) (size, moreBytes int, err error) {
+// Tokens larger than MaxTokenSize result in ErrTokenTooLarge.
+func (d tokenDecoder) determineTokenSize(input []byte) (size, moreBytes int, err error) {
+	size, moreBytes, err = d.tokenSize(input)
+	if err == nil && (size > MaxTokenSize || len(input)+moreBytes > MaxTokenSize) {
+		err = fmt.Errorf("%w: token 0x%x exceeds %d bytes", ErrTokenTooLarge, input[0], MaxTokenSize)
+	}
+	return
+}
+
+// tokenSize determines the size of the current token (without any
+// limits, see determineTokenSize).
+func (d tokenDecoder) tokenSize(input []byte) (size, moreBytes int, err error) {
 	size = 0
 	moreBytes = 0
 	err = nil
@@ -574,7 +800,7 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 			moreBytes = (1 + 4 + 4 + 2) - len(input)
 			return
 		}
-		fileNameLength, local_err := bytesToUint16(input[9:11]) // read 2 bytes indicating file name length
+		fileNameLength, local_err := d.uint16(input[9:11]) // read 2 bytes indicating file name length
 		if local_err != nil {
 			err = local_err
 			return
@@ -591,7 +817,7 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 			moreBytes = 15 - len(input)
 			return
 		}
-		addrlen, cerr := bytesToUint32(input[10:14])
+		addrlen, cerr := d.uint32(input[10:14])
 		if cerr != nil {
 			err = cerr
 			return
@@ -621,11 +847,12 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 			moreBytes = 3 - len(input)
 			return
 		}
-		count, cerr := bytesToUint16(input[1:3])
+		count, cerr := d.uint16(input[1:3])
 		if cerr != nil {
 			err = cerr
 			return
 		}
+		// count includes the terminating NUL
 		size = 1 + 2 + int(count)
 	case 0x24: // 32 bit Subject Token
 		size = 1 + 4 + 4 + 4 + 4 + 4 + 4 + 4 + 4 + 4
@@ -635,19 +862,13 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 			moreBytes = 3 - len(input)
 			return
 		}
-		strCount, cerr := bytesToUint16(input[1:3])
+		strCount, cerr := d.uint16(input[1:3])
 		if cerr != nil {
 			err = cerr
 			return
 		}
 		// make sure we have strCount NUL-terminated strings
-		// NOTE: this is very crude and does not do a full validation
-		//       since it assumes a benevolent byte stream
-		if bytes.Count(input[3:], []byte{0x00}) < int(strCount) {
-			moreBytes = 1
-			return
-		}
-		size = len(input)
+		size, moreBytes, err = d.stringsEnd(input, 3, uint32(strCount))
 	case 0x26: // 32bit process token
 		size = 1 + 4 + 4 + 4 + 4 + 4 + 4 + 4 + 4 + 4
 	case 0x27: // 32 bit Return Token
@@ -658,11 +879,12 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 			moreBytes = 3 - len(input)
 			return
 		}
-		count, cerr := bytesToUint16(input[1:3])
+		count, cerr := d.uint16(input[1:3])
 		if cerr != nil {
 			err = cerr
 			return
 		}
+		// count includes the terminating NUL
 		size = 1 + 2 + int(count)
 	case 0x2a: // in_addr token
 		size = 1 + 4
@@ -676,7 +898,7 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 			moreBytes = 8 - len(input)
 			return
 		}
-		strlen, cerr := bytesToUint16(input[6:8])
+		strlen, cerr := d.uint16(input[6:8])
 		if cerr != nil {
 			err = cerr
 			return
@@ -694,7 +916,7 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 			moreBytes = 3 - len(input)
 			return
 		}
-		count, cerr := bytesToUint16(input[1:3])
+		count, cerr := d.uint16(input[1:3])
 		if cerr != nil {
 			err = cerr
 			return
@@ -706,38 +928,28 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 			moreBytes = 5 - len(input)
 			return
 		}
-		strCount, cerr := bytesToUint32(input[1:5])
+		strCount, cerr := d.uint32(input[1:5])
 		if cerr != nil {
 			err = cerr
 			return
 		}
 		// make sure we have strCount NUL-terminated strings
-		// NOTE: this is very crude and does not do a full validation
-		//       since it assumes a benevolent byte stream
-		if bytes.Count(input[5:], []byte{0x00}) < int(strCount) {
-			moreBytes = 1
-			return
-		}
-		size = len(input)
+		// (a count of 0 results in a token of 5 bytes)
+		size, moreBytes, err = d.stringsEnd(input, 5, strCount)
 	case 0x3d: // exec env token
 		if len(input) < 5 {
 			// need more bytes to read Count field
 			moreBytes = 5 - len(input)
 			return
 		}
-		strCount, cerr := bytesToUint32(input[1:5])
+		strCount, cerr := d.uint32(input[1:5])
 		if cerr != nil {
 			err = cerr
 			return
 		}
 		// make sure we have strCount NUL-terminated strings
-		// NOTE: this is very crude and does not do a full validation
-		//       since it assumes a benevolent byte stream
-		if bytes.Count(input[5:], []byte{0x00}) < int(strCount) {
-			moreBytes = 1
-			return
-		}
-		size = len(input)
+		// (a count of 0 results in a token of 5 bytes)
+		size, moreBytes, err = d.stringsEnd(input, 5, strCount)
 	case 0x3e: // 32bit attribute token
 		size = 1 + 4 + 4 + 4 + 4 + 8 + 4
 	case 0x52: // exit token
@@ -748,7 +960,7 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 			moreBytes = 3 - len(input)
 			return
 		}
-		strlen, cerr := bytesToUint16(input[1:3])
+		strlen, cerr := d.uint16(input[1:3])
 		if cerr != nil {
 			err = cerr
 			return
@@ -760,12 +972,13 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 			moreBytes = 12 - len(input)
 			return
 		}
-		strlen, cerr := bytesToUint16(input[10:12])
+		strlen, cerr := d.uint16(input[10:12])
 		if cerr != nil {
 			err = cerr
 			return
 		}
-		size = 1 + 1 + 8 + 2 + int(strlen) + 1
+		// length includes the terminating NUL (like the 32bit arg token)
+		size = 1 + 1 + 8 + 2 + int(strlen)
 	case 0x72: // 64 bit Return Token
 		size = 1 + 1 + 8
 	case 0x73: // 64 bit attribute token
@@ -775,23 +988,23 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 	case 0x75: // 64 bit Subject Token
 		size = 1 + 4 + 4 + 4 + 4 + 4 + 4 + 4 + 8 + 4
 	case 0x77: // 64 bit process token
-		size = 1 + 4 + 4 + 4 + 4 + 4 + 4 + 4 + 8 + 8
+		size = 1 + 4 + 4 + 4 + 4 + 4 + 4 + 4 + 8 + 4
 	case 0x79: // 64 bit expanded header token
 		if len(input) < 15 {
 			// need more bytes to read AdressType field
 			moreBytes = 15 - len(input)
 			return
 		}
-		addrlen, cerr := bytesToUint32(input[10:14])
+		addrlen, cerr := d.uint32(input[10:14])
 		if cerr != nil {
 			err = cerr
 			return
 		}
 		switch addrlen {
 		case 4: // IPv4 -> 4 bytes address
-			size = 1 + 4 + 2 + 2 + 2 + 4 + 4 + 8 + 8
+			size = 1 + 4 + 1 + 2 + 2 + 4 + 4 + 8 + 8
 		case 16: // IPv6 -> 16 bytes address
-			size = 1 + 4 + 2 + 2 + 2 + 4 + 16 + 8 + 8
+			size = 1 + 4 + 1 + 2 + 2 + 4 + 16 + 8 + 8
 		default:
 			err = fmt.Errorf("invalid value (%d) for 'address type' field in 64bit expanded header token", addrlen)
 		}
@@ -801,7 +1014,7 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 			moreBytes = 37 - len(input)
 			return
 		}
-		addrlen, cerr := bytesToUint32(input[33:37])
+		addrlen, cerr := d.uint32(input[33:37])
 		if cerr != nil {
 			err = cerr
 			return
@@ -819,7 +1032,7 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 			moreBytes = 37 - len(input)
 			return
 		}
-		addrlen, cerr := bytesToUint32(input[33:37])
+		addrlen, cerr := d.uint32(input[33:37])
 		if cerr != nil {
 			err = cerr
 			return
@@ -833,29 +1046,70 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 			err = fmt.Errorf("invalid value (%d) for 'terminal address length' field in 32bit expanded process token", addrlen)
 		}
 	case 0x7c: // expanded 64bit subject token
-		if len(input) < 38 {
+		if len(input) < 41 {
 			// need more bytes to read TerminalAddressLength field
-			moreBytes = 38 - len(input)
+			moreBytes = 41 - len(input)
+			return
+		}
+		addrlen, cerr := d.uint32(input[37:41])
+		if cerr != nil {
+			err = cerr
 			return
 		}
-		addrlen := input[37]
 		switch addrlen {
 		case 4: // IPv4 -> 4 bytes for address
-			size = 1 + 4 + 4 + 4 + 4 + 4 + 4 + 4 + 8 + 1 + 4
+			size = 1 + 4 + 4 + 4 + 4 + 4 + 4 + 4 + 8 + 4 + 4
 		case 16: // IPv6 -> 16 bytes for address
-			size = 1 + 4 + 4 + 4 + 4 + 4 + 4 + 4 + 8 + 1 + 16
+			size = 1 + 4 + 4 + 4 + 4 + 4 + 4 + 4 + 8 + 4 + 16
 		default:
 			err = fmt.Errorf("invalid value (%d) for 'terminal address length' field in 64bit expanded subject token", addrlen)
 		}
+	case 0x7d: // 64bit expanded process token
+		if len(input) < 41 {
+			moreBytes = 41 - len(input)
+			return
+		}
+		addrlen, cerr := d.uint32(input[37:41])
+		if cerr != nil {
+			err = cerr
+			return
+		}
+		switch addrlen {
+		case 4: // IPv4
+			size = 1 + 4 + 4 + 4 + 4 + 4 + 4 + 4 + 8 + 4 + 4
+		case 16: // IPv6
+			size = 1 + 4 + 4 + 4 + 4 + 4 + 4 + 4 + 8 + 4 + 16
+		default:
+			err = fmt.Errorf("invalid value (%d) for 'terminal address length' field in 64bit expanded process token", addrlen)
+		}
 	case 0x7e: // expanded in_addr token
-		size = 1 + 1 + 16 // libbsm always allocates 16 bytes
+		if ExpandedInAddrLayout == ManpageLayout {
+			size = 1 + 1 + 16
+			return
+		}
+		if len(input) < 5 {
+			// need more bytes to read address type
+			moreBytes = 5 - len(input)
+			return
+		}
+		addrlen, cerr := d.uint32(input[1:5])
+		if cerr != nil {
+			err = cerr
+			return
+		}
+		switch addrlen {
+		case 4, 16:
+			size = 1 + 4 + int(addrlen)
+		default:
+			err = fmt.Errorf("invalid address type %d of expanded in_addr token", addrlen)
+		}
 	case 0x7f: // expanded socket token
 		if len(input) < 7 {
 			// need more bytes to read AddressType field
 			moreBytes = 7 - len(input)
 			return
 		}
-		addrlen, cerr := bytesToUint16(input[5:7])
+		addrlen, cerr := d.uint16(input[5:7])
 		if cerr != nil {
 			err = cerr
 			return
@@ -868,24 +1122,272 @@ func determineTokenSize(input []byte) (size, moreBytes int, err error) {
 		default:
 			err = fmt.Errorf("invalid value (%d) for 'address type' field in expanded socket token", addrlen)
 		}
-	case 0x80: // socket token (inet32)
+	case 0x80: // socket token (AUT_SOCKINET32)
+		// token ID (1 byte), socket family (2 bytes),
+		// local port (2 bytes), IPv4 address (4 bytes)
 		size = 1 + 2 + 2 + 4
-	case 0x81: // socket token (inet128)
+	case 0x81: // socket token (AUT_SOCKINET128)
+		// token ID (1 byte), socket family (2 bytes),
+		// local port (2 bytes), IPv6 address (16 bytes)
 		size = 1 + 2 + 2 + 16
-	case 0x82: // FreeBSD socket token
-		size = 1 + 2 + 2 + 4
+	case 0x82: // FreeBSD socket token (AUT_SOCKUNIX)
+		// token ID (1 byte), socket family (2 bytes),
+		// NUL-terminated socket path (max. 104 bytes + NUL)
+		if len(input) < 4 {
+			// need more bytes to read first byte of path
+			moreBytes = 4 - len(input)
+			return
+		}
+		nul := bytes.IndexByte(input[3:], 0x00)
+		if nul == -1 {
+			if len(input)-3 > 104 {
+				err = errors.New("socket path of unix socket token is not NUL-terminated")
+				return
+			}
+			moreBytes = 1
+			return
+		}
+		size = 1 + 2 + nul + 1
 	default:
-		err = fmt.Errorf("can't determine the size of the given token (type): 0x%x", input[0])
+		err = fmt.Errorf("%w: can't determine the size of the given token (type): 0x%x", ErrUnknownTokenID, input[0])
 	}
 	return
 }
 
+// stringsScan keeps the progress of scanning the NUL-terminated strings
+// of a token while its bytes are read, so each byte is scanned once.
+type stringsScan struct {
+	end   int    // index right after the last complete string
+	found uint32 // number of complete strings
+}
+
+// stringsEnd returns the index right after the count-th NUL-terminated
+// string starting at the given index. If there are not enough strings
+// yet, it returns the number of bytes still needed at least (one NUL
+// per missing string) instead. A count which can't fit into MaxTokenSize
+// results in ErrTokenTooLarge.
+func (d tokenDecoder) stringsEnd(input []byte, start int, count uint32) (end, moreBytes int, err error) {
+	if int64(count) > int64(MaxTokenSize-start) {
+		return 0, 0, fmt.Errorf("%w: token 0x%x announces %d strings", ErrTokenTooLarge, input[0], count)
+	}
+	scan := d.scan
+	if scan == nil {
+		scan = &stringsScan{} // no progress kept between calls
+	}
+	if scan.end < start || scan.end > len(input) { // first call for this token
+		*scan = stringsScan{end: start}
+	}
+	for scan.found < count {
+		nul := bytes.IndexByte(input[scan.end:], 0x00)
+		if nul == -1 {
+			return 0, int(count - scan.found), nil
+		}
+		scan.end += nul + 1
+		scan.found += 1
+	}
+	return scan.end, 0, nil
+}
+
+// parseStrings splits the given bytes into count NUL-terminated strings
+// (without NUL). All bytes have to be consumed.
+func parseStrings(input []byte, count uint32) ([]string, error) {
+	texts := []string{}
+	for i := uint32(0); i < count; i++ {
+		nul := bytes.IndexByte(input, 0x00)
+		if nul == -1 {
+			return texts, fmt.Errorf("%w: string %d is not NUL-terminated", ErrShortToken, i)
+		}
+		texts = append(texts, string(input[:nul]))
+		input = input[nul+1:]
+	}
+	if len(input) != 0 {
+		return texts, errors.New("unexpected bytes after last string")
+	}
+	return texts, nil
+}
+
+// ParseExecArgsToken parses an ExecArgsToken out of the given bytes.
+func ParseExecArgsToken(input []byte) (ExecArgsToken, error) {
+	return tokenDecoder{}.parseExecArgsToken(input)
+}
+
+// parseExecArgsToken parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseExecArgsToken(input []byte) (ExecArgsToken, error) {
+	token := ExecArgsToken{}
+
+	// length check (token ID + count field)
+	if len(input) < 5 {
+		return token, fmt.Errorf("%w: invalid length of exec args token", ErrShortToken)
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x3c {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read number of arguments
+	count, err := d.uint32(input[1:5])
+	if err != nil {
+		return token, err
+	}
+	token.Count = count
+
+	// read arguments (an empty list for a count of 0)
+	token.Text, err = parseStrings(input[5:], count)
+	if err != nil {
+		return token, err
+	}
+
+	return token, nil
+}
+
+// ParseExecEnvToken parses an ExecEnvToken out of the given bytes.
+func ParseExecEnvToken(input []byte) (ExecEnvToken, error) {
+	return tokenDecoder{}.parseExecEnvToken(input)
+}
+
+// parseExecEnvToken parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseExecEnvToken(input []byte) (ExecEnvToken, error) {
+	token := ExecEnvToken{}
+
+	// length check (token ID + count field)
+	if len(input) < 5 {
+		return token, fmt.Errorf("%w: invalid length of exec env token", ErrShortToken)
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x3d {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read number of variables
+	count, err := d.uint32(input[1:5])
+	if err != nil {
+		return token, err
+	}
+	token.Count = count
+
+	// read variables (an empty list for a count of 0)
+	token.Text, err = parseStrings(input[5:], count)
+	if err != nil {
+		return token, err
+	}
+
+	return token, nil
+}
+
+// ParseFileToken parses a FileToken out of the given bytes.
+func ParseFileToken(input []byte) (FileToken, error) {
+	return tokenDecoder{}.parseFileToken(input)
+}
+
+// parseFileToken parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseFileToken(input []byte) (FileToken, error) {
+	token := FileToken{}
+
+	// length check (up to the file name length field)
+	if len(input) < 11 {
+		return token, fmt.Errorf("%w: invalid length of file token", ErrShortToken)
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x11 {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read time stamp
+	data32, err := d.uint32(input[1:5])
+	if err != nil {
+		return token, err
+	}
+	token.Seconds = data32
+	data32, err = d.uint32(input[5:9])
+	if err != nil {
+		return token, err
+	}
+	token.Microseconds = data32
+
+	// read file name length (excluding NUL)
+	length, err := d.uint16(input[9:11])
+	if err != nil {
+		return token, err
+	}
+	token.FileNameLength = length
+	if len(input) < 11+int(length)+1 {
+		return token, fmt.Errorf("%w: file name length exceeds length of file token", ErrShortToken)
+	}
+	if len(input) != 11+int(length)+1 {
+		return token, errors.New("file name length does not match length of file token")
+	}
+	token.PathName = string(input[11 : 11+int(length)])
+
+	return token, nil
+}
+
+// ParseTrailerToken parses a TrailerToken out of the given bytes.
+func ParseTrailerToken(input []byte) (TrailerToken, error) {
+	return tokenDecoder{}.parseTrailerToken(input)
+}
+
+// parseTrailerToken parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseTrailerToken(input []byte) (TrailerToken, error) {
+	token := TrailerToken{}
+
+	// (static) length check
+	if len(input) < 7 {
+		return token, fmt.Errorf("%w: invalid length of trailer token", ErrShortToken)
+	}
+	if len(input) != 7 {
+		return token, errors.New("invalid length of trailer token")
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x13 {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read trailer magic
+	data16, err := d.uint16(input[1:3])
+	if err != nil {
+		return token, err
+	}
+	token.TrailerMagic = data16
+	if token.TrailerMagic != 0xb105 {
+		return token, fmt.Errorf("%w: 0x%x", ErrBadTrailerMagic, token.TrailerMagic)
+	}
+
+	// read record byte count
+	data32, err := d.uint32(input[3:7])
+	if err != nil {
+		return token, err
+	}
+	token.RecordByteCount = data32
+
+	return token, nil
+}
+
 // ParseHeaderToken32bit parses a HeaderToken32bit out of the given bytes.
 func ParseHeaderToken32bit(input []byte) (HeaderToken32bit, error) {
+	return tokenDecoder{}.parseHeaderToken32bit(input)
+}
+
+// parseHeaderToken32bit parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseHeaderToken32bit(input []byte) (HeaderToken32bit, error) {
 	ptr := 0
 	token := HeaderToken32bit{}
 
 	// (static) length check
+	if len(input) < 18 {
+		return token, fmt.Errorf("%w: invalid length of 32bit header token", ErrShortToken)
+	}
 	if len(input) != 18 {
 		return token, errors.New("invalid length of 32bit header token")
 	}
@@ -899,7 +1401,7 @@ func ParseHeaderToken32bit(input []byte) (HeaderToken32bit, error) {
 	ptr += 1
 
 	// read record byte count (4 bytes)
-	data32, err := bytesToUint32(input[ptr : ptr+4])
+	data32, err := d.uint32(input[ptr : ptr+4])
 	if err != nil {
 		return token, err
 	}
@@ -911,7 +1413,7 @@ func ParseHeaderToken32bit(input []byte) (HeaderToken32bit, error) {
 	ptr += 1
 
 	// read event type (2 bytes)
-	data16, err := bytesToUint16(input[ptr : ptr+2])
+	data16, err := d.uint16(input[ptr : ptr+2])
 	if err != nil {
 		return token, err
 	}
@@ -919,7 +1421,7 @@ func ParseHeaderToken32bit(input []byte) (HeaderToken32bit, error) {
 	ptr += 2
 
 	// read event sub-type / modifier
-	data16, err = bytesToUint16(input[ptr : ptr+2])
+	data16, err = d.uint16(input[ptr : ptr+2])
 	if err != nil {
 		return token, err
 	}
@@ -927,7 +1429,7 @@ func ParseHeaderToken32bit(input []byte) (HeaderToken32bit, error) {
 	ptr += 2
 
 	// read seconds
-	data32, err = bytesToUint32(input[ptr : ptr+4])
+	data32, err = d.uint32(input[ptr : ptr+4])
 	if err != nil {
 		return token, err
 	}
@@ -935,7 +1437,7 @@ func ParseHeaderToken32bit(input []byte) (HeaderToken32bit, error) {
 	ptr += 4
 
 	// read nanoseconds
-	data32, err = bytesToUint32(input[ptr : ptr+4])
+	data32, err = d.uint32(input[ptr : ptr+4])
 	if err != nil {
 		return token, err
 	}
@@ -944,160 +1446,802 @@ func ParseHeaderToken32bit(input []byte) (HeaderToken32bit, error) {
 	return token, nil
 }
 
-// RecordsFromByteInput yields a generator for all records contained
-// in the given byte input. This input has to support the Reader interface
-// and may be a file or a device.
+// ParseExitToken parses an ExitToken out of the given bytes.
+func ParseExitToken(input []byte) (ExitToken, error) {
+	return tokenDecoder{}.parseExitToken(input)
+}
 
-// TokenFromByteInput converts bytes read from a given input
-// to a BSM token.
-func TokenFromByteInput(input io.Reader) (empty, error) {
-	tokenBuffer := []byte{0x00}
+// parseExitToken parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseExitToken(input []byte) (ExitToken, error) {
+	token := ExitToken{}
 
-	// read all the info we need
-	n, err := input.Read(tokenBuffer[0:1]) // try to use only token ID
-	if nil != err {
-		return nil, err
-	}
-	if n != 1 {
-		return nil, errors.New("read " + strconv.Itoa(n) + " bytes, but wanted exactly 1")
+	// (static) length check
+	if len(input) < 9 {
+		return token, fmt.Errorf("%w: invalid length of exit token", ErrShortToken)
 	}
-	bufidx := 1                                                   // index where to fill the buffer
-	buflen, increase, err := determineTokenSize(tokenBuffer[0:1]) // read only token ID
-	if nil != err {
-		return nil, err
+	if len(input) != 9 {
+		return token, errors.New("invalid length of exit token")
 	}
 
-	if increase != 0 { // we need more bytes and test again
-		// increase token buffer to hold new bytes
-		tmp := make([]byte, 1+increase) // we have read one byte already
-		copy(tmp, tokenBuffer)
-		tokenBuffer = tmp
-		for increase > 0 {
-			// try to read all bytes
-			n, err := input.Read(tokenBuffer[bufidx : bufidx+increase])
-			if nil != err {
-				return nil, err
-			}
-			bufidx += n        // move the index the number of bytes read
-			if n != increase { // adjust how many more to read
-				increase -= n
-			} else {
-				increase = 0 // no more bytes need to be read
-			}
-		}
-		buflen, increase, err = determineTokenSize(tokenBuffer)
-		if nil != err {
-			return nil, err
-		}
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x52 {
+		return token, errors.New("token ID mismatch")
 	}
-	// read all the (remaining) bytes we need
-	tmp := make([]byte, buflen) // increase token buffer to hold new bytes
-	copy(tmp, tokenBuffer)
-	tokenBuffer = tmp
-	n, err = input.Read(tokenBuffer[bufidx:buflen]) // read remaining bytes
-	if nil != err {
-		return nil, err
+	token.TokenID = tokenID
+
+	// read process status on exit
+	status, err := d.uint32(input[1:5])
+	if err != nil {
+		return token, err
 	}
-	if n != buflen-bufidx {
-		return nil, errors.New("read " + strconv.Itoa(n) + " bytes, but wanted exactly " + strconv.Itoa(buflen-bufidx))
+	token.Status = status
+
+	// read (signed) process return value
+	rval, err := d.int32(input[5:9])
+	if err != nil {
+		return token, err
 	}
+	token.ReturnValue = rval
 
-	// process the buffer
-	switch tokenBuffer[0] {
-	case 0x13: // trailer token
-		tmagic, err := bytesToUint16(tokenBuffer[1:3])
-		if err != nil {
-			return nil, err
-		}
-		bcount, err := bytesToUint32(tokenBuffer[3:6])
-		if err != nil {
-			return nil, err
-		}
-		return TrailerToken{
-			TokenID:         tokenBuffer[0],
-			TrailerMagic:    tmagic,
-			RecordByteCount: bcount,
-		}, nil
+	return token, nil
+}
 
-	case 0x14: // 32 bit header token
-		token, err := ParseHeaderToken32bit(tokenBuffer)
-		if err != nil {
-			return nil, err
-		}
-		return token, nil
-	case 0x23: // path token
-		token := PathToken{
-			TokenID: tokenBuffer[0],
+// ParseReturnToken64bit parses a ReturnToken64bit out of the given bytes.
+func ParseReturnToken64bit(input []byte) (ReturnToken64bit, error) {
+	return tokenDecoder{}.parseReturnToken64bit(input)
+}
+
+// parseReturnToken64bit parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseReturnToken64bit(input []byte) (ReturnToken64bit, error) {
+	token := ReturnToken64bit{}
+
+	// (static) length check
+	if len(input) < 10 {
+		return token, fmt.Errorf("%w: invalid length of 64 bit return token", ErrShortToken)
+	}
+	if len(input) != 10 {
+		return token, errors.New("invalid length of 64 bit return token")
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x72 {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read error number
+	token.ErrorNumber = input[1]
+
+	// read return value
+	rval, err := d.uint64(input[2:10])
+	if err != nil {
+		return token, err
+	}
+	token.ReturnValue = rval
+
+	return token, nil
+}
+
+// ParseIPortToken parses an IPortToken out of the given bytes.
+func ParseIPortToken(input []byte) (IPortToken, error) {
+	token := IPortToken{}
+
+	// (static) length check
+	if len(input) < 3 {
+		return token, fmt.Errorf("%w: invalid length of iport token", ErrShortToken)
+	}
+	if len(input) != 3 {
+		return token, errors.New("invalid length of iport token")
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x2c {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read port number (network byte order)
+	port, err := bytesToUint16(input[1:3])
+	if err != nil {
+		return token, err
+	}
+	token.PortNumber = port
+
+	return token, nil
+}
+
+// ParseExpandedInAddrTokenLibbsm parses an ExpandedInAddrToken out of the
+// given bytes as written by libbsm (4 byte address type, 4 or 16 byte
+// address).
+func ParseExpandedInAddrTokenLibbsm(input []byte) (ExpandedInAddrToken, error) {
+	return tokenDecoder{}.parseExpandedInAddrTokenLibbsm(input)
+}
+
+// parseExpandedInAddrTokenLibbsm parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseExpandedInAddrTokenLibbsm(input []byte) (ExpandedInAddrToken, error) {
+	token := ExpandedInAddrToken{}
+
+	// length check (token ID + address type)
+	if len(input) < 5 {
+		return token, fmt.Errorf("%w: invalid length of expanded in_addr token", ErrShortToken)
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x7e {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read address type (= length)
+	addrlen, err := d.uint32(input[1:5])
+	if err != nil {
+		return token, err
+	}
+	if addrlen != 4 && addrlen != 16 {
+		return token, fmt.Errorf("invalid address type %d of expanded in_addr token", addrlen)
+	}
+	token.IpAddressType = byte(addrlen)
+	if len(input) < 5+int(addrlen) {
+		return token, fmt.Errorf("%w: invalid length of expanded in_addr token", ErrShortToken)
+	}
+	if len(input) != 5+int(addrlen) {
+		return token, errors.New("invalid length of expanded in_addr token")
+	}
+
+	// read address
+	token.IpAddress = ipAddress(input[5 : 5+addrlen])
+
+	return token, nil
+}
+
+// ParseExpandedInAddrTokenManpage parses an ExpandedInAddrToken out of the
+// given bytes as documented in audit.log(5) (1 byte address type, 16 byte
+// address). IPv4 addresses are taken from the first 4 address bytes.
+func ParseExpandedInAddrTokenManpage(input []byte) (ExpandedInAddrToken, error) {
+	token := ExpandedInAddrToken{}
+
+	// (static) length check
+	if len(input) < 18 {
+		return token, fmt.Errorf("%w: invalid length of expanded in_addr token", ErrShortToken)
+	}
+	if len(input) != 18 {
+		return token, errors.New("invalid length of expanded in_addr token")
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x7e {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read address type (= length) and address
+	token.IpAddressType = input[1]
+	switch token.IpAddressType {
+	case 4:
+		token.IpAddress = ipAddress(input[2:6])
+	case 16:
+		token.IpAddress = ipAddress(input[2:18])
+	default:
+		return token, fmt.Errorf("invalid address type %d of expanded in_addr token", token.IpAddressType)
+	}
+
+	return token, nil
+}
+
+// inet6Families holds the AF_INET6 values of all platforms, so IPv6
+// families are recognized regardless of SocketFamilyPlatform.
+var inet6Families = map[uint16]bool{
+	26: true, // Solaris (BSM_PF_INET6 of OpenBSM)
+	28: true, // FreeBSD
+	30: true, // Darwin
+}
+
+// ParseSocketToken parses a SocketToken out of the given bytes.
+// IPv6 socket families result in an error wrapping ErrSocketFamily.
+func ParseSocketToken(input []byte) (SocketToken, error) {
+	return tokenDecoder{}.parseSocketToken(input)
+}
+
+// parseSocketToken parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseSocketToken(input []byte) (SocketToken, error) {
+	token := SocketToken{}
+
+	// (static) length check
+	if len(input) < 9 {
+		return token, fmt.Errorf("%w: invalid length of socket token", ErrShortToken)
+	}
+	if len(input) != 9 {
+		return token, errors.New("invalid length of socket token")
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x2e {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read socket family
+	data16, err := d.uint16(input[1:3])
+	if err != nil {
+		return token, err
+	}
+	token.SocketFamily = data16
+	if inet6Families[data16] {
+		return token, fmt.Errorf("%w: IPv6 family %d in socket token (0x2e holds IPv4 addresses only, IPv6 sockets are written as inet128 socket token 0x81)", ErrSocketFamily, data16)
+	}
+
+	// read local port
+	data16, err = bytesToUint16(input[3:5])
+	if err != nil {
+		return token, err
+	}
+	token.LocalPort = data16
+
+	// read socket address
+	token.SocketAddress = ipAddress(input[5:9])
+
+	return token, nil
+}
+
+// ParseSocketInet32Token parses a SocketInet32Token out of the given bytes.
+func ParseSocketInet32Token(input []byte) (SocketInet32Token, error) {
+	return tokenDecoder{}.parseSocketInet32Token(input)
+}
+
+// parseSocketInet32Token parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseSocketInet32Token(input []byte) (SocketInet32Token, error) {
+	token := SocketInet32Token{}
+
+	// (static) length check
+	if len(input) < 9 {
+		return token, fmt.Errorf("%w: invalid length of inet32 socket token", ErrShortToken)
+	}
+	if len(input) != 9 {
+		return token, errors.New("invalid length of inet32 socket token")
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x80 {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read socket family
+	data16, err := d.uint16(input[1:3])
+	if err != nil {
+		return token, err
+	}
+	token.SocketFamily = data16
+
+	// read local port
+	data16, err = bytesToUint16(input[3:5])
+	if err != nil {
+		return token, err
+	}
+	token.LocalPort = data16
+
+	// read IPv4 address
+	token.SocketAddress = ipAddress(input[5:9])
+
+	return token, nil
+}
+
+// ParseSocketInet128Token parses a SocketInet128Token out of the given bytes.
+func ParseSocketInet128Token(input []byte) (SocketInet128Token, error) {
+	return tokenDecoder{}.parseSocketInet128Token(input)
+}
+
+// parseSocketInet128Token parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseSocketInet128Token(input []byte) (SocketInet128Token, error) {
+	token := SocketInet128Token{}
+
+	// (static) length check
+	if len(input) < 21 {
+		return token, fmt.Errorf("%w: invalid length of inet128 socket token", ErrShortToken)
+	}
+	if len(input) != 21 {
+		return token, errors.New("invalid length of inet128 socket token")
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x81 {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read socket family
+	data16, err := d.uint16(input[1:3])
+	if err != nil {
+		return token, err
+	}
+	token.SocketFamily = data16
+
+	// read local port
+	data16, err = bytesToUint16(input[3:5])
+	if err != nil {
+		return token, err
+	}
+	token.LocalPort = data16
+
+	// read IPv6 address
+	token.SocketAddress = ipAddress(input[5:21])
+
+	return token, nil
+}
+
+// ParseSocketUnixToken parses a SocketUnixToken out of the given bytes.
+func ParseSocketUnixToken(input []byte) (SocketUnixToken, error) {
+	return tokenDecoder{}.parseSocketUnixToken(input)
+}
+
+// parseSocketUnixToken parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseSocketUnixToken(input []byte) (SocketUnixToken, error) {
+	token := SocketUnixToken{}
+
+	// length check (token ID + socket family + NUL)
+	if len(input) < 4 {
+		return token, fmt.Errorf("%w: invalid length of unix socket token", ErrShortToken)
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x82 {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read socket family
+	data16, err := d.uint16(input[1:3])
+	if err != nil {
+		return token, err
+	}
+	token.SocketFamily = data16
+
+	// read socket path and drop the terminating NUL
+	if input[len(input)-1] != 0x00 {
+		return token, errors.New("socket path of unix socket token is not NUL-terminated")
+	}
+	token.Path = string(input[3 : len(input)-1])
+
+	return token, nil
+}
+
+// ParseSystemVIpcPermissionToken parses a SystemVIpcPermissionToken out of the given bytes.
+func ParseSystemVIpcPermissionToken(input []byte) (SystemVIpcPermissionToken, error) {
+	return tokenDecoder{}.parseSystemVIpcPermissionToken(input)
+}
+
+// parseSystemVIpcPermissionToken parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseSystemVIpcPermissionToken(input []byte) (SystemVIpcPermissionToken, error) {
+	ptr := 0
+	token := SystemVIpcPermissionToken{}
+
+	// (static) length check
+	if len(input) < 29 {
+		return token, fmt.Errorf("%w: invalid length of System V IPC permission token", ErrShortToken)
+	}
+	if len(input) != 29 {
+		return token, errors.New("invalid length of System V IPC permission token")
+	}
+
+	// read token ID
+	tokenID := input[ptr]
+	if tokenID != 0x32 {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+	ptr += 1
+
+	// read user ID of IPC owner
+	data32, err := d.uint32(input[ptr : ptr+4])
+	if err != nil {
+		return token, err
+	}
+	token.OwnerUserID = data32
+	ptr += 4
+
+	// read group ID of IPC owner
+	data32, err = d.uint32(input[ptr : ptr+4])
+	if err != nil {
+		return token, err
+	}
+	token.OwnerGroupID = data32
+	ptr += 4
+
+	// read user ID of IPC creator
+	data32, err = d.uint32(input[ptr : ptr+4])
+	if err != nil {
+		return token, err
+	}
+	token.CreatorUserID = data32
+	ptr += 4
+
+	// read group ID of IPC creator
+	data32, err = d.uint32(input[ptr : ptr+4])
+	if err != nil {
+		return token, err
+	}
+	token.CreatorGroupID = data32
+	ptr += 4
+
+	// read access mode
+	data32, err = d.uint32(input[ptr : ptr+4])
+	if err != nil {
+		return token, err
+	}
+	token.AccessMode = data32
+	ptr += 4
+
+	// read sequence number
+	data32, err = d.uint32(input[ptr : ptr+4])
+	if err != nil {
+		return token, err
+	}
+	token.SequenceNumber = data32
+	ptr += 4
+
+	// read IPC key
+	data32, err = d.uint32(input[ptr : ptr+4])
+	if err != nil {
+		return token, err
+	}
+	token.Key = data32
+
+	return token, nil
+}
+
+// ParseTextToken parses a TextToken out of the given bytes.
+func ParseTextToken(input []byte) (TextToken, error) {
+	return tokenDecoder{}.parseTextToken(input)
+}
+
+// parseTextToken parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseTextToken(input []byte) (TextToken, error) {
+	token := TextToken{}
+
+	// length check (token ID + length field)
+	if len(input) < 3 {
+		return token, fmt.Errorf("%w: invalid length of text token", ErrShortToken)
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x28 {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read text length (including NUL)
+	length, err := d.uint16(input[1:3])
+	if err != nil {
+		return token, err
+	}
+	token.TextLength = length
+	if len(input) < 3+int(length) {
+		return token, fmt.Errorf("%w: text length exceeds length of text token", ErrShortToken)
+	}
+	if len(input) != 3+int(length) {
+		return token, errors.New("text length does not match length of text token")
+	}
+	if length == 0 {
+		return token, nil
+	}
+
+	// read text and drop the terminating NUL
+	if input[len(input)-1] != 0x00 {
+		return token, errors.New("text of text token is not NUL-terminated")
+	}
+	token.Text = string(input[3 : len(input)-1])
+
+	return token, nil
+}
+
+// ParsePathToken parses a PathToken out of the given bytes.
+func ParsePathToken(input []byte) (PathToken, error) {
+	return tokenDecoder{}.parsePathToken(input)
+}
+
+// parsePathToken parses the token in the byte order of the decoder.
+func (d tokenDecoder) parsePathToken(input []byte) (PathToken, error) {
+	token := PathToken{}
+
+	// length check (token ID + length field)
+	if len(input) < 3 {
+		return token, fmt.Errorf("%w: invalid length of path token", ErrShortToken)
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x23 {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read path length (including NUL)
+	length, err := d.uint16(input[1:3])
+	if err != nil {
+		return token, err
+	}
+	token.PathLength = length
+	if len(input) < 3+int(length) {
+		return token, fmt.Errorf("%w: path length exceeds length of path token", ErrShortToken)
+	}
+	if len(input) != 3+int(length) {
+		return token, errors.New("path length does not match length of path token")
+	}
+	if length == 0 {
+		return token, nil
+	}
+
+	// read path and drop the terminating NUL
+	if input[len(input)-1] != 0x00 {
+		return token, errors.New("path of path token is not NUL-terminated")
+	}
+	token.Path = string(input[3 : len(input)-1])
+
+	return token, nil
+}
+
+// ParseZonenameToken parses a ZonenameToken out of the given bytes.
+func ParseZonenameToken(input []byte) (ZonenameToken, error) {
+	return tokenDecoder{}.parseZonenameToken(input)
+}
+
+// parseZonenameToken parses the token in the byte order of the decoder.
+func (d tokenDecoder) parseZonenameToken(input []byte) (ZonenameToken, error) {
+	token := ZonenameToken{}
+
+	// length check (token ID + length field)
+	if len(input) < 3 {
+		return token, fmt.Errorf("%w: invalid length of zonename token", ErrShortToken)
+	}
+
+	// read token ID
+	tokenID := input[0]
+	if tokenID != 0x60 {
+		return token, errors.New("token ID mismatch")
+	}
+	token.TokenID = tokenID
+
+	// read zonename length (including NUL)
+	length, err := d.uint16(input[1:3])
+	if err != nil {
+		return token, err
+	}
+	token.ZonenameLength = length
+	if len(input) < 3+int(length) {
+		return token, fmt.Errorf("%w: zonename length exceeds length of zonename token", ErrShortToken)
+	}
+	if len(input) != 3+int(length) {
+		return token, errors.New("zonename length does not match length of zonename token")
+	}
+	if length == 0 {
+		return token, nil
+	}
+
+	// read zonename and drop the terminating NUL
+	if input[len(input)-1] != 0x00 {
+		return token, errors.New("zonename of zonename token is not NUL-terminated")
+	}
+	token.Zonename = string(input[3 : len(input)-1])
+
+	return token, nil
+}
+
+// readBytes reads exactly count bytes from the given input and appends
+// them to the buffer. Partial reads (e.g. on pipes) are retried until
+// enough bytes arrived. Running out of bytes at the very beginning of
+// a token yields io.EOF, running out of bytes within a token yields
+// io.ErrUnexpectedEOF. Spare capacity of the buffer is reused.
+func readBytes(input io.Reader, buffer []byte, count int) ([]byte, error) {
+	length := len(buffer)
+	if cap(buffer)-length < count {
+		grown := make([]byte, length, 2*length+count)
+		copy(grown, buffer)
+		buffer = grown
+	}
+	buffer = buffer[:length+count]
+	_, err := io.ReadFull(input, buffer[length:])
+	if err == io.EOF && length != 0 {
+		err = io.ErrUnexpectedEOF // token started already
+	}
+	if nil != err {
+		return buffer[:length], err
+	}
+	return buffer, nil
+}
+
+// RecordsFromByteInput yields a generator for all records contained
+// in the given byte input. This input has to support the Reader interface
+// and may be a file or a device.
+
+// TokenFromByteInput converts bytes read from a given input
+// to a BSM token. It returns io.EOF if the input is exhausted before
+// a token starts and io.ErrUnexpectedEOF if it is exhausted within
+// a token.
+func TokenFromByteInput(input io.Reader) (Token, error) {
+	tokenBuffer, err := tokenDecoder{}.readTokenBytes(input, nil)
+	if nil != err {
+		return nil, err
+	}
+	return tokenDecoder{}.parseTokenBuffer(tokenBuffer)
+}
+
+// ParseToken parses the token at the beginning of the given bytes and
+// returns it together with the number of bytes it occupies. Bytes after
+// the token are ignored, so the bytes of a complete record can be parsed
+// token by token without an io.Reader.
+func ParseToken(input []byte) (Token, int, error) {
+	size, err := tokenDecoder{}.sliceTokenSize(input)
+	if err != nil {
+		return nil, 0, err
+	}
+	token, err := tokenDecoder{}.parseTokenBuffer(input[:size])
+	if err != nil {
+		return nil, 0, err
+	}
+	return token, size, nil
+}
+
+// sliceTokenSize determines the size of the token at the beginning of
+// the given bytes. The token has to be complete.
+func (d tokenDecoder) sliceTokenSize(input []byte) (int, error) {
+	d.scan = &stringsScan{}
+	available := 0
+	size, increase, err := d.determineTokenSize(input[:available])
+	for err == nil && increase > 0 {
+		available += increase
+		if available > len(input) {
+			return 0, fmt.Errorf("%w: %d bytes available", ErrShortToken, len(input))
+		}
+		size, increase, err = d.determineTokenSize(input[:available])
+	}
+	if err != nil {
+		return 0, err
+	}
+	if size > len(input) {
+		return 0, fmt.Errorf("%w: token 0x%x needs %d bytes, %d available", ErrShortToken, input[0], size, len(input))
+	}
+	return size, nil
+}
+
+// readTokenBytes reads all bytes of the next token from the given input.
+// The capacity of the given buffer is reused (its content is discarded).
+func (d tokenDecoder) readTokenBytes(input io.Reader, buffer []byte) ([]byte, error) {
+	tokenBuffer, size, err := d.readTokenHead(input, buffer)
+	if nil != err {
+		return tokenBuffer, err
+	}
+
+	// read all the (remaining) bytes we need
+	if size > len(tokenBuffer) {
+		tokenBuffer, err = readBytes(input, tokenBuffer, size-len(tokenBuffer))
+	}
+	return tokenBuffer, err
+}
+
+// readTokenHead reads the leading bytes of the next token (into the
+// given buffer) until the size of the token can be determined.
+func (d tokenDecoder) readTokenHead(input io.Reader, buffer []byte) ([]byte, int, error) {
+	d.scan = &stringsScan{}
+	tokenBuffer := buffer[:0]
+	size, increase, err := d.determineTokenSize(tokenBuffer)
+	if nil != err {
+		return tokenBuffer, size, err
+	}
+	for increase > 0 {
+		tokenBuffer, err = readBytes(input, tokenBuffer, increase)
+		if nil != err {
+			return tokenBuffer, size, err
+		}
+		size, increase, err = d.determineTokenSize(tokenBuffer)
+		if nil != err {
+			return tokenBuffer, size, err
+		}
+	}
+	return tokenBuffer, size, nil
+}
+
+// parseTokenBuffer converts the bytes of a complete token into a BSM
+// token. The resulting token does not refer to the given bytes.
+func (d tokenDecoder) parseTokenBuffer(tokenBuffer []byte) (Token, error) {
+	switch tokenBuffer[0] {
+	case 0x11: // file token
+		token, err := d.parseFileToken(tokenBuffer)
+		if err != nil {
+			return nil, err
+		}
+		return token, nil
+
+	case 0x13: // trailer token
+		token, err := d.parseTrailerToken(tokenBuffer)
+		if errors.Is(err, ErrBadTrailerMagic) && !StrictTrailer {
+			// magic is checked again when the record is complete
+			token.RecordByteCount, err = d.uint32(tokenBuffer[3:7])
 		}
-		length, err := bytesToUint16(tokenBuffer[1:3])
 		if err != nil {
 			return nil, err
 		}
-		token.PathLength = length
-		token.Path = string(tokenBuffer[3 : length+2])
+		return token, nil
+
+	case 0x14: // 32 bit header token
+		token, err := d.parseHeaderToken32bit(tokenBuffer)
+		if err != nil {
+			return nil, err
+		}
+		return token, nil
+	case 0x23: // path token
+		token, err := d.parsePathToken(tokenBuffer)
+		if err != nil {
+			return nil, err
+		}
 		return token, nil
 
 	case 0x24: // 32 bit subject token
 		token := SubjectToken32bit{
 			TokenID: tokenBuffer[0],
 		}
-		val, err := bytesToUint32(tokenBuffer[1:5])
+		val, err := d.uint32(tokenBuffer[1:5])
 		if err != nil {
 			return nil, err
 		}
 		token.AuditID = val
 
-		val, err = bytesToUint32(tokenBuffer[5:9])
+		val, err = d.uint32(tokenBuffer[5:9])
 		if err != nil {
 			return nil, err
 		}
 		token.EffectiveUserID = val
 
-		val, err = bytesToUint32(tokenBuffer[9:13])
+		val, err = d.uint32(tokenBuffer[9:13])
 		if err != nil {
 			return nil, err
 		}
 		token.EffectiveGroupID = val
 
-		val, err = bytesToUint32(tokenBuffer[13:17])
+		val, err = d.uint32(tokenBuffer[13:17])
 		if err != nil {
 			return nil, err
 		}
 		token.RealUserID = val
 
-		val, err = bytesToUint32(tokenBuffer[17:21])
+		val, err = d.uint32(tokenBuffer[17:21])
 		if err != nil {
 			return nil, err
 		}
 		token.RealGroupID = val
 
-		val, err = bytesToUint32(tokenBuffer[21:25])
+		val, err = d.uint32(tokenBuffer[21:25])
 		if err != nil {
 			return nil, err
 		}
 		token.ProcessID = val
 
-		val, err = bytesToUint32(tokenBuffer[25:29])
+		val, err = d.uint32(tokenBuffer[25:29])
 		if err != nil {
 			return nil, err
 		}
 		token.SessionID = val
 
-		val, err = bytesToUint32(tokenBuffer[29:33])
+		val, err = d.uint32(tokenBuffer[29:33])
 		if err != nil {
 			return nil, err
 		}
 		token.TerminalPortID = val
 
-		token.TerminalMachineAddress = net.IPv4(
-			tokenBuffer[33],
-			tokenBuffer[34],
-			tokenBuffer[35],
-			tokenBuffer[36])
+		token.TerminalMachineAddress = ipAddress(tokenBuffer[33:37])
 		return token, nil
 
 	case 0x27: // 32 bit return token
-		rval, err := bytesToUint32(tokenBuffer[2:6])
+		rval, err := d.uint32(tokenBuffer[2:6])
 		if err != nil {
 			return nil, err
 		}
@@ -1108,94 +2252,101 @@ func TokenFromByteInput(input io.Reader) (empty, error) {
 		}, nil
 
 	case 0x28: // text token
-		length, err := bytesToUint16(tokenBuffer[1:3])
+		token, err := d.parseTextToken(tokenBuffer)
 		if err != nil {
 			return nil, err
 		}
-		return TextToken{
-			TokenID:    tokenBuffer[0],
-			TextLength: length,
-			Text:       string(tokenBuffer[3 : length+2]), // 3 bytes inital offset - 1 NUL byte = 2 bytes
-		}, nil
+		return token, nil
 
 	case 0x2c: // iport token
-		port, err := bytesToUint16(tokenBuffer[1:3])
+		token, err := ParseIPortToken(tokenBuffer)
 		if err != nil {
 			return nil, err
 		}
-		return IPortToken{TokenID: tokenBuffer[0],
-			PortNumber: port,
-		}, nil
+		return token, nil
 
 	case 0x2d: // 32bit arg_token
 		token := ArgToken32bit{
 			TokenID:    tokenBuffer[0],
 			ArgumentID: tokenBuffer[1],
 		}
-		val, err := bytesToUint32(tokenBuffer[2:6])
+		val, err := d.uint32(tokenBuffer[2:6])
 		if err != nil {
 			return nil, err
 		}
 		token.ArgumentValue = val
-		length, err := bytesToUint16(tokenBuffer[6:8])
+		length, err := d.uint16(tokenBuffer[6:8])
 		if err != nil {
 			return nil, err
 		}
 		token.Length = length
-		token.Text = string(tokenBuffer[8 : length+7])
+		if len(tokenBuffer) < 8+int(length) {
+			return nil, fmt.Errorf("%w: arg text needs %d bytes, got %d", ErrShortToken, length, len(tokenBuffer)-8)
+		}
+		// length includes the terminating NUL
+		if length > 0 {
+			token.Text = string(tokenBuffer[8 : 8+int(length)-1])
+		}
 		return token, nil
 
-	case 0x2e: // socket soken
-		token := SocketToken{
-			TokenID: tokenBuffer[0],
+	case 0x2e: // socket token
+		token, err := d.parseSocketToken(tokenBuffer)
+		if err != nil {
+			return nil, err
 		}
-		val, err := bytesToUint16(tokenBuffer[1:3])
+		return token, nil
+
+	case 0x32: // System V IPC permission token
+		token, err := d.parseSystemVIpcPermissionToken(tokenBuffer)
 		if err != nil {
 			return nil, err
 		}
-		token.SocketFamily = val
-		val, err = bytesToUint16(tokenBuffer[3:5])
+		return token, nil
+
+	case 0x3c: // exec args token
+		token, err := d.parseExecArgsToken(tokenBuffer)
+		if err != nil {
+			return nil, err
+		}
+		return token, nil
+
+	case 0x3d: // exec env token
+		token, err := d.parseExecEnvToken(tokenBuffer)
 		if err != nil {
 			return nil, err
 		}
-		token.LocalPort = val
-		token.SocketAddress = net.IPv4(
-			tokenBuffer[5],
-			tokenBuffer[6],
-			tokenBuffer[7],
-			tokenBuffer[8])
 		return token, nil
 
 	case 0x3e: // 32bit attribute token
 		token := AttributeToken32bit{
 			TokenID: tokenBuffer[0],
 		}
-		val, err := bytesToUint32(tokenBuffer[1:5])
+		val, err := d.uint32(tokenBuffer[1:5])
 		if err != nil {
 			return nil, err
 		}
 		token.FileAccessMode = val
-		val, err = bytesToUint32(tokenBuffer[5:9])
+		val, err = d.uint32(tokenBuffer[5:9])
 		if err != nil {
 			return nil, err
 		}
 		token.OwnerUserID = val
-		val, err = bytesToUint32(tokenBuffer[9:13])
+		val, err = d.uint32(tokenBuffer[9:13])
 		if err != nil {
 			return nil, err
 		}
 		token.OwnerGroupID = val
-		val, err = bytesToUint32(tokenBuffer[13:17])
+		val, err = d.uint32(tokenBuffer[13:17])
 		if err != nil {
 			return nil, err
 		}
 		token.FileSystemID = val
-		fsval, err := bytesToUint64(tokenBuffer[17:25])
+		fsval, err := d.uint64(tokenBuffer[17:25])
 		if err != nil {
 			return nil, err
 		}
 		token.FileSystemNodeID = fsval
-		val, err = bytesToUint32(tokenBuffer[25:29])
+		val, err = d.uint32(tokenBuffer[25:29])
 		if err != nil {
 			return nil, err
 		}
@@ -1203,60 +2354,56 @@ func TokenFromByteInput(input io.Reader) (empty, error) {
 		return token, nil
 
 	case 0x52: // exit token
-		token := ExitToken{
-			TokenID: tokenBuffer[0],
-		}
-		stat, err := bytesToUint32(tokenBuffer[1:3])
+		token, err := d.parseExitToken(tokenBuffer)
 		if err != nil {
 			return nil, err
 		}
-		token.Status = stat
-		rval, _ := binary.Varint(tokenBuffer[3:7])
-		token.ReturnValue = int32(rval)
 		return token, nil
 
 	case 0x60: // zonename token
-		token := ZonenameToken{
-			TokenID: tokenBuffer[0],
+		token, err := d.parseZonenameToken(tokenBuffer)
+		if err != nil {
+			return nil, err
 		}
-		length, err := bytesToUint16(tokenBuffer[1:3])
+		return token, nil
+
+	case 0x72: // 64 bit return token
+		token, err := d.parseReturnToken64bit(tokenBuffer)
 		if err != nil {
 			return nil, err
 		}
-		token.ZonenameLength = length
-		token.Zonename = string(tokenBuffer[3 : length+2])
 		return token, nil
 
 	case 0x73: // 64 bit attribute token
 		token := AttributeToken64bit{
 			TokenID: tokenBuffer[0],
 		}
-		val, err := bytesToUint32(tokenBuffer[1:5])
+		val, err := d.uint32(tokenBuffer[1:5])
 		if err != nil {
 			return nil, err
 		}
 		token.FileAccessMode = val
-		val, err = bytesToUint32(tokenBuffer[5:9])
+		val, err = d.uint32(tokenBuffer[5:9])
 		if err != nil {
 			return nil, err
 		}
 		token.OwnerUserID = val
-		val, err = bytesToUint32(tokenBuffer[9:13])
+		val, err = d.uint32(tokenBuffer[9:13])
 		if err != nil {
 			return nil, err
 		}
 		token.OwnerGroupID = val
-		val, err = bytesToUint32(tokenBuffer[13:17])
+		val, err = d.uint32(tokenBuffer[13:17])
 		if err != nil {
 			return nil, err
 		}
 		token.FileSystemID = val
-		bval, err := bytesToUint64(tokenBuffer[17:25])
+		bval, err := d.uint64(tokenBuffer[17:25])
 		if err != nil {
 			return nil, err
 		}
 		token.FileSystemNodeID = bval
-		bval, err = bytesToUint64(tokenBuffer[25:33])
+		bval, err = d.uint64(tokenBuffer[25:33])
 		if err != nil {
 			return nil, err
 		}
@@ -1267,55 +2414,96 @@ func TokenFromByteInput(input io.Reader) (empty, error) {
 		token := ExpandedSubjectToken32bit{
 			TokenID: tokenBuffer[0],
 		}
-		val, err := bytesToUint32(tokenBuffer[1:5])
+		val, err := d.uint32(tokenBuffer[1:5])
 		if err != nil {
 			return nil, err
 		}
 		token.AuditID = val
 
-		val, err = bytesToUint32(tokenBuffer[5:9])
+		val, err = d.uint32(tokenBuffer[5:9])
 		if err != nil {
 			return nil, err
 		}
 		token.EffectiveUserID = val
 
-		val, err = bytesToUint32(tokenBuffer[9:13])
+		val, err = d.uint32(tokenBuffer[9:13])
 		if err != nil {
 			return nil, err
 		}
 		token.EffectiveGroupID = val
 
-		val, err = bytesToUint32(tokenBuffer[13:17])
+		val, err = d.uint32(tokenBuffer[13:17])
 		if err != nil {
 			return nil, err
 		}
 		token.RealUserID = val
 
-		val, err = bytesToUint32(tokenBuffer[17:21])
+		val, err = d.uint32(tokenBuffer[17:21])
 		if err != nil {
 			return nil, err
 		}
 		token.RealGroupID = val
 
-		val, err = bytesToUint32(tokenBuffer[21:25])
+		val, err = d.uint32(tokenBuffer[21:25])
 		if err != nil {
 			return nil, err
 		}
 		token.ProcessID = val
 
-		val, err = bytesToUint32(tokenBuffer[25:29])
+		val, err = d.uint32(tokenBuffer[25:29])
 		if err != nil {
 			return nil, err
 		}
 		token.SessionID = val
 
-		val, err = bytesToUint32(tokenBuffer[29:33])
+		val, err = d.uint32(tokenBuffer[29:33])
 		if err != nil {
 			return nil, err
 		}
 		token.TerminalPortID = val
 
-		val, err = bytesToUint32(tokenBuffer[33:37])
+		val, err = d.uint32(tokenBuffer[33:37])
+		if err != nil {
+			return nil, err
+		}
+		token.TerminalAddressLength = val
+
+		switch val {
+		case 4:
+			token.TerminalMachineAddress = ipAddress(tokenBuffer[37:41])
+		case 16:
+			token.TerminalMachineAddress = ipAddress(tokenBuffer[37:53])
+		default:
+			return nil, errors.New("can't process length of terminal machine address")
+		}
+		if StrictAddresses {
+			if err := checkAddress(tokenBuffer[37 : 37+val]); err != nil {
+				return nil, err
+			}
+		}
+		return token, nil
+
+	case 0x7c: // expanded 64bit subject token
+		token := ExpandedSubjectToken64bit{
+			TokenID: tokenBuffer[0],
+		}
+		ids := []*uint32{&token.AuditID, &token.EffectiveUserID, &token.EffectiveGroupID,
+			&token.RealUserID, &token.RealGroupID, &token.ProcessID, &token.SessionID}
+		for i, id := range ids {
+			val, err := d.uint32(tokenBuffer[1+4*i : 5+4*i])
+			if err != nil {
+				return nil, err
+			}
+			*id = val
+		}
+
+		port, err := d.uint64(tokenBuffer[29:37])
+		if err != nil {
+			return nil, err
+		}
+		token.TerminalPortID = port
+
+		val, err := d.uint32(tokenBuffer[37:41])
 		if err != nil {
 			return nil, err
 		}
@@ -1323,71 +2511,72 @@ func TokenFromByteInput(input io.Reader) (empty, error) {
 
 		switch val {
 		case 4:
-			token.TerminalMachineAddress = net.IPv4(
-				tokenBuffer[37],
-				tokenBuffer[38],
-				tokenBuffer[39],
-				tokenBuffer[40])
+			token.TerminalMachineAddress = ipAddress(tokenBuffer[41:45])
 		case 16:
-			token.TerminalMachineAddress = tokenBuffer[37:53]
+			token.TerminalMachineAddress = ipAddress(tokenBuffer[41:57])
 		default:
 			return nil, errors.New("can't process length of terminal machine address")
 		}
+		if StrictAddresses {
+			if err := checkAddress(tokenBuffer[41 : 41+val]); err != nil {
+				return nil, err
+			}
+		}
 		return token, nil
 
 	case 0x7b: // 32bit expanded process token
 		token := ExpandedProcessToken32bit{
 			TokenID: tokenBuffer[0],
 		}
-		val, err := bytesToUint32(tokenBuffer[1:5])
+		val, err := d.uint32(tokenBuffer[1:5])
 		if err != nil {
 			return nil, err
 		}
 		token.AuditID = val
 
-		val, err = bytesToUint32(tokenBuffer[5:9])
+		val, err = d.uint32(tokenBuffer[5:9])
 		if err != nil {
 			return nil, err
 		}
 		token.EffectiveUserID = val
 
-		val, err = bytesToUint32(tokenBuffer[9:13])
+		val, err = d.uint32(tokenBuffer[9:13])
 		if err != nil {
 			return nil, err
 		}
 		token.EffectiveGroupID = val
 
-		val, err = bytesToUint32(tokenBuffer[13:17])
+		val, err = d.uint32(tokenBuffer[13:17])
 		if err != nil {
 			return nil, err
 		}
 		token.RealUserID = val
 
-		val, err = bytesToUint32(tokenBuffer[17:21])
+		val, err = d.uint32(tokenBuffer[17:21])
 		if err != nil {
 			return nil, err
 		}
 		token.RealGroupID = val
 
-		val, err = bytesToUint32(tokenBuffer[21:25])
+		val, err = d.uint32(tokenBuffer[21:25])
 		if err != nil {
 			return nil, err
 		}
 		token.ProcessID = val
 
-		val, err = bytesToUint32(tokenBuffer[25:29])
+		val, err = d.uint32(tokenBuffer[25:29])
 		if err != nil {
 			return nil, err
 		}
 		token.SessionID = val
 
-		val, err = bytesToUint32(tokenBuffer[29:33])
+		val, err = d.uint32(tokenBuffer[29:33])
 		if err != nil {
 			return nil, err
 		}
 		token.TerminalPortID = val
 
-		val, err = bytesToUint32(tokenBuffer[33:37])
+		val, err = d.uint32(tokenBuffer[33:37])
 		if err != nil {
 			return nil, err
 		}
@@ -1395,170 +2584,401 @@ func TokenFromByteInput(input io.Reader) (empty, error) {
 
 		switch token.TerminalAddressLength {
 		case 4:
-			token.TerminalMachineAddress = net.IPv4(
-				tokenBuffer[37],
-				tokenBuffer[38],
-				tokenBuffer[39],
-				tokenBuffer[40],
-			)
+			token.TerminalMachineAddress = ipAddress(tokenBuffer[37:41])
 		case 16:
-			token.TerminalMachineAddress = tokenBuffer[37:53]
+			token.TerminalMachineAddress = ipAddress(tokenBuffer[37:53])
 		default:
 			return nil, errors.New("invalid value for address length in 32bit expanded process token")
 		}
+		if StrictAddresses {
+			if err := checkAddress(tokenBuffer[37 : 37+token.TerminalAddressLength]); err != nil {
+				return nil, err
+			}
+		}
 		return token, nil
 
-	case 0x80: // inet32 socket soken
-		token := SocketToken{
-			TokenID: tokenBuffer[0],
-		}
-		val, err := bytesToUint16(tokenBuffer[1:3])
-		if err != nil {
-			return nil, err
+	case 0x7e: // expanded in_addr token
+		if ExpandedInAddrLayout == ManpageLayout {
+			token, err := ParseExpandedInAddrTokenManpage(tokenBuffer)
+			if err != nil {
+				return nil, err
+			}
+			return token, nil
 		}
-		token.SocketFamily = val
-		val, err = bytesToUint16(tokenBuffer[3:5])
+		token, err := d.parseExpandedInAddrTokenLibbsm(tokenBuffer)
 		if err != nil {
 			return nil, err
 		}
-		token.LocalPort = val
-		token.SocketAddress = net.IPv4(
-			tokenBuffer[5],
-			tokenBuffer[6],
-			tokenBuffer[7],
-			tokenBuffer[8])
 		return token, nil
 
-	case 0x81: // inet128 socket soken
-		token := SocketToken{
-			TokenID: tokenBuffer[0],
-		}
-		val, err := bytesToUint16(tokenBuffer[1:3])
+	case 0x80: // inet32 socket token
+		token, err := d.parseSocketInet32Token(tokenBuffer)
 		if err != nil {
 			return nil, err
 		}
-		token.SocketFamily = val
-		val, err = bytesToUint16(tokenBuffer[3:5])
-		if err != nil {
-			return nil, err
-		}
-		token.LocalPort = val
-		token.SocketAddress = tokenBuffer[5:21]
 		return token, nil
 
-	case 0x82: // FreeBSD socket token
-		token := SocketToken{
-			TokenID: tokenBuffer[0],
-		}
-		val, err := bytesToUint16(tokenBuffer[1:3])
+	case 0x81: // inet128 socket token
+		token, err := d.parseSocketInet128Token(tokenBuffer)
 		if err != nil {
 			return nil, err
 		}
-		token.SocketFamily = val
-		val, err = bytesToUint16(tokenBuffer[3:5])
+		return token, nil
+
+	case 0x82: // unix socket token
+		token, err := d.parseSocketUnixToken(tokenBuffer)
 		if err != nil {
 			return nil, err
 		}
-		token.LocalPort = val
-
-		token.SocketAddress = net.IPv4(
-			tokenBuffer[5],
-			tokenBuffer[6],
-			tokenBuffer[7],
-			tokenBuffer[8],
-		)
 		return token, nil
 
 	default:
-		return nil, fmt.Errorf("new token ID found: 0x%x", tokenBuffer[0])
+		if d.lenient {
+			return RawToken{
+				TokenID: tokenBuffer[0],
+				Raw:     append([]byte(nil), tokenBuffer...),
+			}, nil
+		}
+		return nil, fmt.Errorf("%w: new token ID found: 0x%x", ErrUnknownTokenID, tokenBuffer[0])
 	}
-	return nil, nil
 }
 
-// BsmRecord represents a BSM record.
+// BsmRecord represents a BSM record. A record starts with a header token
+// and ends with a trailer token, everything in between is kept in Tokens.
 type BsmRecord struct {
-	Seconds     uint64  // record time stamp (8 bytes)
-	NanoSeconds uint64  // record time stamp (8 bytes)
-	Tokens      []empty // generic list of all tokens
+	Seconds     uint64       // record time stamp (8 bytes)
+	NanoSeconds uint64       // record time stamp (8 bytes)
+	Header      Token        // header token (32/64 bit, expanded or not)
+	Tokens      []Token      // generic list of all tokens between header and trailer
+	Trailer     TrailerToken // trailer token
+}
+
+// EventType returns the event type of the header token (regardless
+// of its variant). False is returned if the record has no header.
+func (r BsmRecord) EventType() (uint16, bool) {
+	switch header := r.Header.(type) {
+	case HeaderToken32bit:
+		return header.EventType, true
+	case HeaderToken64bit:
+		return header.EventType, true
+	case ExpandedHeaderToken32bit:
+		return header.EventType, true
+	case ExpandedHeaderToken64bit:
+		return header.EventType, true
+	}
+	return 0, false
 }
 
 // ParsingResult encapsulates the result of the parsing
 // process to be used in conjunction with channels.
 type ParsingResult struct {
-	Record BsmRecord
-	Error  error
+	Record   BsmRecord
+	Boundary *FileToken // set (instead of Record) for file tokens between records
+	Error    error
+}
+
+// FileBoundary is returned by ReadBsmRecord if a file token (0x11) is
+// found instead of a record. File tokens mark the beginning and end of
+// the original trails within concatenated audit trails. Reading can
+// continue with the next record.
+type FileBoundary struct {
+	File FileToken
+}
+
+func (b *FileBoundary) Error() string {
+	return "file boundary: " + b.File.String()
+}
+
+// ParseError describes a token which could not be parsed. The offset
+// is relative to the beginning of the record (ReadBsmRecord) or the
+// stream (RecordGenerator).
+type ParseError struct {
+	Offset  int64 // offset of the first byte of the token
+	TokenID byte  // ID of the offending token
+	Err     error // underlying error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("token 0x%x at offset 0x%x: %s", e.TokenID, e.Offset, e.Err.Error())
+}
+
+// Unwrap returns the underlying error (e.g. ErrUnknownTokenID).
+func (e *ParseError) Unwrap() error {
+	return e.Err
 }
 
-// ReadBsmRecord read a complete BSM record from the given byte source.
-// TODO: support potential file token at the beginning of a stream
-// TODO: check record size for consistency
+// ReadBsmRecord reads a complete BSM record from the given byte source.
+// The record has to start with a header token and is read up to (and
+// including) the next trailer token. The byte count of the trailer has
+// to match the one of the header. If the input is exhausted before a
+// header token could be read, io.EOF is returned. If it is exhausted
+// within a record, io.ErrUnexpectedEOF is returned. Tokens which can't
+// be parsed are reported as *ParseError. A file token in place of a
+// header is reported as *FileBoundary.
 func ReadBsmRecord(input io.Reader) (BsmRecord, error) {
+	return readBsmRecord(input, 0, tokenDecoder{})
+}
+
+// readBsmRecord reads a complete BSM record (see ReadBsmRecord) starting
+// at the given offset of the stream, decoding its tokens with d.
+func readBsmRecord(input io.Reader, offset int64, d tokenDecoder) (BsmRecord, error) {
+	counter := &countingReader{reader: input} // keep track of bytes consumed
+	buffer := []byte{}
+	readToken := func() (Token, error) {
+		start := offset + counter.count
+		var err error
+		buffer, err = d.readTokenBytes(counter, buffer)
+		if err == nil {
+			token, perr := d.parseTokenBuffer(buffer)
+			if perr == nil {
+				return token, nil
+			}
+			err = perr
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF || 0 == len(buffer) {
+			return nil, err
+		}
+		return nil, &ParseError{Offset: start, TokenID: buffer[0], Err: err}
+	}
+	// push back the last token (see StrictTrailer)
+	unreadToken := func() error {
+		if pushback, ok := input.(*countingReader); ok { // RecordGenerator
+			pushback.unread(buffer)
+		} else if seeker, ok := input.(io.Seeker); ok {
+			if _, err := seeker.Seek(-int64(len(buffer)), io.SeekCurrent); err != nil {
+				return err
+			}
+		} else {
+			return errors.New("can't push back header token of next record: input is not seekable")
+		}
+		counter.count -= int64(len(buffer))
+		return nil
+	}
+	return assembleRecord(readToken, unreadToken, func() int64 { return counter.count }, offset)
+}
+
+// ReadBsmRecordBytes parses the BSM record at the beginning of the given
+// bytes (e.g. of a memory-mapped file) and returns it together with the
+// number of bytes it occupies. Errors are reported like ReadBsmRecord
+// does, except for truncated tokens which are reported as *ParseError
+// wrapping ErrShortToken. A *FileBoundary is returned together with the
+// size of the file token, so the caller can skip it.
+func ReadBsmRecordBytes(input []byte) (BsmRecord, int, error) {
+	return readBsmRecordBytes(input, 0)
+}
+
+// readBsmRecordBytes parses the record at the beginning of the given
+// bytes (see ReadBsmRecordBytes), which start at the given offset of the
+// stream (used for errors).
+func readBsmRecordBytes(input []byte, offset int64) (BsmRecord, int, error) {
+	consumed, last := 0, 0
+	readToken := func() (Token, error) {
+		if consumed == len(input) {
+			return nil, io.EOF
+		}
+		token, size, err := ParseToken(input[consumed:])
+		if err != nil {
+			return nil, &ParseError{Offset: offset + int64(consumed), TokenID: input[consumed], Err: err}
+		}
+		consumed += size
+		last = size
+		return token, nil
+	}
+	unreadToken := func() error {
+		consumed -= last
+		return nil
+	}
+	rec, err := assembleRecord(readToken, unreadToken, func() int64 { return int64(consumed) }, offset)
+	return rec, consumed, err
+}
+
+// assembleRecord builds a record from the tokens returned by readToken
+// (header up to trailer). unreadToken pushes back the last token read,
+// consumed reports the number of bytes read so far and offset is the
+// position of the record in the stream.
+func assembleRecord(readToken func() (Token, error), unreadToken func() error, consumed func() int64, offset int64) (BsmRecord, error) {
 	rec := BsmRecord{}
 
 	// start: header token
-	header, err := TokenFromByteInput(input)
+	header, err := readToken()
 	if err != nil {
 		return rec, err
 	}
 
+	var recordByteCount uint32
+	var version byte
 	switch v := header.(type) {
 	case HeaderToken32bit:
 		rec.Seconds = uint64(v.Seconds)
 		rec.NanoSeconds = uint64(v.NanoSeconds)
+		recordByteCount = v.RecordByteCount
+		version = v.VersionNumber
 	case HeaderToken64bit:
 		rec.Seconds = v.Seconds
 		rec.NanoSeconds = v.NanoSeconds
+		recordByteCount = v.RecordByteCount
+		version = v.VersionNumber
 	case ExpandedHeaderToken32bit:
 		rec.Seconds = uint64(v.Seconds)
 		rec.NanoSeconds = uint64(v.NanoSeconds)
+		recordByteCount = v.RecordByteCount
+		version = v.VersionNumber
 	case ExpandedHeaderToken64bit:
 		rec.Seconds = v.Seconds
 		rec.NanoSeconds = v.NanoSeconds
+		recordByteCount = v.RecordByteCount
+		version = v.VersionNumber
+	case FileToken:
+		return rec, &FileBoundary{File: v}
 	default:
-		return rec, errors.New("no header token found")
+		return rec, &ParseError{Offset: offset, TokenID: header.Type(), Err: errors.New("no header token found")}
 	}
+	rec.Header = header
 
-	nextToken, err := TokenFromByteInput(input)
-	if err != nil {
-		return rec, err
+	// later tokens may be mis-parsed for unknown versions
+	if SupportedVersions != nil && !SupportedVersions[version] {
+		return rec, &ParseError{Offset: offset, TokenID: header.Type(), Err: fmt.Errorf("%w: %d", ErrBadVersion, version)}
 	}
 
-	_, isEnd := nextToken.(TrailerToken) // assert next token to be trailer and check success
-	for !isEnd {
-		// append the current token to list (in record)
-		rec.Tokens = append(rec.Tokens, nextToken)
-
-		// check if the next (trailer) token indicates the end of record
-		nextToken, err = TokenFromByteInput(input)
+	for {
+		nextToken, err := readToken()
+		if !StrictTrailer && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+			return rec, fmt.Errorf("%w: record ends without trailer", ErrTrailerWarning)
+		}
+		if err == io.EOF {
+			return rec, io.ErrUnexpectedEOF // record ends without trailer
+		}
 		if err != nil {
 			return rec, err
 		}
-		_, isEnd = nextToken.(TrailerToken) // assert next token to be trailer and check success
+
+		// check if the trailer token indicates the end of record
+		if trailer, isEnd := nextToken.(TrailerToken); isEnd {
+			rec.Trailer = trailer
+			break
+		}
+		if !StrictTrailer {
+			switch nextToken.(type) {
+			case HeaderToken32bit, HeaderToken64bit, ExpandedHeaderToken32bit, ExpandedHeaderToken64bit:
+				if err := unreadToken(); err != nil {
+					return rec, err
+				}
+				return rec, fmt.Errorf("%w: record ends at next header without trailer", ErrTrailerWarning)
+			}
+		}
+		// append the current token to list (in record)
+		rec.Tokens = append(rec.Tokens, nextToken)
+	}
+
+	// bad magic is only accepted if StrictTrailer is disabled
+	if rec.Trailer.TrailerMagic != 0xb105 {
+		return rec, fmt.Errorf("%w: %v: 0x%x", ErrTrailerWarning, ErrBadTrailerMagic, rec.Trailer.TrailerMagic)
+	}
+
+	bytesRead := consumed()
+	if rec.Trailer.RecordByteCount != recordByteCount || int64(recordByteCount) != bytesRead {
+		return rec, fmt.Errorf("record length mismatch: header=%d trailer=%d read=%d",
+			recordByteCount, rec.Trailer.RecordByteCount, bytesRead)
 	}
 
 	return rec, nil
 }
 
+// countingReader keeps track of the number of bytes read.
+type countingReader struct {
+	reader  io.Reader
+	count   int64
+	pending []byte // bytes pushed back by unread
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	if 0 != len(c.pending) {
+		n := copy(p, c.pending)
+		c.pending = c.pending[n:]
+		c.count += int64(n)
+		return n, nil
+	}
+	n, err := c.reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// unread pushes back the given bytes, they are read again next.
+func (c *countingReader) unread(p []byte) {
+	c.pending = append(append([]byte(nil), p...), c.pending...)
+	c.count -= int64(len(p))
+}
+
 // RecordGenerator yields a continous stream of BSM records
 // until the source is exhausted.
-func RecordGenerator(input io.Reader) chan ParsingResult {
+func RecordGenerator(input io.Reader) <-chan ParsingResult {
+	return RecordGeneratorContext(context.Background(), input)
+}
+
+// ParseAll reads all records of the given input (see RecordGenerator).
+// It returns the records read up to the first error together with this
+// error. File boundaries are dropped, records with trailer warnings (see
+// StrictTrailer) are kept. All records are held in memory, so
+// this is unsuitable for huge audit trails.
+func ParseAll(input io.Reader) ([]BsmRecord, error) {
+	records := []BsmRecord{}
+	for result := range RecordGenerator(input) {
+		if result.Error != nil && !errors.Is(result.Error, ErrTrailerWarning) {
+			return records, result.Error
+		}
+		if result.Boundary != nil {
+			continue
+		}
+		records = append(records, result.Record)
+	}
+	return records, nil
+}
+
+// RecordGeneratorContext yields a continous stream of BSM records
+// until the source is exhausted or the given context is cancelled.
+// The channel is closed in both cases. A clean end of the source
+// (io.EOF between records) is not reported, any other error is passed
+// on as the last result before the channel is closed. File tokens
+// between records are passed on as results with Boundary set. Errors
+// wrapping ErrTrailerWarning (see StrictTrailer) are passed on together
+// with their record and don't stop the stream.
+func RecordGeneratorContext(ctx context.Context, input io.Reader) <-chan ParsingResult {
+	return generateRecords(ctx, input, tokenDecoder{})
+}
+
+// generateRecords yields the records of the given input (see
+// RecordGeneratorContext), decoding their tokens with d.
+func generateRecords(ctx context.Context, input io.Reader, d tokenDecoder) <-chan ParsingResult {
 	resChan := make(chan ParsingResult)
 
 	// cookie-cutter iterator
 	go func() {
+		defer close(resChan)
+		// keep track of offsets within the stream
+		counter := &countingReader{reader: input}
 		for { // extraction loop
-			rec, err := ReadBsmRecord(input)
+			rec, err := readBsmRecord(counter, counter.count, d)
+			// leave if source is exhausted or reading was cancelled
+			if err == io.EOF || ctx.Err() != nil {
+				return
+			}
 			res := ParsingResult{
 				Record: rec,
 				Error:  err,
 			}
-			resChan <- res
-			// leave source is exhausted
-			if res.Error == io.EOF {
-				break
+			if boundary, ok := err.(*FileBoundary); ok {
+				res = ParsingResult{Boundary: &boundary.File}
+			}
+			select {
+			case resChan <- res:
+			case <-ctx.Done():
+				return
+			}
+			// the stream can't be resynchronized after an error
+			if res.Error != nil && !errors.Is(res.Error, ErrTrailerWarning) {
+				return
 			}
 		}
-		close(resChan)
 	}()
 
 	return resChan
@@ -3,10 +3,17 @@ package bsm
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
 	"os"
 	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 func Test_bytesToUint32(t *testing.T) {
@@ -36,6 +43,56 @@ func Test_bytesToUint32(t *testing.T) {
 	}
 }
 
+func Test_bytesToUint64(t *testing.T) {
+	testdata := map[uint64][]byte{
+		0:                    []byte{0x00},
+		1:                    []byte{0x01},
+		2:                    []byte{0x00, 0x02},
+		256:                  []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00}, // leading 0x00 bytes
+		65536:                []byte{0x00, 0x01, 0x00, 0x00},
+		4294967296:           []byte{0x01, 0x00, 0x00, 0x00, 0x00},
+		72057594037927938:    []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02},
+		18446744073709551615: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+	for k, v := range testdata {
+		number, err := bytesToUint64(v)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if number != k {
+			t.Error("could not decode " + strconv.FormatUint(k, 10) + " correctly, got " + strconv.FormatUint(number, 10))
+		}
+	}
+	_, err := bytesToUint64([]byte{0xff, 0x01, 0xac, 0xb4, 0x2c, 0x00, 0x00, 0x00, 0x01})
+	if err == nil {
+		t.Error("did not catch overflow")
+	}
+}
+
+func Test_bytesToInt32(t *testing.T) {
+	testdata := map[int32][]byte{
+		0:           []byte{0x00, 0x00, 0x00, 0x00},
+		1:           []byte{0x00, 0x00, 0x00, 0x01},
+		-1:          []byte{0xff, 0xff, 0xff, 0xff},
+		-2:          []byte{0xff, 0xff, 0xff, 0xfe},
+		2147483647:  []byte{0x7f, 0xff, 0xff, 0xff},
+		-2147483648: []byte{0x80, 0x00, 0x00, 0x00},
+	}
+	for k, v := range testdata {
+		number, err := bytesToInt32(v)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if number != k {
+			t.Error("could not decode " + strconv.Itoa(int(k)) + " correctly, got " + strconv.Itoa(int(number)))
+		}
+	}
+	_, err := bytesToInt32([]byte{0xff, 0x01, 0xac, 0xb4, 0x2c})
+	if err == nil {
+		t.Error("did not catch overflow")
+	}
+}
+
 func TestTokenFromByteInput(t *testing.T) {
 	data := []byte{0x00}
 	_, err := TokenFromByteInput(bytes.NewBuffer(data))
@@ -61,6 +118,144 @@ func TestTokenFromByteInput(t *testing.T) {
 	}
 }
 
+func TestTokenFromByteInput_partial_reads(t *testing.T) {
+	data := []byte{
+		0x28,       // --- text token ID
+		0x00, 0x04, // text length incl. NUL
+		0x41, 0x42, 0x43, 0x00, // "ABC"
+		0x25,       // --- path attr token ID
+		0x00, 0x02, // count
+		0x41, 0x00, // path 1
+		0x42, 0x00, // path 2
+		0x2c,       // --- iport token ID
+		0x23, 0x42, // port number
+	}
+	// deliver exactly one byte per Read() call
+	input := iotest.OneByteReader(bytes.NewBuffer(data))
+
+	token, err := TokenFromByteInput(input)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	text, ok := token.(TextToken)
+	if !ok {
+		t.Fatal("expected TextToken, but got", token)
+	}
+	if text.Text != "ABC" {
+		t.Error("unexpected text: " + text.Text)
+	}
+
+	// no dedicated parser yet, but all bytes have to be consumed
+	_, err = TokenFromByteInput(input)
+	if err == nil || !strings.Contains(err.Error(), "new token ID found: 0x25") {
+		t.Error("unexpected error:", err)
+	}
+
+	token, err = TokenFromByteInput(input)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if token.(IPortToken).PortNumber != 9026 {
+		t.Error("wrong port number in IPortToken")
+	}
+
+	// stream ends within a token
+	_, err = TokenFromByteInput(iotest.OneByteReader(bytes.NewBuffer(data[:5])))
+	if err != io.ErrUnexpectedEOF {
+		t.Error("expected io.ErrUnexpectedEOF, got", err)
+	}
+}
+
+func TestTokenFromByteInput_EOF(t *testing.T) {
+	// end of stream before a token starts
+	_, err := TokenFromByteInput(bytes.NewBuffer([]byte{}))
+	if err != io.EOF {
+		t.Error("expected io.EOF on empty input, got", err)
+	}
+
+	// end of stream within the (fixed size) token
+	_, err = TokenFromByteInput(bytes.NewBuffer([]byte{0x27, 0x00, 0x00}))
+	if err != io.ErrUnexpectedEOF {
+		t.Error("expected io.ErrUnexpectedEOF on truncated return token, got", err)
+	}
+
+	// end of stream within the length field of a variable sized token
+	_, err = TokenFromByteInput(bytes.NewBuffer([]byte{0x28, 0x00}))
+	if err != io.ErrUnexpectedEOF {
+		t.Error("expected io.ErrUnexpectedEOF on truncated text token, got", err)
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	marshaled := []Marshaler{
+		HeaderToken32bit{TokenID: 0x14, VersionNumber: 11, EventType: 6152},
+		TextToken{TokenID: 0x28, Text: "successful login root"},
+		PathToken{TokenID: 0x23, Path: "/etc/passwd"},
+		ZonenameToken{TokenID: 0x60, Zonename: "global"},
+		ReturnToken32bit{TokenID: 0x27, ReturnValue: 1},
+		ReturnToken64bit{TokenID: 0x72, ErrorNumber: 13},
+		ExitToken{TokenID: 0x52, Status: 1},
+		SubjectToken32bit{TokenID: 0x24, AuditID: 1001},
+		ExpandedSubjectToken32bit{TokenID: 0x7a, AuditID: 1001, TerminalAddressLength: 16, TerminalMachineAddress: net.ParseIP("2001:db8::1")},
+		ExpandedProcessToken32bit{TokenID: 0x7b, ProcessID: 42, TerminalAddressLength: 4, TerminalMachineAddress: net.IPv4(10, 0, 0, 1)},
+		TrailerToken{TokenID: 0x13, TrailerMagic: 0xb105, RecordByteCount: 56},
+	}
+	samples := [][]byte{
+		{0x11, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x61, 0x00}, // file
+		{0x2c, 0x23, 0x42}, // iport
+		{0x2d, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x04, 0x66, 0x6f, 0x6f, 0x00}, // arg32
+		{0x2e, 0x00, 0x02, 0x00, 0x50, 0x5d, 0xb8, 0xd8, 0x26},                   // socket
+		{0x3c, 0x00, 0x00, 0x00, 0x02, 0x6c, 0x73, 0x00, 0x2d, 0x6c, 0x00},       // exec args
+		{0x3d, 0x00, 0x00, 0x00, 0x00},                                           // exec env
+		{0x7e, 0x00, 0x00, 0x00, 0x04, 0xc0, 0xa8, 0x01, 0x0a},                   // expanded in_addr
+		{0x80, 0x00, 0x02, 0x00, 0x16, 0xc0, 0xa8, 0x01, 0x0a},                   // inet32 socket
+		{0x82, 0x00, 0x01, 0x2f, 0x74, 0x6d, 0x70, 0x2f, 0x73, 0x00},             // unix socket
+	}
+	for _, m := range marshaled {
+		data, err := m.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples = append(samples, data)
+	}
+
+	for _, sample := range samples {
+		// bytes of the following token are not consumed
+		input := append(append([]byte{}, sample...), 0x13, 0xb1, 0x05, 0x00)
+		token, consumed, err := ParseToken(input)
+		if err != nil {
+			t.Errorf("token 0x%x: %v", sample[0], err)
+			continue
+		}
+		if consumed != len(sample) {
+			t.Errorf("token 0x%x: consumed %d bytes, expected %d", sample[0], consumed, len(sample))
+		}
+		if token.Type() != sample[0] {
+			t.Errorf("token 0x%x: parsed %v", sample[0], token)
+		}
+	}
+
+	// complete records token by token
+	tokens := 0
+	for data := rootLogin; len(data) > 0; tokens++ {
+		_, consumed, err := ParseToken(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = data[consumed:]
+	}
+	if tokens != 14 {
+		t.Error("unexpected number of tokens: " + strconv.Itoa(tokens))
+	}
+
+	// truncated tokens
+	for _, data := range [][]byte{{}, {0x28, 0x00}, rootLogin[:10]} {
+		if _, _, err := ParseToken(data); !errors.Is(err, ErrShortToken) {
+			t.Errorf("expected ErrShortToken for %x, got %v", data, err)
+		}
+	}
+}
+
 // fixed sized tokens
 func Test_determineTokenSize_fixed(t *testing.T) {
 	testData := map[byte]int{
@@ -82,11 +277,9 @@ func Test_determineTokenSize_fixed(t *testing.T) {
 		0x73: 33, // 64 bit attribute token
 		0x74: 26, // 64 bit header token
 		0x75: 41, // 64 bit subject token
-		0x77: 45, // 64 bit process token
-		0x7e: 18, // expanded in_addr token
+		0x77: 41, // 64 bit process token
 		0x80: 9,  // inet32 socket token
 		0x81: 21, // inet128 socket token
-		0x82: 9,  // FreeBSD socket token
 	}
 	for tokenID, count := range testData {
 		dcount, _, err := determineTokenSize([]byte{tokenID})
@@ -99,6 +292,108 @@ func Test_determineTokenSize_fixed(t *testing.T) {
 	}
 }
 
+func TestParseExpandedInAddrToken(t *testing.T) {
+	ipv6 := []byte{0x20, 0x01, 0x0d, 0xb8, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01} // 2001:db8::1
+
+	// libbsm: 4 byte address type, address of variable length
+	libbsm := append([]byte{0x7e, 0x00, 0x00, 0x00, 0x10}, ipv6...)
+	token, err := ParseExpandedInAddrTokenLibbsm(libbsm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.IpAddressType != 16 || token.IpAddress.String() != "2001:db8::1" {
+		t.Error("unexpected libbsm token:", token)
+	}
+	token, err = ParseExpandedInAddrTokenLibbsm([]byte{0x7e, 0x00, 0x00, 0x00, 0x04, 0xc0, 0xa8, 0x01, 0x0a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AddressFamily() != AddressFamilyIPv4 || token.IpAddress.String() != "192.168.1.10" {
+		t.Error("unexpected libbsm IPv4 token:", token)
+	}
+	if _, err = ParseExpandedInAddrTokenManpage(libbsm); err == nil {
+		t.Error("expected an error when parsing libbsm layout as manpage layout")
+	}
+
+	// audit.log(5): 1 byte address type, 16 byte address
+	manpage := append([]byte{0x7e, 0x10}, ipv6...)
+	token, err = ParseExpandedInAddrTokenManpage(manpage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.IpAddressType != 16 || token.IpAddress.String() != "2001:db8::1" {
+		t.Error("unexpected manpage token:", token)
+	}
+
+	// the generic token reader follows ExpandedInAddrLayout
+	size, _, err := determineTokenSize(libbsm)
+	if err != nil || size != 21 {
+		t.Error("unexpected size of libbsm token:", size, err)
+	}
+	if _, err = TokenFromByteInput(bytes.NewBuffer(libbsm)); err != nil {
+		t.Error(err)
+	}
+	ExpandedInAddrLayout = ManpageLayout
+	defer func() { ExpandedInAddrLayout = LibbsmLayout }()
+	size, _, err = determineTokenSize([]byte{0x7e})
+	if err != nil || size != 18 {
+		t.Error("unexpected size of manpage token:", size, err)
+	}
+	generic, err := TokenFromByteInput(bytes.NewBuffer(manpage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generic.(ExpandedInAddrToken).IpAddress.String() != "2001:db8::1" {
+		t.Error("unexpected token:", generic)
+	}
+}
+
+// regression test of token sizes which didn't match the layout written by
+// libbsm (au_to_* functions in bsm_token.c of OpenBSM)
+func Test_determineTokenSize_libbsmLayout(t *testing.T) {
+	zeros := func(n int) []byte { return make([]byte, n) }
+	concat := func(parts ...[]byte) []byte {
+		token := []byte{}
+		for _, part := range parts {
+			token = append(token, part...)
+		}
+		return token
+	}
+	testData := map[string][]byte{
+		// au_to_arg64: ID, argument number (1), value (8), text length
+		// including NUL (2), text
+		"arg64": concat([]byte{0x71, 0x01}, zeros(8), []byte{0x00, 0x03, 'a', 'b', 0x00}),
+		// au_to_process64: ID, auid, euid, egid, ruid, rgid, pid, sid (4
+		// each), terminal port (8), terminal address (4)
+		"process64": concat([]byte{0x77}, zeros(7*4), zeros(8), zeros(4)),
+		// au_to_header64_ex: ID, byte count (4), version (1), event type
+		// (2), event modifier (2), address type (4), address (4 or 16),
+		// seconds (8), nanoseconds (8)
+		"header64_ex IPv4": concat([]byte{0x79}, zeros(4), []byte{0x0b}, zeros(4), []byte{0, 0, 0, 4}, zeros(4), zeros(16)),
+		"header64_ex IPv6": concat([]byte{0x79}, zeros(4), []byte{0x0b}, zeros(4), []byte{0, 0, 0, 16}, zeros(16), zeros(16)),
+		// au_to_subject64_ex: ID, auid, euid, egid, ruid, rgid, pid, sid
+		// (4 each), terminal port (8), address type (4), address (4 or 16)
+		"subject64_ex IPv4": concat([]byte{0x7c}, zeros(7*4), zeros(8), []byte{0, 0, 0, 4}, zeros(4)),
+		"subject64_ex IPv6": concat([]byte{0x7c}, zeros(7*4), zeros(8), []byte{0, 0, 0, 16}, zeros(16)),
+		// au_to_process64_ex: ID, auid, euid, egid, ruid, rgid, pid, sid
+		// (4 each), terminal port (8), address type (4), address (4 or 16)
+		"process64_ex IPv4": concat([]byte{0x7d}, zeros(7*4), zeros(8), []byte{0, 0, 0, 4}, zeros(4)),
+		"process64_ex IPv6": concat([]byte{0x7d}, zeros(7*4), zeros(8), []byte{0, 0, 0, 16}, zeros(16)),
+	}
+	for name, token := range testData {
+		// trailing bytes (of the next token) are ignored
+		size, err := tokenDecoder{}.sliceTokenSize(append(append([]byte{}, token...), 0x13, 0xb1, 0x05))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if size != len(token) {
+			t.Errorf("%s: expected %d bytes, got %d", name, len(token), size)
+		}
+	}
+}
+
 func Test_determineTokenSize_file_token(t *testing.T) {
 	testData := []byte{}
 
@@ -212,7 +507,7 @@ func Test_determineTokenSize_expanded_64bit_subject_token(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	moreBytes := 37
+	moreBytes := 40
 	if more != moreBytes {
 		t.Error("expected " + strconv.Itoa(moreBytes) + " bytes more to read, but only " + strconv.Itoa(more) + " were requested")
 	}
@@ -227,14 +522,14 @@ func Test_determineTokenSize_expanded_64bit_subject_token(t *testing.T) {
 		0x00, 0x01, 0x02, 0x03, // process ID
 		0x00, 0x01, 0x02, 0x03, // audit session ID
 		0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, // terminal port ID
-		0x00,                   // length of address
+		0x00, 0x00, 0x00, 0x00, // length of address
 		0x00, 0x01, 0x02, 0x03, // IPv4
 	}
 	size, more, err := determineTokenSize(testData)
 	if err == nil {
 		t.Error("expected an error on invalid address length")
 	}
-	testData[37] = 4 // IPv4
+	testData[40] = 4 // IPv4
 	size, more, err = determineTokenSize(testData)
 	if err != nil {
 		t.Error(err)
@@ -242,7 +537,7 @@ func Test_determineTokenSize_expanded_64bit_subject_token(t *testing.T) {
 	if more != 0 {
 		t.Error("expected 0 bytes more to read, but only " + strconv.Itoa(more) + " were requested")
 	}
-	expSize := 42
+	expSize := 45
 	if size != expSize {
 		t.Error("wrong size: expected " + strconv.Itoa(expSize) + ", got " + strconv.Itoa(size))
 	}
@@ -331,7 +626,7 @@ func Test_determineTokenSize_expanded_64bit_header_token(t *testing.T) {
 	// correct token (in terms of size)
 	testData = []byte{0x79, // token ID
 		0x00, 0x01, 0x02, 0x03, // number of bytes in record
-		0x00, 0x01, // record version number
+		0x0b,       // record version number
 		0x00, 0x01, // event type
 		0x00, 0x01, // event modifier / sub-type
 		0x00, 0x01, 0x02, 0x03, // host address type/length
@@ -354,7 +649,7 @@ func Test_determineTokenSize_expanded_64bit_header_token(t *testing.T) {
 	if more != 0 {
 		t.Error("expected 0 bytes more to read, but only " + strconv.Itoa(more) + " were requested")
 	}
-	expSize := 35
+	expSize := 34
 	if size != expSize {
 		t.Error("wrong size: expected " + strconv.Itoa(expSize) + ", got " + strconv.Itoa(size))
 	}
@@ -486,6 +781,55 @@ func Test_determineTokenSize_exec_args_token(t *testing.T) {
 	if size != expSize {
 		t.Error("wrong size: expected " + strconv.Itoa(expSize) + ", got " + strconv.Itoa(size))
 	}
+	// following bytes must not be consumed
+	size, _, err = determineTokenSize(append(testData, 0x43, 0x00, 0x27))
+	if err != nil || size != expSize {
+		t.Error("wrong size with trailing bytes: " + strconv.Itoa(size))
+	}
+
+	// zero count
+	testData = []byte{0x3c, // token ID
+		0x00, 0x00, 0x00, 0x00, // count
+		0x27, 0x00, // next token
+	}
+	size, more, err = determineTokenSize(testData)
+	if err != nil || more != 0 || size != 5 {
+		t.Error("wrong size for zero count: " + strconv.Itoa(size))
+	}
+
+	// each missing string needs at least its NUL
+	testData = []byte{0x3c, // token ID
+		0x00, 0x00, 0x00, 0x03, // count
+		0x41, 0x00, 0x42, // text, start of next text
+	}
+	_, more, err = determineTokenSize(testData)
+	if err != nil || more != 2 {
+		t.Error("expected 2 bytes more to read, got " + strconv.Itoa(more))
+	}
+
+	// counts which can't fit into a token are rejected up front
+	_, _, err = determineTokenSize([]byte{0x3c, 0xff, 0xff, 0xff, 0xff})
+	if !errors.Is(err, ErrTokenTooLarge) {
+		t.Error("expected token too large, got", err)
+	}
+}
+
+func TestTokenFromByteInput_manyStrings(t *testing.T) {
+	// strings used to be rescanned for every byte read (quadratic)
+	count := 1 << 20
+	input := []byte{0x3c, 0x00, 0x10, 0x00, 0x00} // exec args, count 2^20
+	input = append(input, bytes.Repeat([]byte{0x61, 0x00}, count)...)
+	start := time.Now()
+	token, err := TokenFromByteInput(bytes.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args, ok := token.(ExecArgsToken); !ok || len(args.Text) != count {
+		t.Error("unexpected exec args token")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Error("reading took", elapsed)
+	}
 }
 
 func Test_determineTokenSize_exec_argv_token(t *testing.T) {
@@ -527,6 +871,21 @@ func Test_determineTokenSize_exec_argv_token(t *testing.T) {
 	if size != expSize {
 		t.Error("wrong size: expected " + strconv.Itoa(expSize) + ", got " + strconv.Itoa(size))
 	}
+	// following bytes must not be consumed
+	size, _, err = determineTokenSize(append(testData, 0x43, 0x00, 0x27))
+	if err != nil || size != expSize {
+		t.Error("wrong size with trailing bytes: " + strconv.Itoa(size))
+	}
+
+	// zero count
+	testData = []byte{0x3d, // token ID
+		0x00, 0x00, 0x00, 0x00, // count
+		0x27, 0x00, // next token
+	}
+	size, more, err = determineTokenSize(testData)
+	if err != nil || more != 0 || size != 5 {
+		t.Error("wrong size for zero count: " + strconv.Itoa(size))
+	}
 }
 
 func Test_determineTokenSize_group_token(t *testing.T) {
@@ -648,6 +1007,12 @@ func Test_determineTokenSize_path_attr_token(t *testing.T) {
 	if size != expSize {
 		t.Error("wrong size: expected " + strconv.Itoa(expSize) + ", got " + strconv.Itoa(size))
 	}
+
+	// following bytes don't belong to the token
+	size, _, err = determineTokenSize(append(testData, 0x13, 0xb1, 0x05))
+	if err != nil || size != expSize {
+		t.Error("wrong size with following bytes: " + strconv.Itoa(size))
+	}
 }
 
 func Test_determineTokenSize_text_token(t *testing.T) {
@@ -775,6 +1140,53 @@ func Test_determineTokenSize_zonename_token(t *testing.T) {
 	}
 }
 
+func Test_determineTokenSize_unix_socket_token(t *testing.T) {
+	// correct token ID, bot no more
+	testData := []byte{0x82}
+	_, more, err := determineTokenSize(testData)
+	if err != nil {
+		t.Error(err)
+	}
+	moreBytes := 3
+	if more != moreBytes {
+		t.Error("expected " + strconv.Itoa(moreBytes) + " bytes more to read, but only " + strconv.Itoa(more) + " were requested")
+	}
+
+	// path not terminated (yet)
+	testData = []byte{0x82, // token ID
+		0x00, 0x01, // socket family
+		0x2f, 0x74, 0x6d, // "/tm"
+	}
+	_, more, err = determineTokenSize(testData)
+	if err != nil {
+		t.Error(err)
+	}
+	if more != 1 {
+		t.Error("expected 1 bytes more to read, but only " + strconv.Itoa(more) + " were requested")
+	}
+
+	// correct token (in terms of size)
+	testData = append(testData, 0x70, 0x00) // "p" + NUL
+	size, more, err := determineTokenSize(testData)
+	if err != nil {
+		t.Error(err)
+	}
+	if more != 0 {
+		t.Error("expected 0 bytes more to read, but only " + strconv.Itoa(more) + " were requested")
+	}
+	expSize := 8
+	if size != expSize {
+		t.Error("wrong size: expected " + strconv.Itoa(expSize) + ", got " + strconv.Itoa(size))
+	}
+
+	// path exceeding sizeof(sun_path)
+	testData = append([]byte{0x82, 0x00, 0x01}, bytes.Repeat([]byte{0x41}, 105)...)
+	_, _, err = determineTokenSize(testData)
+	if err == nil {
+		t.Error("expected an error on overlong socket path")
+	}
+}
+
 func TestParseHeaderToken32bit(t *testing.T) {
 	data := []byte{0x14, // token ID \
 		0x00, 0x00, 0x00, 0x38, // record byte number \
@@ -810,92 +1222,560 @@ func TestParseHeaderToken32bit(t *testing.T) {
 		t.Error("wrong number of nanoseconds")
 	}
 
+	// truncated token
+	_, err = ParseHeaderToken32bit(data[:10])
+	if !errors.Is(err, ErrShortToken) {
+		t.Errorf("expected ErrShortToken on truncated token, got %v", err)
+	}
+	_, err = ParseHeaderToken32bit(append(data, 0x00))
+	if err == nil || errors.Is(err, ErrShortToken) {
+		t.Errorf("expected a length mismatch error on oversized token, got %v", err)
+	}
 }
 
-func Test_parsing_ExpandedProcessToken32bit(t *testing.T) {
-	data := []byte{
-		0x7b,                   // token ID
-		0x00, 0x01, 0x02, 0x03, // audit ID
-		0x00, 0x01, 0x02, 0x03, // effective user ID
-		0x00, 0x01, 0x02, 0x03, // effective group ID
-		0x00, 0x01, 0x02, 0x03, // real user ID
-		0x00, 0x01, 0x02, 0x03, // real group ID
-		0x00, 0x01, 0x02, 0x03, // process ID
-		0x00, 0x01, 0x02, 0x03, // session ID
-		0x00, 0x01, 0x02, 0x03, // terminal port ID
-		0x00, 0x00, 0x00, 0x04, // address length -> IPv4
-		0x00, 0x01, 0x02, 0x03, // actual IP
+func TestParseExitToken(t *testing.T) {
+	data := []byte{0x52, // token ID
+		0x00, 0x00, 0x01, 0x00, // status
+		0xff, 0xff, 0xff, 0xff, // return value (-1)
 	}
-
-	token, err := TokenFromByteInput(bytes.NewBuffer(data))
+	token, err := ParseExitToken(data)
 	if err != nil {
 		t.Error(err.Error())
 	}
-	switch v := token.(type) {
-	case ExpandedProcessToken32bit:
-		if v.TerminalAddressLength != 0x04 {
-			t.Error("invalid address length on 32 bit expanded process token")
-		}
-	default:
-		t.Error("unexpected token found")
+	if token.TokenID != 0x52 {
+		t.Error("wrong token ID")
+	}
+	if token.Status != 256 {
+		t.Error("wrong status, got " + strconv.Itoa(int(token.Status)))
+	}
+	if token.ReturnValue != -1 {
+		t.Error("wrong return value, got " + strconv.Itoa(int(token.ReturnValue)))
 	}
 
-	data = []byte{
-		0x7b,                   // token ID
-		0x00, 0x01, 0x02, 0x03, // audit ID
-		0x00, 0x01, 0x02, 0x03, // effective user ID
-		0x00, 0x01, 0x02, 0x03, // effective group ID
-		0x00, 0x01, 0x02, 0x03, // real user ID
-		0x00, 0x01, 0x02, 0x03, // real group ID
-		0x00, 0x01, 0x02, 0x03, // process ID
-		0x00, 0x01, 0x02, 0x03, // session ID
-		0x00, 0x01, 0x02, 0x03, // terminal port ID
-		0x00, 0x00, 0x00, 0x10, // address length -> IPv6
-		0x00, 0x01, 0x02, 0x03, // actual IP
-		0x04, 0x05, 0x06, 0x07,
-		0x08, 0x09, 0x0a, 0x0b,
-		0x0c, 0x0d, 0x0e, 0x0f,
+	// truncated token
+	_, err = ParseExitToken(data[:5])
+	if err == nil {
+		t.Error("expected an error on invalid length")
 	}
-	token, err = TokenFromByteInput(bytes.NewBuffer(data))
+}
+
+func TestParseIPortToken(t *testing.T) {
+	data := []byte{0x2c, // token ID
+		0x23, 0x42, // port number
+	}
+	token, err := ParseIPortToken(data)
 	if err != nil {
 		t.Error(err.Error())
 	}
-	switch token.(type) {
-	case ExpandedProcessToken32bit:
-	default:
-		t.Error("unexpected token found")
+	if token.TokenID != 0x2c {
+		t.Error("wrong token ID")
+	}
+	if token.PortNumber != 9026 {
+		t.Error("wrong port number, got " + strconv.Itoa(int(token.PortNumber)))
 	}
 
-	data = []byte{
-		0x7b,                   // token ID
-		0x00, 0x01, 0x02, 0x03, // audit ID
-		0x00, 0x01, 0x02, 0x03, // effective user ID
-		0x00, 0x01, 0x02, 0x03, // effective group ID
-		0x00, 0x01, 0x02, 0x03, // real user ID
-		0x00, 0x01, 0x02, 0x03, // real group ID
-		0x00, 0x01, 0x02, 0x03, // process ID
-		0x00, 0x01, 0x02, 0x03, // session ID
-		0x00, 0x01, 0x02, 0x03, // terminal port ID
-		0x00, 0x00, 0x00, 0x11, // invalid address length
-		0x00, 0x01, 0x02, 0x03, // actual IP
-		0x04, 0x05, 0x06, 0x07,
-		0x08, 0x09, 0x0a, 0x0b,
-		0x0c, 0x0d, 0x0e, 0x0f,
-		0x41,
+	// token ID mismatch
+	_, err = ParseIPortToken([]byte{0x2d, 0x23, 0x42})
+	if err == nil {
+		t.Error("expected an error on token ID mismatch")
 	}
-	_, err = TokenFromByteInput(bytes.NewBuffer(data))
+
+	// truncated token
+	_, err = ParseIPortToken(data[:2])
 	if err == nil {
 		t.Error("expected an error on invalid length")
 	}
+}
+
+func TestParseSocketToken(t *testing.T) {
+	data := []byte{0x2e, // token ID
+		0x00, 0x02, // socket family (AF_INET)
+		0x00, 0x50, // local port (80)
+		0x5d, 0xb8, 0xd8, 0x26, // IPv4 address
+	}
+	token, err := ParseSocketToken(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.SocketFamily != 2 || token.LocalPort != 80 || token.SocketAddress.String() != "93.184.216.38" {
+		t.Error("unexpected socket token:", token)
+	}
 
+	// the address field does not grow for IPv6 families
+	size, _, err := determineTokenSize(data)
+	if err != nil || size != 9 {
+		t.Error("unexpected size of socket token:", size, err)
+	}
+	data[2] = 28 // AF_INET6 (FreeBSD)
+	_, err = ParseSocketToken(data)
+	if err == nil || !strings.Contains(err.Error(), "0x81") {
+		t.Error("expected an error pointing to the inet128 socket token, got", err)
+	}
+	if _, err = TokenFromByteInput(bytes.NewBuffer(data)); !errors.Is(err, ErrSocketFamily) {
+		t.Error("expected ErrSocketFamily on IPv6 family, got", err)
+	}
 }
 
-func Test_small_example_token(t *testing.T) {
-	data := []byte{
-		0x14,                   // --- 32bit header token ID
-		0x00, 0x00, 0x00, 0x38, // 56 bytes in record
-		0x0b,       // version number (2991)
-		0xaf, 0xc8, // event type
+func TestParseSocketToken_inet6Families(t *testing.T) {
+	defer func(platform Platform) { SocketFamilyPlatform = platform }(SocketFamilyPlatform)
+	for _, platform := range []Platform{FreeBSD, Darwin, Solaris} {
+		SocketFamilyPlatform = platform // doesn't change the result
+		for _, family := range []byte{26, 28, 30} {
+			data := []byte{0x2e, 0x00, family, 0x00, 0x50, 0x5d, 0xb8, 0xd8, 0x26}
+			if _, err := ParseSocketToken(data); !errors.Is(err, ErrSocketFamily) {
+				t.Errorf("platform %d, family %d: expected ErrSocketFamily, got %v", platform, family, err)
+			}
+		}
+		data := []byte{0x2e, 0x00, 0x02, 0x00, 0x50, 0x5d, 0xb8, 0xd8, 0x26} // AF_INET
+		if _, err := ParseSocketToken(data); err != nil {
+			t.Errorf("platform %d: unexpected error on AF_INET: %v", platform, err)
+		}
+	}
+
+	// records report the token as ParseError
+	record := []byte{0x14, 0x00, 0x00, 0x00, 0x22, 0x0b, 0x00, 0x00, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}
+	record = append(record, 0x2e, 0x00, 28, 0x00, 0x50, 0x5d, 0xb8, 0xd8, 0x26)
+	record = append(record, 0x13, 0xb1, 0x05, 0x00, 0x00, 0x00, 0x22)
+	_, err := ReadBsmRecord(bytes.NewBuffer(record))
+	var parseError *ParseError
+	if !errors.As(err, &parseError) || parseError.TokenID != 0x2e || parseError.Offset != 18 {
+		t.Error("expected a parse error of the socket token, got", err)
+	}
+	if !errors.Is(err, ErrSocketFamily) {
+		t.Error("expected ErrSocketFamily, got", err)
+	}
+}
+
+func TestParseSocketInet32Token(t *testing.T) {
+	data := []byte{0x80, // token ID
+		0x00, 0x02, // socket family (AF_INET)
+		0x00, 0x16, // local port (22)
+		0xc0, 0xa8, 0x01, 0x0a, // IPv4 address
+	}
+	token, err := ParseSocketInet32Token(data)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if token.TokenID != 0x80 {
+		t.Error("wrong token ID")
+	}
+	if token.SocketFamily != 2 {
+		t.Error("wrong socket family")
+	}
+	if token.LocalPort != 22 {
+		t.Error("wrong local port, got " + strconv.Itoa(int(token.LocalPort)))
+	}
+	if token.SocketAddress.String() != "192.168.1.10" {
+		t.Error("wrong socket address, got " + token.SocketAddress.String())
+	}
+
+	// same token via the generic token reader
+	generic, err := TokenFromByteInput(bytes.NewBuffer(data))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if _, ok := generic.(SocketInet32Token); !ok {
+		t.Error("expected SocketInet32Token, but got", generic)
+	}
+
+	// truncated token
+	_, err = ParseSocketInet32Token(data[:7])
+	if err == nil {
+		t.Error("expected an error on invalid length")
+	}
+}
+
+func TestParseSocketInet128Token(t *testing.T) {
+	data := []byte{0x81, // token ID
+		0x00, 0x1c, // socket family (AF_INET6 on FreeBSD)
+		0x01, 0xbb, // local port (443)
+		0x20, 0x01, 0x0d, 0xb8, // IPv6 address
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x01,
+	}
+	token, err := ParseSocketInet128Token(data)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if token.TokenID != 0x81 {
+		t.Error("wrong token ID")
+	}
+	if token.SocketFamily != 28 {
+		t.Error("wrong socket family")
+	}
+	if token.LocalPort != 443 {
+		t.Error("wrong local port, got " + strconv.Itoa(int(token.LocalPort)))
+	}
+	if token.SocketAddress.String() != "2001:db8::1" {
+		t.Error("wrong socket address, got " + token.SocketAddress.String())
+	}
+
+	// same token via the generic token reader
+	generic, err := TokenFromByteInput(bytes.NewBuffer(data))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if _, ok := generic.(SocketInet128Token); !ok {
+		t.Error("expected SocketInet128Token, but got", generic)
+	}
+
+	// truncated token
+	_, err = ParseSocketInet128Token(data[:9])
+	if err == nil {
+		t.Error("expected an error on invalid length")
+	}
+}
+
+func TestParseSocketUnixToken(t *testing.T) {
+	data := []byte{0x82, // token ID
+		0x00, 0x01, // socket family (AF_UNIX)
+		0x2f, 0x74, 0x6d, 0x70, 0x2f, 0x2e, 0x73, 0x2e, // "/tmp/.s.sock"
+		0x73, 0x6f, 0x63, 0x6b, 0x00,
+	}
+	token, err := ParseSocketUnixToken(data)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if token.TokenID != 0x82 {
+		t.Error("wrong token ID")
+	}
+	if token.SocketFamily != 1 {
+		t.Error("wrong socket family")
+	}
+	if token.Path != "/tmp/.s.sock" {
+		t.Error("wrong socket path, got " + token.Path)
+	}
+
+	// same token via the generic token reader
+	generic, err := TokenFromByteInput(bytes.NewBuffer(data))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if v, ok := generic.(SocketUnixToken); !ok || v.Path != "/tmp/.s.sock" {
+		t.Error("expected SocketUnixToken, but got", generic)
+	}
+
+	// missing NUL
+	_, err = ParseSocketUnixToken(data[:10])
+	if err == nil {
+		t.Error("expected an error on missing NUL")
+	}
+}
+
+func TestParseSystemVIpcPermissionToken(t *testing.T) {
+	data := []byte{0x32, // token ID
+		0x00, 0x00, 0x03, 0xe9, // owner user ID
+		0x00, 0x00, 0x03, 0xea, // owner group ID
+		0x00, 0x00, 0x00, 0x00, // creator user ID
+		0x00, 0x00, 0x00, 0x05, // creator group ID
+		0x00, 0x00, 0x01, 0xb0, // access mode
+		0x00, 0x00, 0x00, 0x2a, // sequence number
+		0xde, 0xad, 0xbe, 0xef, // IPC key
+	}
+	token, err := ParseSystemVIpcPermissionToken(data)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if token.TokenID != 0x32 {
+		t.Error("wrong token ID")
+	}
+	if token.OwnerUserID != 1001 {
+		t.Error("wrong owner user ID, got " + strconv.Itoa(int(token.OwnerUserID)))
+	}
+	if token.OwnerGroupID != 1002 {
+		t.Error("wrong owner group ID, got " + strconv.Itoa(int(token.OwnerGroupID)))
+	}
+	if token.CreatorUserID != 0 {
+		t.Error("wrong creator user ID, got " + strconv.Itoa(int(token.CreatorUserID)))
+	}
+	if token.CreatorGroupID != 5 {
+		t.Error("wrong creator group ID, got " + strconv.Itoa(int(token.CreatorGroupID)))
+	}
+	if token.AccessMode != 0660 {
+		t.Error("wrong access mode, got " + strconv.Itoa(int(token.AccessMode)))
+	}
+	if token.SequenceNumber != 42 {
+		t.Error("wrong sequence number, got " + strconv.Itoa(int(token.SequenceNumber)))
+	}
+	if token.Key != 0xdeadbeef {
+		t.Error("wrong IPC key")
+	}
+
+	// same token via the generic token reader
+	generic, err := TokenFromByteInput(bytes.NewBuffer(data))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	switch v := generic.(type) {
+	case SystemVIpcPermissionToken:
+		if v != token {
+			t.Error("token differs from directly parsed one")
+		}
+	default:
+		t.Error("expected SystemVIpcPermissionToken, but got", v)
+	}
+
+	// truncated token
+	_, err = ParseSystemVIpcPermissionToken(data[:20])
+	if err == nil {
+		t.Error("expected an error on invalid length")
+	}
+}
+
+func TestParseTextToken(t *testing.T) {
+	data := []byte{0x28, // token ID
+		0x00, 0x16, // text length incl. NUL (22 bytes)
+		0x61, 0x75, 0x64, 0x69, // "auditd::Audit startup"
+		0x74, 0x64, 0x3a, 0x3a,
+		0x41, 0x75, 0x64, 0x69,
+		0x74, 0x20, 0x73, 0x74,
+		0x61, 0x72, 0x74, 0x75,
+		0x70, 0x00,
+	}
+	token, err := ParseTextToken(data)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if token.TokenID != 0x28 {
+		t.Error("wrong token ID")
+	}
+	if token.TextLength != 22 {
+		t.Error("wrong text length, got " + strconv.Itoa(int(token.TextLength)))
+	}
+	if token.Text != "auditd::Audit startup" {
+		t.Error("unexpected text: " + token.Text)
+	}
+
+	// missing NUL
+	data[len(data)-1] = 0x41
+	_, err = ParseTextToken(data)
+	if err == nil {
+		t.Error("expected an error on missing NUL")
+	}
+
+	// truncated token
+	_, err = ParseTextToken(data[:10])
+	if err == nil {
+		t.Error("expected an error on invalid length")
+	}
+}
+
+func TestParseFileToken(t *testing.T) {
+	data := []byte{0x11, // token ID
+		0x5a, 0x9a, 0xc2, 0xe6, // seconds
+		0x00, 0x00, 0x01, 0xf4, // microseconds
+		0x00, 0x05, // file name length (excluding NUL)
+		0x74, 0x72, 0x61, 0x69, 0x6c, 0x00, // "trail"
+	}
+	token, err := ParseFileToken(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Seconds != 1520091878 || token.Microseconds != 500 || token.PathName != "trail" {
+		t.Error("unexpected file token:", token)
+	}
+
+	// size has to match the length field
+	size, _, err := determineTokenSize(data)
+	if err != nil || size != len(data) {
+		t.Error("unexpected size of file token:", size, err)
+	}
+
+	// truncated file name
+	_, err = ParseFileToken(data[:14])
+	if !errors.Is(err, ErrShortToken) {
+		t.Errorf("expected ErrShortToken, got %v", err)
+	}
+}
+
+func TestParsePathToken(t *testing.T) {
+	data := []byte{0x23, // token ID
+		0x00, 0x0c, // path length incl. NUL (12 bytes)
+		0x2f, 0x65, 0x74, 0x63, // "/etc/passwd"
+		0x2f, 0x70, 0x61, 0x73,
+		0x73, 0x77, 0x64, 0x00,
+	}
+	token, err := ParsePathToken(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.PathLength != 12 || token.Path != "/etc/passwd" {
+		t.Errorf("unexpected path token: %d %q", token.PathLength, token.Path)
+	}
+
+	// size has to match the length field
+	size, _, err := determineTokenSize(data)
+	if err != nil || size != len(data) {
+		t.Error("unexpected size of path token:", size, err)
+	}
+
+	// empty path used to panic
+	token2, err := TokenFromByteInput(bytes.NewBuffer([]byte{0x23, 0x00, 0x00}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token2.(PathToken).Path != "" {
+		t.Error("expected empty path")
+	}
+
+	// missing NUL
+	data[len(data)-1] = 0x41
+	if _, err := ParsePathToken(data); err == nil {
+		t.Error("expected an error on missing NUL")
+	}
+}
+
+// The length field of text tokens includes the NUL, so the size of the
+// token is 1 + 2 + length (e.g. 25 bytes for "auditd::Audit startup").
+func Test_text_token_NUL_accounting(t *testing.T) {
+	data, err := ioutil.ReadFile("start_stop.bsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := data[18:] // text token right after the 32 bit header
+	length, _ := bytesToUint16(text[1:3])
+	if length != 22 {
+		t.Fatal("unexpected length field in fixture:", length)
+	}
+	size, _, err := determineTokenSize(text)
+	if err != nil || size != 1+2+22 {
+		t.Error("unexpected size of text token:", size, err)
+	}
+	token, err := ParseTextToken(text[:size])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token.Text)+1 != int(token.TextLength) || token.Text != "auditd::Audit startup" {
+		t.Errorf("text does not match length field: %q (%d)", token.Text, token.TextLength)
+	}
+}
+
+func TestParseZonenameToken(t *testing.T) {
+	data := []byte{0x60, // token ID
+		0x00, 0x06, // zonename length incl. NUL (6 bytes)
+		0x77, 0x77, 0x77, 0x30, 0x31, 0x00, // "www01"
+	}
+	token, err := ParseZonenameToken(data)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if token.TokenID != 0x60 {
+		t.Error("wrong token ID")
+	}
+	if token.ZonenameLength != 6 {
+		t.Error("wrong zonename length, got " + strconv.Itoa(int(token.ZonenameLength)))
+	}
+	if token.Zonename != "www01" {
+		t.Error("unexpected jail name: " + token.Zonename)
+	}
+
+	// same token via the generic token reader
+	generic, err := TokenFromByteInput(bytes.NewBuffer(data))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	switch v := generic.(type) {
+	case ZonenameToken:
+		if v.Zonename != "www01" {
+			t.Error("unexpected jail name: " + v.Zonename)
+		}
+	default:
+		t.Error("expected ZonenameToken, but got", v)
+	}
+
+	// length field exceeding the token
+	data[2] = 0x07
+	_, err = ParseZonenameToken(data)
+	if err == nil {
+		t.Error("expected an error on invalid length")
+	}
+}
+
+func Test_parsing_ExpandedProcessToken32bit(t *testing.T) {
+	data := []byte{
+		0x7b,                   // token ID
+		0x00, 0x01, 0x02, 0x03, // audit ID
+		0x00, 0x01, 0x02, 0x03, // effective user ID
+		0x00, 0x01, 0x02, 0x03, // effective group ID
+		0x00, 0x01, 0x02, 0x03, // real user ID
+		0x00, 0x01, 0x02, 0x03, // real group ID
+		0x00, 0x01, 0x02, 0x03, // process ID
+		0x00, 0x01, 0x02, 0x03, // session ID
+		0x00, 0x01, 0x02, 0x03, // terminal port ID
+		0x00, 0x00, 0x00, 0x04, // address length -> IPv4
+		0x00, 0x01, 0x02, 0x03, // actual IP
+	}
+
+	token, err := TokenFromByteInput(bytes.NewBuffer(data))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	switch v := token.(type) {
+	case ExpandedProcessToken32bit:
+		if v.TerminalAddressLength != 0x04 {
+			t.Error("invalid address length on 32 bit expanded process token")
+		}
+	default:
+		t.Error("unexpected token found")
+	}
+
+	data = []byte{
+		0x7b,                   // token ID
+		0x00, 0x01, 0x02, 0x03, // audit ID
+		0x00, 0x01, 0x02, 0x03, // effective user ID
+		0x00, 0x01, 0x02, 0x03, // effective group ID
+		0x00, 0x01, 0x02, 0x03, // real user ID
+		0x00, 0x01, 0x02, 0x03, // real group ID
+		0x00, 0x01, 0x02, 0x03, // process ID
+		0x00, 0x01, 0x02, 0x03, // session ID
+		0x00, 0x01, 0x02, 0x03, // terminal port ID
+		0x00, 0x00, 0x00, 0x10, // address length -> IPv6
+		0x00, 0x01, 0x02, 0x03, // actual IP
+		0x04, 0x05, 0x06, 0x07,
+		0x08, 0x09, 0x0a, 0x0b,
+		0x0c, 0x0d, 0x0e, 0x0f,
+	}
+	token, err = TokenFromByteInput(bytes.NewBuffer(data))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	switch token.(type) {
+	case ExpandedProcessToken32bit:
+	default:
+		t.Error("unexpected token found")
+	}
+
+	data = []byte{
+		0x7b,                   // token ID
+		0x00, 0x01, 0x02, 0x03, // audit ID
+		0x00, 0x01, 0x02, 0x03, // effective user ID
+		0x00, 0x01, 0x02, 0x03, // effective group ID
+		0x00, 0x01, 0x02, 0x03, // real user ID
+		0x00, 0x01, 0x02, 0x03, // real group ID
+		0x00, 0x01, 0x02, 0x03, // process ID
+		0x00, 0x01, 0x02, 0x03, // session ID
+		0x00, 0x01, 0x02, 0x03, // terminal port ID
+		0x00, 0x00, 0x00, 0x11, // invalid address length
+		0x00, 0x01, 0x02, 0x03, // actual IP
+		0x04, 0x05, 0x06, 0x07,
+		0x08, 0x09, 0x0a, 0x0b,
+		0x0c, 0x0d, 0x0e, 0x0f,
+		0x41,
+	}
+	_, err = TokenFromByteInput(bytes.NewBuffer(data))
+	if err == nil {
+		t.Error("expected an error on invalid length")
+	}
+
+}
+
+func Test_small_example_token(t *testing.T) {
+	data := []byte{
+		0x14,                   // --- 32bit header token ID
+		0x00, 0x00, 0x00, 0x38, // 56 bytes in record
+		0x0b,       // version number (2991)
+		0xaf, 0xc8, // event type
 		0x00, 0x00, // event modifier / sub-type
 		0x5a, 0x9a, 0xc2, 0xe6, // timestamp seconds
 		0x00, 0x00, 0x03, 0x01, // timestamp nanoseconds
@@ -969,7 +1849,7 @@ func Test_small_example_token(t *testing.T) {
 	}
 	switch v := token.(type) {
 	case TrailerToken:
-		if v.RecordByteCount != 0 {
+		if v.RecordByteCount != 56 {
 			t.Error("unexpected record byte count")
 		}
 	default:
@@ -989,101 +1869,210 @@ func Test_small_example_token(t *testing.T) {
 	// --- try the generator ---
 	input = bytes.NewBuffer(data)
 	rcount := 0
-	for _ = range RecordGenerator(input) {
+	for res := range RecordGenerator(input) {
+		if res.Error != nil {
+			t.Error(res.Error)
+		}
 		rcount += 1
-		if rcount > 2 { // original + EOF
+		if rcount > 1 { // original only, EOF is not reported
 			t.Error("more records than expected")
 		}
 	}
 }
 
-func Test_parsing_root_login(t *testing.T) {
+func TestToken_ID(t *testing.T) {
 	data := []byte{
-		0x14, // --- 32bit header token
-		0x00, 0x00, 0x00, 0x61,
-		0x0b,
-		0x18, 0x0f,
-		0x00, 0x00,
-		0x5a, 0x9a, 0xc2, 0x1f,
-		0x00, 0x00, 0x03, 0x63,
-		0x24,                   // --- 32bit subject token
-		0xff, 0xff, 0xff, 0xff, // audit ID
-		0x00, 0x00, 0x00, 0x00, // effective user ID
-		0x00, 0x00, 0x00, 0x00, // effective group ID
-		0x00, 0x00, 0x00, 0x00, // real user ID
-		0x00, 0x00, 0x00, 0x00, // real group ID
-		0x00, 0x00, 0x02, 0xf2, // process ID
-		0x00, 0x00, 0x02, 0xf2, // audit session ID
-		0x00, 0x00, 0x00, 0x00, // terminal port ID
-		0x00, 0x00, 0x00, 0x00, // machine IP address
-		0x28,       // --- text token
-		0x00, 0x1a, // test length (26)
-		0x73, 0x75, 0x63, 0x63, // text
-		0x65, 0x73, 0x73, 0x66,
-		0x75, 0x6c, 0x20, 0x61,
-		0x75, 0x74, 0x68, 0x65,
-		0x6e, 0x74, 0x69, 0x63,
-		0x61, 0x74, 0x69, 0x6f,
-		0x6e, 0x00,
-		0x27, // --- return token
-		0x00,
-		0x00, 0x00, 0x00, 0x00,
-		0x13, // --- trailer token
-		0xb1, 0x05,
-		0x00, 0x00, 0x00, 0x61,
-		0x14, // --- 32bit subject token
-		0x00, 0x00, 0x00, 0x61,
-		0x0b,
-		0x80, 0x20,
-		0x00, 0x00,
-		0x5a, 0x9a, 0xc2, 0x27,
-		0x00, 0x00, 0x01, 0xf9,
-		0x7a, // expanded 32bit subject token
-		0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00, 0x03, 0x35,
-		0x00, 0x00, 0x03, 0x35,
-		0x00, 0x00, 0x1c, 0x65,
-		0x00, 0x00, 0x00, 0x04,
-		0x5d, 0xb8, 0xd8, 0x26,
-		0x28, // --- text token
-		0x00, 0x16,
-		0x73, 0x75, 0x63, 0x63,
-		0x65, 0x73, 0x73, 0x66,
-		0x75, 0x6c, 0x20, 0x6c,
-		0x6f, 0x67, 0x69, 0x6e,
-		0x20, 0x72, 0x6f, 0x6f,
-		0x74, 0x00,
-		0x27, // --- return token
-		0x00,
-		0x00, 0x00, 0x00, 0x00,
-		0x13, // --- trailer token
-		0xb1, 0x05,
-		0x00, 0x00, 0x00, 0x61,
-		0x14, // 32 bit header
-		0x00, 0x00, 0x00, 0x39,
-		0x0b,
-		0xaf, 0xc9,
-		0x00, 0x00,
-		0x5a, 0x9a, 0xc2, 0x43,
-		0x00, 0x00, 0x03, 0xa1,
-		0x28, // --- text token
-		0x00, 0x17,
-		0x61, 0x75, 0x64, 0x69,
-		0x74, 0x64, 0x3a, 0x3a,
-		0x41, 0x75, 0x64, 0x69,
-		0x74, 0x20, 0x73, 0x68,
-		0x75, 0x74, 0x64, 0x6f,
-		0x77, 0x6e, 0x00,
-		0x27, // --- return token
-		0x00, 0x00, 0x00, 0x00, 0x00,
-		0x13, // --- trailer token
-		0xb1, 0x05, 0x00, 0x00, 0x00, 0x39,
+		0x14,                   // --- 32bit header token ID
+		0x00, 0x00, 0x00, 0x1f, // 31 bytes in record
+		0x0b,       // version number
+		0xaf, 0xc8, // event type
+		0x00, 0x00, // event modifier / sub-type
+		0x5a, 0x9a, 0xc2, 0xe6, // timestamp seconds
+		0x00, 0x00, 0x03, 0x01, // timestamp nanoseconds
+		0x2c,       // --- iport token ID
+		0x23, 0x42, // port number
+		0x27,                   // --- return token ID
+		0x00,                   // error number
+		0x00, 0x00, 0x00, 0x00, // return value
+		0x13,       // --- trailer token ID
+		0xb1, 0x05, // trailer magic
+		0x00, 0x00, 0x00, 0x1f, // record byte count
 	}
 	input := bytes.NewBuffer(data)
+	expected := []byte{0x14, 0x2c, 0x27, 0x13}
+	tokens := []Token{}
+	for range expected {
+		token, err := TokenFromByteInput(input)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		tokens = append(tokens, token)
+	}
+	for i, token := range tokens {
+		if token.Type() != expected[i] {
+			t.Errorf("token %d: expected ID 0x%x, got 0x%x", i, expected[i], token.Type())
+		}
+	}
+}
+
+func TestReadBsmRecord(t *testing.T) {
+	data := []byte{
+		0x14,                   // --- 32bit header token ID
+		0x00, 0x00, 0x00, 0x1f, // 31 bytes in record
+		0x0b,       // version number
+		0xaf, 0xc8, // event type
+		0x00, 0x00, // event modifier / sub-type
+		0x5a, 0x9a, 0xc2, 0xe6, // timestamp seconds
+		0x00, 0x00, 0x03, 0x01, // timestamp nanoseconds
+		0x27,                   // --- return token ID
+		0x00,                   // error number
+		0x00, 0x00, 0x00, 0x00, // return value
+		0x13,       // --- trailer token ID
+		0xb1, 0x05, // trailer magic
+		0x00, 0x00, 0x00, 0x1f, // record byte count
+	}
+
+	// well-formed record
+	rec, err := ReadBsmRecord(bytes.NewBuffer(data))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if _, ok := rec.Header.(HeaderToken32bit); !ok {
+		t.Error("expected HeaderToken32bit, but got", rec.Header)
+	}
+	if 1 != len(rec.Tokens) {
+		t.Error("unexpected number of tokens in BSM record")
+	}
+	if rec.Trailer.RecordByteCount != 31 {
+		t.Error("unexpected record byte count in trailer")
+	}
+	if rec.Seconds != 1520091878 {
+		t.Error("unexpected time stamp")
+	}
+
+	// no header
+	_, err = ReadBsmRecord(bytes.NewBuffer(data[18:]))
+	if err == nil || err.Error() != "token 0x27 at offset 0x0: no header token found" {
+		t.Error("expected an error on missing header, got", err)
+	}
+
+	// truncated within a token
+	_, err = ReadBsmRecord(bytes.NewBuffer(data[:28]))
+	if err == nil {
+		t.Error("expected an error on truncated record")
+	}
+
+	// truncated before the trailer
+	_, err = ReadBsmRecord(bytes.NewBuffer(data[:24]))
+	if err != io.ErrUnexpectedEOF {
+		t.Error("expected io.ErrUnexpectedEOF on missing trailer, got", err)
+	}
+
+	// byte count of trailer does not match
+	data[len(data)-1] = 0x20
+	_, err = ReadBsmRecord(bytes.NewBuffer(data))
+	if err == nil || err.Error() != "record length mismatch: header=31 trailer=32 read=31" {
+		t.Error("expected an error on record length mismatch, got", err)
+	}
+
+	// header and trailer agree, but don't match the actual length
+	data[4] = 0x20
+	_, err = ReadBsmRecord(bytes.NewBuffer(data))
+	if err == nil || err.Error() != "record length mismatch: header=32 trailer=32 read=31" {
+		t.Error("expected an error on record length mismatch, got", err)
+	}
+}
+
+// rootLogin holds three records: a successful authentication, a root
+// login (with expanded subject token) and an audit shutdown.
+var rootLogin = []byte{
+	0x14, // --- 32bit header token
+	0x00, 0x00, 0x00, 0x61,
+	0x0b,
+	0x18, 0x0f,
+	0x00, 0x00,
+	0x5a, 0x9a, 0xc2, 0x1f,
+	0x00, 0x00, 0x03, 0x63,
+	0x24,                   // --- 32bit subject token
+	0xff, 0xff, 0xff, 0xff, // audit ID
+	0x00, 0x00, 0x00, 0x00, // effective user ID
+	0x00, 0x00, 0x00, 0x00, // effective group ID
+	0x00, 0x00, 0x00, 0x00, // real user ID
+	0x00, 0x00, 0x00, 0x00, // real group ID
+	0x00, 0x00, 0x02, 0xf2, // process ID
+	0x00, 0x00, 0x02, 0xf2, // audit session ID
+	0x00, 0x00, 0x00, 0x00, // terminal port ID
+	0x00, 0x00, 0x00, 0x00, // machine IP address
+	0x28,       // --- text token
+	0x00, 0x1a, // test length (26)
+	0x73, 0x75, 0x63, 0x63, // text
+	0x65, 0x73, 0x73, 0x66,
+	0x75, 0x6c, 0x20, 0x61,
+	0x75, 0x74, 0x68, 0x65,
+	0x6e, 0x74, 0x69, 0x63,
+	0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x00,
+	0x27, // --- return token
+	0x00,
+	0x00, 0x00, 0x00, 0x00,
+	0x13, // --- trailer token
+	0xb1, 0x05,
+	0x00, 0x00, 0x00, 0x61,
+	0x14, // --- 32bit subject token
+	0x00, 0x00, 0x00, 0x61,
+	0x0b,
+	0x80, 0x20,
+	0x00, 0x00,
+	0x5a, 0x9a, 0xc2, 0x27,
+	0x00, 0x00, 0x01, 0xf9,
+	0x7a, // expanded 32bit subject token
+	0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x03, 0x35,
+	0x00, 0x00, 0x03, 0x35,
+	0x00, 0x00, 0x1c, 0x65,
+	0x00, 0x00, 0x00, 0x04,
+	0x5d, 0xb8, 0xd8, 0x26,
+	0x28, // --- text token
+	0x00, 0x16,
+	0x73, 0x75, 0x63, 0x63,
+	0x65, 0x73, 0x73, 0x66,
+	0x75, 0x6c, 0x20, 0x6c,
+	0x6f, 0x67, 0x69, 0x6e,
+	0x20, 0x72, 0x6f, 0x6f,
+	0x74, 0x00,
+	0x27, // --- return token
+	0x00,
+	0x00, 0x00, 0x00, 0x00,
+	0x13, // --- trailer token
+	0xb1, 0x05,
+	0x00, 0x00, 0x00, 0x61,
+	0x14, // 32 bit header
+	0x00, 0x00, 0x00, 0x39,
+	0x0b,
+	0xaf, 0xc9,
+	0x00, 0x00,
+	0x5a, 0x9a, 0xc2, 0x43,
+	0x00, 0x00, 0x03, 0xa1,
+	0x28, // --- text token
+	0x00, 0x17,
+	0x61, 0x75, 0x64, 0x69,
+	0x74, 0x64, 0x3a, 0x3a,
+	0x41, 0x75, 0x64, 0x69,
+	0x74, 0x20, 0x73, 0x68,
+	0x75, 0x74, 0x64, 0x6f,
+	0x77, 0x6e, 0x00,
+	0x27, // --- return token
+	0x00, 0x00, 0x00, 0x00, 0x00,
+	0x13, // --- trailer token
+	0xb1, 0x05, 0x00, 0x00, 0x00, 0x39,
+}
+
+func Test_parsing_root_login(t *testing.T) {
+	input := bytes.NewBuffer(rootLogin)
 	rec, err := ReadBsmRecord(input)
 	if err != nil {
 		t.Error(err.Error())
@@ -1128,6 +2117,174 @@ func Test_parsing_root_login(t *testing.T) {
 
 }
 
+func TestRecordGeneratorContext(t *testing.T) {
+	record := []byte{
+		0x14,                   // --- 32bit header token ID
+		0x00, 0x00, 0x00, 0x1f, // 31 bytes in record
+		0x0b,       // version number
+		0xaf, 0xc8, // event type
+		0x00, 0x00, // event modifier / sub-type
+		0x5a, 0x9a, 0xc2, 0xe6, // timestamp seconds
+		0x00, 0x00, 0x03, 0x01, // timestamp nanoseconds
+		0x27,                   // --- return token ID
+		0x00,                   // error number
+		0x00, 0x00, 0x00, 0x00, // return value
+		0x13,       // --- trailer token ID
+		0xb1, 0x05, // trailer magic
+		0x00, 0x00, 0x00, 0x1f, // record byte count
+	}
+	input := bytes.NewBuffer(bytes.Repeat(record, 100))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := RecordGeneratorContext(ctx, input)
+	res := <-results
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	cancel()
+
+	// the channel has to be closed without consuming all records
+	rcount := 1
+	for range results {
+		rcount += 1
+	}
+	if rcount >= 100 {
+		t.Error("generator did not stop on cancelled context")
+	}
+}
+
+func TestRecordGenerator_error(t *testing.T) {
+	data := []byte{
+		0x14,                   // --- 32bit header token ID
+		0x00, 0x00, 0x00, 0x1f, // 31 bytes in record
+		0x0b,       // version number
+		0xaf, 0xc8, // event type
+		0x00, 0x00, // event modifier / sub-type
+		0x5a, 0x9a, 0xc2, 0xe6, // timestamp seconds
+		0x00, 0x00, 0x03, 0x01, // timestamp nanoseconds
+		0x27,                   // --- return token ID
+		0x00,                   // error number
+		0x00, 0x00, 0x00, 0x00, // return value
+		0x13,       // --- trailer token ID
+		0xb1, 0x05, // trailer magic
+		0x00, 0x00, 0x00, 0x1f, // record byte count
+		0xff, 0xfe, 0xfd, 0xfc, // garbage
+	}
+	results := []ParsingResult{}
+	for res := range RecordGenerator(bytes.NewBuffer(data)) {
+		results = append(results, res)
+	}
+	if len(results) != 2 {
+		t.Fatal("expected one record and one error, got " + strconv.Itoa(len(results)) + " results")
+	}
+	if results[0].Error != nil {
+		t.Error(results[0].Error)
+	}
+	if results[1].Error == nil || results[1].Error == io.EOF {
+		t.Error("expected a parsing error, got", results[1].Error)
+	}
+}
+
+func TestRecordGenerator_truncated(t *testing.T) {
+	data, err := ioutil.ReadFile("start_stop.bsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// clean end of stream: no error reported
+	for res := range RecordGenerator(bytes.NewBuffer(data)) {
+		if res.Error != nil {
+			t.Error("unexpected error:", res.Error)
+		}
+	}
+
+	// stream ends within the trailer token of the last record
+	results := []ParsingResult{}
+	for res := range RecordGenerator(bytes.NewBuffer(data[:len(data)-3])) {
+		results = append(results, res)
+	}
+	if len(results) != 2 {
+		t.Fatal("expected one record and one error, got " + strconv.Itoa(len(results)) + " results")
+	}
+	if results[1].Error != io.ErrUnexpectedEOF {
+		t.Error("expected io.ErrUnexpectedEOF, got", results[1].Error)
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	records, err := ParseAll(bytes.NewBuffer(rootLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatal("expected 3 records, got " + strconv.Itoa(len(records)))
+	}
+	if event, _ := records[2].EventType(); event != 45001 {
+		t.Error("unexpected event of last record: " + strconv.Itoa(int(event)))
+	}
+
+	// records up to the error are returned
+	records, err = ParseAll(bytes.NewBuffer(rootLogin[:len(rootLogin)-3]))
+	if err != io.ErrUnexpectedEOF {
+		t.Error("expected io.ErrUnexpectedEOF, got", err)
+	}
+	if len(records) != 2 {
+		t.Error("expected 2 records before the error, got " + strconv.Itoa(len(records)))
+	}
+}
+
+// fileToken returns the bytes of a file token for the given path.
+func fileToken(path string) []byte {
+	data := []byte{0x11, 0x5a, 0x9a, 0xc2, 0xe6, 0x00, 0x00, 0x00, 0x00}
+	data = appendUint16(data, uint16(len(path)))
+	return append(append(data, path...), 0x00)
+}
+
+func TestRecordGenerator_fileBoundaries(t *testing.T) {
+	trail, err := ioutil.ReadFile("start_stop.bsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// two trails (with two records each) bracketed by file tokens
+	data := []byte{}
+	for _, name := range []string{"first", "second"} {
+		data = append(data, fileToken("")...)
+		data = append(data, trail...)
+		data = append(data, fileToken(name)...)
+	}
+
+	kinds := ""
+	for res := range RecordGenerator(bytes.NewBuffer(data)) {
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+		if res.Boundary != nil {
+			kinds += "F"
+			continue
+		}
+		kinds += "R"
+	}
+	if kinds != "FRRFFRRF" {
+		t.Error("unexpected sequence of boundaries and records: " + kinds)
+	}
+
+	// ReadBsmRecord reports the boundaries as errors
+	input := bytes.NewBuffer(data)
+	_, err = ReadBsmRecord(input)
+	var boundary *FileBoundary
+	if !errors.As(err, &boundary) || boundary.File.PathName != "" {
+		t.Fatal("expected file boundary, got", err)
+	}
+	if _, err = ReadBsmRecord(input); err != nil {
+		t.Error(err)
+	}
+
+	count, err := CountRecords(bytes.NewBuffer(data))
+	if err != nil || count != 4 {
+		t.Error("unexpected number of records:", count, err)
+	}
+}
+
 func Test_reading_from_file(t *testing.T) {
 	file, err := os.Open("start_stop.bsm")
 	if err != nil {
@@ -1136,10 +2293,351 @@ func Test_reading_from_file(t *testing.T) {
 	defer file.Close()
 
 	rcount := 0
-	for _ = range RecordGenerator(file) {
+	for res := range RecordGenerator(file) {
+		if res.Error != nil {
+			t.Error(res.Error)
+		}
 		rcount += 1
-		if rcount > 3 { // start + stop + EOF
+		if rcount > 2 { // start + stop
 			t.Error("more records than expected")
 		}
 	}
+	if rcount != 2 {
+		t.Error("expected 2 records, got " + strconv.Itoa(rcount))
+	}
+}
+
+func Test_sentinel_errors(t *testing.T) {
+	// token type which can't be sized
+	_, err := TokenFromByteInput(bytes.NewBuffer([]byte{0x90, 0x00, 0x00}))
+	if !errors.Is(err, ErrUnknownTokenID) {
+		t.Error("expected ErrUnknownTokenID, got", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "0x90") {
+		t.Error("expected token ID in error message, got", err)
+	}
+
+	// token type which can be sized, but not parsed yet
+	_, err = TokenFromByteInput(bytes.NewBuffer([]byte{0x2f, 0x00, 0x00, 0x00, 0x01}))
+	if !errors.Is(err, ErrUnknownTokenID) {
+		t.Error("expected ErrUnknownTokenID, got", err)
+	}
+
+	_, err = ParseTrailerToken([]byte{0x13, 0xb1, 0x06, 0x00, 0x00, 0x00, 0x38})
+	if !errors.Is(err, ErrBadTrailerMagic) {
+		t.Error("expected ErrBadTrailerMagic, got", err)
+	}
+
+	_, err = ParseTextToken([]byte{0x28, 0x00, 0x04, 0x41, 0x00})
+	if !errors.Is(err, ErrShortToken) {
+		t.Error("expected ErrShortToken, got", err)
+	}
+}
+
+func TestParseError_offset(t *testing.T) {
+	data, err := ioutil.ReadFile("start_stop.bsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// corrupt the token ID of the return token in the second record
+	first, err := ReadBsmRecord(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstLength := int(first.Trailer.RecordByteCount)
+	second, err := ReadBsmRecord(bytes.NewBuffer(data[firstLength:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	returnOffset := firstLength + int(second.Trailer.RecordByteCount) - 7 - 6 // before trailer
+	if data[returnOffset] != 0x27 {
+		t.Fatalf("expected return token at offset 0x%x", returnOffset)
+	}
+	data[returnOffset] = 0x90
+
+	var result ParsingResult
+	for result = range RecordGenerator(bytes.NewBuffer(data)) {
+	}
+	parseErr, ok := result.Error.(*ParseError)
+	if !ok {
+		t.Fatal("expected a *ParseError, got", result.Error)
+	}
+	if parseErr.Offset != int64(returnOffset) || parseErr.TokenID != 0x90 {
+		t.Errorf("unexpected offset 0x%x or token ID 0x%x", parseErr.Offset, parseErr.TokenID)
+	}
+	if !errors.Is(result.Error, ErrUnknownTokenID) {
+		t.Error("expected wrapped ErrUnknownTokenID, got", result.Error)
+	}
+	expected := "token 0x90 at offset 0x" + strconv.FormatInt(int64(returnOffset), 16)
+	if !strings.HasPrefix(result.Error.Error(), expected) {
+		t.Error("unexpected error message: " + result.Error.Error())
+	}
+}
+
+func TestReadBsmRecord_version(t *testing.T) {
+	data, err := ioutil.ReadFile("start_stop.bsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[5] = 0x0c // version 12
+	_, err = ReadBsmRecord(bytes.NewBuffer(data))
+	if !errors.Is(err, ErrBadVersion) {
+		t.Fatal("expected ErrBadVersion, got", err)
+	}
+	if err.Error() != "token 0x14 at offset 0x0: unsupported record version: 12" {
+		t.Error("unexpected error message: " + err.Error())
+	}
+
+	// validation disabled
+	supported := SupportedVersions
+	SupportedVersions = nil
+	defer func() { SupportedVersions = supported }()
+	if _, err = ReadBsmRecord(bytes.NewBuffer(data)); err != nil {
+		t.Error("unexpected error with disabled version validation:", err)
+	}
+}
+
+func TestParseExecArgsToken(t *testing.T) {
+	data := []byte{0x3c, // token ID
+		0x00, 0x00, 0x00, 0x02, // count
+		0x6c, 0x73, 0x00, // "ls"
+		0x2d, 0x6c, 0x00, // "-l"
+	}
+	token, err := ParseExecArgsToken(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Count != 2 || len(token.Text) != 2 || token.Text[0] != "ls" || token.Text[1] != "-l" {
+		t.Error("unexpected exec args:", token.Text)
+	}
+
+	// missing arguments
+	if _, err = ParseExecArgsToken(data[:5]); err == nil {
+		t.Error("expected an error on missing arguments")
+	}
+
+	// zero count
+	token, err = ParseExecArgsToken([]byte{0x3c, 0x00, 0x00, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Text == nil || len(token.Text) != 0 {
+		t.Error("expected an empty list of arguments, got", token.Text)
+	}
+
+	// unterminated argument
+	if _, err := ParseExecArgsToken(data[:len(data)-1]); !errors.Is(err, ErrShortToken) {
+		t.Error("expected ErrShortToken, got", err)
+	}
+}
+
+func TestParseExecEnvToken(t *testing.T) {
+	// zero count token followed by a return token
+	input := bytes.NewBuffer([]byte{
+		0x3d,                   // token ID
+		0x00, 0x00, 0x00, 0x00, // count
+		0x27, 0x00, 0x00, 0x00, 0x00, 0x00, // return token
+	})
+	token, err := TokenFromByteInput(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, ok := token.(ExecEnvToken)
+	if !ok {
+		t.Fatal("asserting ExecEnvToken type failed")
+	}
+	if env.Count != 0 || env.Text == nil || len(env.Text) != 0 {
+		t.Error("expected an empty list of variables, got", env.Text)
+	}
+	if _, err := TokenFromByteInput(input); err != nil {
+		t.Error("return token not read correctly:", err)
+	}
+
+	env, err = ParseExecEnvToken([]byte{0x3d, 0x00, 0x00, 0x00, 0x01, 0x41, 0x3d, 0x42, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(env.Text) != 1 || env.Text[0] != "A=B" {
+		t.Error("unexpected exec env:", env.Text)
+	}
+}
+
+func Test_determineTokenSize_MaxTokenSize(t *testing.T) {
+	maxSize := MaxTokenSize
+	MaxTokenSize = 1000
+	defer func() { MaxTokenSize = maxSize }()
+
+	// declared length exceeds the limit (the text itself is not needed)
+	_, err := TokenFromByteInput(bytes.NewBuffer([]byte{0x28, 0xff, 0xff}))
+	if !errors.Is(err, ErrTokenTooLarge) {
+		t.Error("expected ErrTokenTooLarge, got", err)
+	}
+
+	// never ending string in exec args token
+	data := append([]byte{0x3c, 0x00, 0x00, 0x00, 0x01}, bytes.Repeat([]byte{0x41}, 2000)...)
+	_, err = TokenFromByteInput(bytes.NewBuffer(data))
+	if !errors.Is(err, ErrTokenTooLarge) {
+		t.Error("expected ErrTokenTooLarge, got", err)
+	}
+
+	// tokens within the limit are fine
+	_, err = TokenFromByteInput(bytes.NewBuffer([]byte{0x28, 0x00, 0x02, 0x41, 0x00}))
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+}
+
+func TestLenient(t *testing.T) {
+	// System V IPC token: known size, but no parser
+	ipc := RawToken{TokenID: 0x22, Raw: []byte{0x22, 0x01, 0x00, 0x00, 0x00, 0x2a}}
+	record, err := BuildRecord(HeaderToken32bit{VersionNumber: 11, EventType: 1}, ipc, TextToken{Text: "after"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := append(record, rootLogin...)
+
+	if _, err = ParseAll(bytes.NewBuffer(input)); !errors.Is(err, ErrUnknownTokenID) {
+		t.Error("expected unknown token ID, got", err)
+	}
+
+	reader := NewRecordReader(bytes.NewBuffer(input))
+	reader.Lenient = true
+	records := []BsmRecord{}
+	for result := range reader.Records(context.Background()) {
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+		records = append(records, result.Record)
+	}
+	if len(records) != 4 {
+		t.Fatal("expected 4 records, got " + strconv.Itoa(len(records)))
+	}
+	raw, ok := records[0].Tokens[0].(RawToken)
+	if !ok || !bytes.Equal(raw.Raw, ipc.Raw) {
+		t.Error("unexpected raw token:", records[0].Tokens[0])
+	}
+	if text, ok := records[0].Tokens[1].(TextToken); !ok || text.Text != "after" {
+		t.Error("unexpected token after raw token:", records[0].Tokens[1])
+	}
+
+	// the same for reading record by record
+	reader = NewRecordReader(bytes.NewBuffer(input))
+	reader.Lenient = true
+	rec, err := reader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rec.Tokens[0].(RawToken); !ok {
+		t.Error("expected a raw token, got", rec.Tokens[0])
+	}
+
+	// tokens of unknown size still fail
+	if _, err = TokenFromByteInput(bytes.NewBuffer([]byte{0xee, 0x00})); !errors.Is(err, ErrUnknownTokenID) {
+		t.Error("expected unknown token ID, got", err)
+	}
+}
+
+func TestReadBsmRecordBytes(t *testing.T) {
+	input := rootLogin
+	count := 0
+	for len(input) > 0 {
+		rec, consumed, err := ReadBsmRecordBytes(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if consumed != int(rec.Trailer.RecordByteCount) {
+			t.Errorf("consumed %d bytes, record length is %d", consumed, rec.Trailer.RecordByteCount)
+		}
+		expected, err := ReadBsmRecord(bytes.NewBuffer(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rec.Equal(expected) {
+			t.Error("record differs from the one read by ReadBsmRecord:", rec)
+		}
+		input = input[consumed:]
+		count += 1
+	}
+	if count != 3 {
+		t.Error("expected 3 records, got " + strconv.Itoa(count))
+	}
+
+	if _, _, err := ReadBsmRecordBytes(nil); err != io.EOF {
+		t.Error("expected io.EOF, got", err)
+	}
+	_, first, _ := ReadBsmRecordBytes(rootLogin)
+	if _, _, err := ReadBsmRecordBytes(rootLogin[:first-3]); !errors.Is(err, ErrShortToken) {
+		t.Error("expected a short token, got", err)
+	}
+	withoutTrailer := rootLogin[:first-7]
+	if _, _, err := ReadBsmRecordBytes(withoutTrailer); err != io.ErrUnexpectedEOF {
+		t.Error("expected io.ErrUnexpectedEOF, got", err)
+	}
+
+	// file tokens are consumed
+	_, consumed, err := ReadBsmRecordBytes(fileToken("trail"))
+	var boundary *FileBoundary
+	if !errors.As(err, &boundary) || consumed != 17 {
+		t.Error("expected a file boundary of 17 bytes, got", err, consumed)
+	}
+}
+
+func TestStrictTrailer(t *testing.T) {
+	defer func(strict bool) { StrictTrailer = strict }(StrictTrailer)
+	first, err := BuildRecord(HeaderToken32bit{VersionNumber: 11, EventType: 1}, TextToken{Text: "first"}, ReturnToken32bit{TokenID: 0x27})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := BuildRecord(HeaderToken32bit{VersionNumber: 11, EventType: 2}, ReturnToken32bit{TokenID: 0x27})
+	if err != nil {
+		t.Fatal(err)
+	}
+	badMagic := append(append([]byte{}, first...), second...)
+	badMagic[len(first)-6] = 0xff // first byte of trailer magic
+	noTrailer := append(append([]byte{}, first[:len(first)-7]...), second...)
+
+	// strict (default)
+	StrictTrailer = true
+	if _, err = ParseAll(bytes.NewBuffer(badMagic)); !errors.Is(err, ErrBadTrailerMagic) {
+		t.Error("expected bad trailer magic, got", err)
+	}
+	if _, err = ParseAll(bytes.NewBuffer(noTrailer)); err == nil {
+		t.Error("expected an error on missing trailer")
+	}
+
+	// lenient
+	StrictTrailer = false
+	for name, input := range map[string][]byte{"bad magic": badMagic, "no trailer": noTrailer} {
+		records, err := ParseAll(bytes.NewBuffer(input))
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if len(records) != 2 || len(records[0].Tokens) != 2 {
+			t.Fatal(name+": unexpected records:", records)
+		}
+		if event, _ := records[1].EventType(); event != 2 {
+			t.Error(name+": unexpected event of second record:", event)
+		}
+	}
+	rec, err := ReadBsmRecord(bytes.NewReader(badMagic))
+	if !errors.Is(err, ErrTrailerWarning) || rec.Trailer.RecordByteCount != uint32(len(first)) {
+		t.Error("expected a trailer warning, got", err, rec.Trailer)
+	}
+
+	// pushing back the next header requires a seekable input
+	input := bytes.NewReader(noTrailer)
+	if _, err = ReadBsmRecord(input); !errors.Is(err, ErrTrailerWarning) {
+		t.Error("expected a trailer warning, got", err)
+	}
+	if rec, err = ReadBsmRecord(input); err != nil || rec.Trailer.RecordByteCount != uint32(len(second)) {
+		t.Error("unexpected second record:", rec, err)
+	}
+	if _, err = ReadBsmRecord(bytes.NewBuffer(noTrailer)); err == nil || errors.Is(err, ErrTrailerWarning) {
+		t.Error("expected an error on non-seekable input, got", err)
+	}
+
+	// truncated record at the end of input
+	if _, err = ReadBsmRecord(bytes.NewReader(first[:len(first)-3])); !errors.Is(err, ErrTrailerWarning) {
+		t.Error("expected a trailer warning, got", err)
+	}
 }
@@ -0,0 +1,118 @@
+// Incremental construction of BSM records
+package bsm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// RecordBuilder accumulates the tokens of a record. The header token has
+// to be added first, the trailer token is added by Finish, which also
+// computes the record byte counts (like au_close(3) of libbsm).
+type RecordBuilder struct {
+	header Token
+	tokens []Token
+}
+
+// AddHeader sets the header token of the record. It has to be called
+// once, before any other token is added.
+func (b *RecordBuilder) AddHeader(header Token) error {
+	if b.header != nil {
+		return errors.New("record has a header token already")
+	}
+	switch header.(type) {
+	case HeaderToken32bit, HeaderToken64bit, ExpandedHeaderToken32bit, ExpandedHeaderToken64bit:
+	default:
+		return fmt.Errorf("token 0x%x is not a header token", header.Type())
+	}
+	b.header = header
+	return nil
+}
+
+// AddToken appends the given token to the record. Header and trailer
+// tokens are rejected.
+func (b *RecordBuilder) AddToken(token Token) error {
+	if b.header == nil {
+		return errors.New("record has to start with a header token")
+	}
+	switch token.(type) {
+	case HeaderToken32bit, HeaderToken64bit, ExpandedHeaderToken32bit, ExpandedHeaderToken64bit:
+		return errors.New("record can't contain a second header token")
+	case TrailerToken:
+		return errors.New("trailer token is added by Finish")
+	}
+	if _, ok := token.(Sizer); !ok {
+		return fmt.Errorf("size of token 0x%x is unknown", token.Type())
+	}
+	b.tokens = append(b.tokens, token)
+	return nil
+}
+
+// Finish completes the record: the record byte counts of the header and
+// the (added) trailer token are set to the size of the serialized record.
+func (b *RecordBuilder) Finish() (BsmRecord, error) {
+	rec := BsmRecord{}
+	if b.header == nil {
+		return rec, errors.New("record has to start with a header token")
+	}
+
+	// record byte count includes header and trailer
+	trailer := TrailerToken{TokenID: 0x13, TrailerMagic: 0xb105}
+	length := b.header.(Sizer).Size() + trailer.Size()
+	for _, token := range b.tokens {
+		length += token.(Sizer).Size()
+	}
+	if uint64(length) > math.MaxUint32 {
+		return rec, errors.New("record too long")
+	}
+	trailer.RecordByteCount = uint32(length)
+
+	switch h := b.header.(type) {
+	case HeaderToken32bit:
+		h.RecordByteCount = trailer.RecordByteCount
+		rec.Header, rec.Seconds, rec.NanoSeconds = h, uint64(h.Seconds), uint64(h.NanoSeconds)
+	case HeaderToken64bit:
+		h.RecordByteCount = trailer.RecordByteCount
+		rec.Header, rec.Seconds, rec.NanoSeconds = h, h.Seconds, h.NanoSeconds
+	case ExpandedHeaderToken32bit:
+		h.RecordByteCount = trailer.RecordByteCount
+		rec.Header, rec.Seconds, rec.NanoSeconds = h, uint64(h.Seconds), uint64(h.NanoSeconds)
+	case ExpandedHeaderToken64bit:
+		h.RecordByteCount = trailer.RecordByteCount
+		rec.Header, rec.Seconds, rec.NanoSeconds = h, h.Seconds, h.NanoSeconds
+	}
+	rec.Tokens = append([]Token(nil), b.tokens...)
+	rec.Trailer = trailer
+	return rec, nil
+}
+
+// GenerateTrail writes a synthetic audit trail of n valid (56 byte)
+// records to the given output, e.g. for tests and benchmarks at scale.
+// Each record consists of a 32 bit header (audit startup event, one
+// second after the preceding record), a text, a return and a trailer
+// token.
+func GenerateTrail(w io.Writer, n int) error {
+	for i := 0; i < n; i++ {
+		builder := RecordBuilder{}
+		err := builder.AddHeader(HeaderToken32bit{TokenID: 0x14, VersionNumber: 11, EventType: 45000, Seconds: uint32(i)})
+		if err != nil {
+			return err
+		}
+		if err = builder.AddToken(TextToken{TokenID: 0x28, TextLength: 22, Text: "auditd::Audit startup"}); err != nil {
+			return err
+		}
+		if err = builder.AddToken(ReturnToken32bit{TokenID: 0x27}); err != nil {
+			return err
+		}
+		rec, err := builder.Finish()
+		if err != nil {
+			return err
+		}
+		if _, err = rec.WriteTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// test incremental construction of BSM records
+package bsm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRecordBuilder(t *testing.T) {
+	// small example record (see Test_small_example_token)
+	data := []byte{
+		0x14, 0x00, 0x00, 0x00, 0x38, 0x0b, 0xaf, 0xc8, 0x00, 0x00, // 32bit header token
+		0x5a, 0x9a, 0xc2, 0xe6, 0x00, 0x00, 0x03, 0x01,
+		0x28, 0x00, 0x16, // text token
+		0x61, 0x75, 0x64, 0x69, 0x74, 0x64, 0x3a, 0x3a, 0x41, 0x75, 0x64,
+		0x69, 0x74, 0x20, 0x73, 0x74, 0x61, 0x72, 0x74, 0x75, 0x70, 0x00,
+		0x27, 0x00, 0x00, 0x00, 0x00, 0x00, // return token
+		0x13, 0xb1, 0x05, 0x00, 0x00, 0x00, 0x38, // trailer token
+	}
+	expected, err := ReadBsmRecord(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	builder := RecordBuilder{}
+	if err = builder.AddToken(TextToken{TokenID: 0x28}); err == nil {
+		t.Error("expected an error on token before header")
+	}
+	header := HeaderToken32bit{TokenID: 0x14, VersionNumber: 11, EventType: 45000, Seconds: 1520091878, NanoSeconds: 769}
+	if err = builder.AddHeader(header); err != nil {
+		t.Fatal(err)
+	}
+	if err = builder.AddHeader(header); err == nil {
+		t.Error("expected an error on second header")
+	}
+	if err = builder.AddToken(TextToken{TokenID: 0x28, TextLength: 22, Text: "auditd::Audit startup"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = builder.AddToken(ReturnToken32bit{TokenID: 0x27}); err != nil {
+		t.Fatal(err)
+	}
+	if err = builder.AddToken(TrailerToken{}); err == nil {
+		t.Error("expected an error on trailer token")
+	}
+	rec, err := builder.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rec.Equal(expected) {
+		t.Errorf("built record differs from parsed one:\n%v\n%v", rec, expected)
+	}
+	output := &bytes.Buffer{}
+	if _, err = rec.WriteTo(output); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(output.Bytes(), data) {
+		t.Errorf("unexpected serialized record: %x", output.Bytes())
+	}
+
+	if _, err = (&RecordBuilder{}).Finish(); err == nil {
+		t.Error("expected an error on missing header")
+	}
+}
+
+func TestGenerateTrail(t *testing.T) {
+	output := &bytes.Buffer{}
+	if err := GenerateTrail(output, 100); err != nil {
+		t.Fatal(err)
+	}
+	if output.Len() != 100*56 {
+		t.Error("unexpected size of trail:", output.Len())
+	}
+	records, err := ParseAll(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 100 {
+		t.Fatal("expected 100 records, got", len(records))
+	}
+	for i, rec := range records {
+		if err := rec.Validate(); err != nil {
+			t.Errorf("record %d: %v", i, err)
+		}
+	}
+	if records[99].Seconds != 99 {
+		t.Error("unexpected time stamp of last record:", records[99].Seconds)
+	}
+}
@@ -0,0 +1,386 @@
+// A simple tool to print BSM audit records
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	//"github.com/davecgh/go-spew/spew"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/tpltnt/go-bsm"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writeError is an error writing the output (as opposed to reading or
+// parsing the input).
+type writeError struct {
+	err error
+}
+
+func (e writeError) Error() string { return e.err.Error() }
+func (e writeError) Unwrap() error { return e.err }
+
+// printRecords prints all records of the parsing results (in praudit
+// style). Processing stops at the first parsing error.
+func printRecords(results <-chan bsm.ParsingResult, output io.Writer) error {
+	for result := range results {
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.Boundary != nil {
+			if _, err := fmt.Fprintf(output, "file,%d,%d,%s\n", result.Boundary.Seconds, result.Boundary.Microseconds, result.Boundary.PathName); err != nil {
+				return writeError{err}
+			}
+			continue
+		}
+		if err := bsm.WriteText(output, result.Record); err != nil {
+			return writeError{err}
+		}
+	}
+	return nil
+}
+
+// recordPrinter returns the function printing all records of the parsing
+// results in the given output format (text, json, xml or csv). File
+// boundaries are only shown in text format.
+func recordPrinter(format string) (func(results <-chan bsm.ParsingResult, output io.Writer) error, error) {
+	var write func(io.Writer, bsm.BsmRecord) error
+	var flush func() error
+	switch format {
+	case "text":
+		return printRecords, nil
+	case "json":
+		write = func(w io.Writer, rec bsm.BsmRecord) error {
+			data, err := rec.MarshalJSON()
+			if err != nil {
+				return err
+			}
+			if _, err = w.Write(append(data, '\n')); err != nil {
+				return writeError{err}
+			}
+			return nil
+		}
+	case "xml":
+		write = func(w io.Writer, rec bsm.BsmRecord) error {
+			if err := bsm.WriteXML(w, rec); err != nil {
+				return writeError{err}
+			}
+			return nil
+		}
+	case "csv":
+		// one writer for all inputs, so the header is only written once
+		var writer *bsm.CSVWriter
+		write = func(w io.Writer, rec bsm.BsmRecord) error {
+			if writer == nil {
+				var err error
+				if writer, err = bsm.NewCSVWriter(w); err != nil {
+					return writeError{err}
+				}
+			}
+			if err := writer.Write(rec); err != nil {
+				return writeError{err}
+			}
+			return nil
+		}
+		flush = func() error {
+			if writer == nil {
+				return nil
+			}
+			if err := writer.Flush(); err != nil {
+				return writeError{err}
+			}
+			return nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown output format %q (expected text, json, xml or csv)", format)
+	}
+
+	return func(results <-chan bsm.ParsingResult, output io.Writer) error {
+		err := func() error {
+			for result := range results {
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.Boundary != nil {
+					continue
+				}
+				if err := write(output, result.Record); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+		if flush != nil { // also keep the rows written before an error
+			if ferr := flush(); err == nil {
+				err = ferr
+			}
+		}
+		return err
+	}, nil
+}
+
+// summarizeRecords prints the event type and time stamp of each record
+// of the parsing results, followed by the total number of records.
+func summarizeRecords(results <-chan bsm.ParsingResult, output io.Writer) error {
+	count := 0
+	for result := range results {
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.Boundary != nil {
+			continue
+		}
+		count += 1
+		rec := result.Record
+		event, _ := rec.EventType()
+		timestamp := time.Unix(int64(rec.Seconds), int64(rec.NanoSeconds)).UTC()
+		if _, err := fmt.Fprintf(output, "event=%d time=%s\n", event, timestamp.Format(time.RFC3339Nano)); err != nil {
+			return writeError{err}
+		}
+	}
+	if _, err := fmt.Fprintf(output, "records=%d\n", count); err != nil {
+		return writeError{err}
+	}
+	return nil
+}
+
+// filterResults passes through the parsing results whose record satisfies
+// the predicate. Errors and file boundaries are always passed through.
+// The output channel is closed when the results are exhausted or the
+// context is cancelled.
+func filterResults(ctx context.Context, results <-chan bsm.ParsingResult, pred func(bsm.BsmRecord) bool) <-chan bsm.ParsingResult {
+	output := make(chan bsm.ParsingResult)
+	go func() {
+		defer close(output)
+		for {
+			var result bsm.ParsingResult
+			var ok bool
+			select {
+			case result, ok = <-results:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+			if result.Error == nil && result.Boundary == nil && !pred(result.Record) {
+				continue
+			}
+			select {
+			case output <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// parseEvents parses a comma separated list of event types, given as
+// numbers or as names found in the event table.
+func parseEvents(list string, names bsm.EventNames) ([]uint16, error) {
+	events := []uint16{}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if number, err := strconv.ParseUint(item, 10, 16); err == nil {
+			events = append(events, uint16(number))
+			continue
+		}
+		number, ok := names.EventNumber(item)
+		if !ok {
+			return nil, fmt.Errorf("unknown event %q (event names require --eventfile)", item)
+		}
+		events = append(events, number)
+	}
+	return events, nil
+}
+
+// eventFilter returns a predicate selecting the records of the given
+// event types.
+func eventFilter(ids []uint16) func(bsm.BsmRecord) bool {
+	wanted := map[uint16]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	return func(rec bsm.BsmRecord) bool {
+		event, ok := rec.EventType()
+		return ok && wanted[event]
+	}
+}
+
+// isTerminal reports whether the input is an interactive terminal.
+func isTerminal(input io.Reader) bool {
+	file, ok := input.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// run processes the given command line arguments (without the program
+// name) and returns the exit code. Records are read from stdin if no
+// audit file is given (e.g. 'auditreduce ... | bsmprinter') and printed
+// to out, errors are logged to errw.
+func run(args []string, stdin io.Reader, out, errw io.Writer) int {
+	logger := log.New(errw, "", 0)
+
+	// handle CLI
+	flags := pflag.NewFlagSet("bsmprinter", pflag.ContinueOnError)
+	flags.SetOutput(errw)
+	flags.StringArray("auditfile", nil, "FreeBSD audit file to parse, may be repeated (default: stdin)")
+	flags.String("format", "text", "output format: text, json, xml or csv")
+	flags.String("events", "", "only print records of the given (comma separated) event types")
+	flags.String("eventfile", "", "event table to resolve event names (e.g. /etc/security/audit_event)")
+	flags.Bool("count", false, "only print the number of (matching) records")
+	flags.Bool("summary", false, "only print event type and time stamp per record")
+	flags.Bool("lenient", false, "hex dump tokens of known size which can't be decoded")
+	if err := flags.Parse(args); err != nil {
+		return 2 // usage already printed
+	}
+	config := viper.New()
+	config.BindPFlags(flags)
+
+	// --count and --summary replace the regular output
+	exclusive := []string{}
+	if flags.Changed("format") {
+		exclusive = append(exclusive, "--format")
+	}
+	for _, name := range []string{"count", "summary"} {
+		if config.GetBool(name) {
+			exclusive = append(exclusive, "--"+name)
+		}
+	}
+	if 1 < len(exclusive) {
+		logger.Printf("conflicting flags: %s\n", strings.Join(exclusive, ", "))
+		return 2
+	}
+
+	process, err := recordPrinter(config.GetString("format"))
+	if err != nil {
+		logger.Println(err)
+		return 2
+	}
+	if config.GetBool("summary") {
+		process = summarizeRecords
+	}
+
+	// restrict output to the given events
+	var keep func(bsm.BsmRecord) bool
+	if events := config.GetString("events"); 0 != len(events) {
+		names := bsm.EventNames{}
+		if eventFile := config.GetString("eventfile"); 0 != len(eventFile) {
+			file, err := os.Open(eventFile)
+			if err != nil {
+				logger.Println("Could not open event table:", err)
+				return 1
+			}
+			names, err = bsm.LoadEventNames(file)
+			file.Close()
+			if err != nil {
+				logger.Println("Could not read event table:", err)
+				return 1
+			}
+		}
+		ids, err := parseEvents(events, names)
+		if err != nil {
+			logger.Println(err)
+			return 2
+		}
+		keep = eventFilter(ids)
+	}
+	read := 0 // records read from the current input
+	handle := func(input io.Reader) error {
+		read = 0
+		// stop reading and filtering when process returns early
+		ctx, cancel := context.WithCancel(context.Background())
+		reader := bsm.NewRecordReader(input)
+		reader.Lenient = config.GetBool("lenient")
+		results := filterResults(ctx, reader.Records(ctx), func(rec bsm.BsmRecord) bool {
+			read += 1
+			return keep == nil || keep(rec)
+		})
+		err := process(results, out)
+		cancel()
+		for range results { // wait for the filter before read is used
+		}
+		return err
+	}
+
+	// only count the (matching) records of all files
+	total, counting := 0, config.GetBool("count")
+	if counting && keep == nil {
+		handle = func(input io.Reader) error {
+			var err error
+			read, err = bsm.CountRecords(input) // doesn't parse tokens
+			total += read
+			return err
+		}
+	} else if counting {
+		process = func(results <-chan bsm.ParsingResult, _ io.Writer) error {
+			for result := range results {
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.Boundary == nil {
+					total += 1
+				}
+			}
+			return nil
+		}
+	}
+	fail := func(name string, err error) int {
+		var werr writeError
+		if errors.As(err, &werr) { // e.g. a broken pipe
+			logger.Println("Could not write output:", werr.err)
+			return 1
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) { // input ends within a record
+			err = fmt.Errorf("unexpected EOF after %d records", read)
+		}
+		logger.Printf("Could not parse %s: %s\n", name, err)
+		return 1
+	}
+
+	// process files (given by flag or as arguments) in order, empty
+	// files simply contain no records
+	paths := append(config.GetStringSlice("auditfile"), flags.Args()...)
+	if 0 == len(paths) && isTerminal(stdin) { // don't wait for input typed in
+		fmt.Fprintln(errw, "usage: bsmprinter [flags] [auditfile ...]")
+		flags.PrintDefaults()
+		return 2
+	}
+	if 0 == len(paths) {
+		if err := handle(stdin); err != nil {
+			return fail("input", err)
+		}
+	}
+	for _, path := range paths {
+		file, err := bsm.OpenAuditFile(path)
+		if err != nil {
+			logger.Println("Could not open input file:", err)
+			return 1
+		}
+		err = handle(file)
+		file.Close()
+		if err != nil {
+			return fail(path, err)
+		}
+	}
+	if counting {
+		if _, err := fmt.Fprintln(out, total); err != nil {
+			return fail("output", writeError{err})
+		}
+	}
+	return 0
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -0,0 +1,409 @@
+// test the BSM printer
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tpltnt/go-bsm"
+)
+
+// audit trails of the package tests
+const (
+	startStop   = "../../start_stop.bsm"
+	startStopGz = "../../testdata/start_stop.bsm.gz"
+)
+
+// rootLogin returns the bytes of three records (events 6159, 32800 and
+// 45001) of a root login.
+func rootLogin(t *testing.T) []byte {
+	data, err := ioutil.ReadFile("../../testdata/root_login.bsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// failingWriter fails all writes.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func Test_printRecords(t *testing.T) {
+	file, err := os.Open(startStop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	output := &bytes.Buffer{}
+	if err := printRecords(bsm.RecordGenerator(file), output); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 8 { // header + text + return + trailer per record
+		t.Fatal("unexpected number of lines:\n" + output.String())
+	}
+	if !strings.HasPrefix(lines[0], "header,") || !strings.Contains(lines[0], ",45000,") {
+		t.Error("expected audit startup header, got " + lines[0])
+	}
+	if lines[1] != "text,auditd::Audit startup" {
+		t.Error("unexpected text token: " + lines[1])
+	}
+	if !strings.Contains(lines[4], ",45001,") {
+		t.Error("expected audit shutdown header, got " + lines[4])
+	}
+	if !strings.HasPrefix(lines[7], "trailer,") {
+		t.Error("expected trailer, got " + lines[7])
+	}
+}
+
+func Test_printRecords_boundary(t *testing.T) {
+	results := make(chan bsm.ParsingResult, 1)
+	results <- bsm.ParsingResult{Boundary: &bsm.FileToken{TokenID: 0x11, Seconds: 1520091878, Microseconds: 250000, PathName: "trail"}}
+	close(results)
+
+	output := &bytes.Buffer{}
+	if err := printRecords(results, output); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "file,1520091878,250000,trail\n" { // microseconds
+		t.Error("unexpected file boundary: " + output.String())
+	}
+}
+
+func Test_printRecords_error(t *testing.T) {
+	data, err := ioutil.ReadFile(startStop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := &bytes.Buffer{}
+	err = printRecords(bsm.RecordGenerator(bytes.NewReader(data[:len(data)-3])), output) // truncated
+	if err == nil {
+		t.Error("expected an error on truncated input")
+	}
+}
+
+func Test_summarizeRecords(t *testing.T) {
+	file, err := os.Open(startStop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	output := &bytes.Buffer{}
+	if err := summarizeRecords(bsm.RecordGenerator(file), output); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatal("unexpected summary:\n" + output.String())
+	}
+	if !strings.HasPrefix(lines[0], "event=45000 time=") || !strings.HasPrefix(lines[1], "event=45001 time=") {
+		t.Error("unexpected events in summary:\n" + output.String())
+	}
+	if lines[2] != "records=2" {
+		t.Error("unexpected record count: " + lines[2])
+	}
+}
+
+func Test_run(t *testing.T) {
+	output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run([]string{"--auditfile", startStop, "--summary"}, nil, output, errors); code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, errors.String())
+	}
+	if !strings.HasSuffix(output.String(), "records=2\n") {
+		t.Error("unexpected output:\n" + output.String())
+	}
+	if errors.Len() != 0 {
+		t.Error("unexpected error output: " + errors.String())
+	}
+}
+
+func Test_run_missingFile(t *testing.T) {
+	output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run([]string{"--auditfile", "does_not_exist.bsm"}, nil, output, errors); code == 0 {
+		t.Error("expected a non-zero exit code")
+	}
+	if !strings.Contains(errors.String(), "Could not open input file") {
+		t.Error("unexpected error output: " + errors.String())
+	}
+	if output.Len() != 0 {
+		t.Error("unexpected output: " + output.String())
+	}
+}
+
+func Test_run_multipleFiles(t *testing.T) {
+	output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+	args := []string{"--auditfile", startStop, "--auditfile", startStopGz}
+	if code := run(args, nil, output, errors); code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, errors.String())
+	}
+	if strings.Count(output.String(), "text,auditd::Audit startup") != 2 {
+		t.Error("expected records of both files:\n" + output.String())
+	}
+
+	// positional arguments are processed after the flags
+	output.Reset()
+	args = []string{"--summary", "--auditfile", startStop, startStopGz}
+	if code := run(args, nil, output, errors); code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, errors.String())
+	}
+	if strings.Count(output.String(), "records=2\n") != 2 {
+		t.Error("expected a summary per file:\n" + output.String())
+	}
+}
+
+func Test_run_format(t *testing.T) {
+	testData := map[string][]string{
+		"text": {"header,", "text,auditd::Audit startup", "trailer,"},
+		"json": {`{"timestamp":`, `"tokens":[`},
+		"xml":  {"<record ", "<text>auditd::Audit startup</text>"},
+		"csv":  {"timestamp,event,uid,return,text\n", ",45000,", ",45001,"},
+	}
+	for format, markers := range testData {
+		output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+		if code := run([]string{"--format", format, "--auditfile", startStop}, nil, output, errors); code != 0 {
+			t.Errorf("%s: unexpected exit code %d: %s", format, code, errors.String())
+			continue
+		}
+		for _, marker := range markers {
+			if !strings.Contains(output.String(), marker) {
+				t.Errorf("%s: expected %q in output:\n%s", format, marker, output.String())
+			}
+		}
+	}
+
+	// CSV header is written once for multiple files
+	output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run([]string{"--format", "csv", startStop, startStopGz}, nil, output, errors); code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, errors.String())
+	}
+	if lines := strings.Split(strings.TrimSpace(output.String()), "\n"); len(lines) != 5 {
+		t.Error("expected header and 4 rows:\n" + output.String())
+	}
+}
+
+func Test_run_unknownFormat(t *testing.T) {
+	output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run([]string{"--format", "yaml", "--auditfile", startStop}, nil, output, errors); code == 0 {
+		t.Error("expected a non-zero exit code")
+	}
+	if !strings.Contains(errors.String(), `unknown output format "yaml"`) {
+		t.Error("unexpected error output: " + errors.String())
+	}
+}
+
+func Test_run_events(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bsmprinter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	trail, table := filepath.Join(dir, "root_login.bsm"), filepath.Join(dir, "audit_event")
+	if err = ioutil.WriteFile(trail, rootLogin(t), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(table, []byte("32800:AUE_openssh:OpenSSH login:lo\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, events := range []string{"32800", "AUE_openssh", "1,32800"} {
+		output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+		args := []string{"--events", events, "--eventfile", table, "--format", "csv", "--auditfile", trail}
+		if code := run(args, nil, output, errors); code != 0 {
+			t.Fatalf("%s: unexpected exit code %d: %s", events, code, errors.String())
+		}
+		lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+		if len(lines) != 2 || !strings.Contains(lines[1], ",32800,") {
+			t.Errorf("%s: expected the root login only:\n%s", events, output.String())
+		}
+	}
+
+	// event names need an event table
+	output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run([]string{"--events", "AUE_openssh", "--auditfile", trail}, nil, output, errors); code == 0 {
+		t.Error("expected a non-zero exit code")
+	}
+	if !strings.Contains(errors.String(), `unknown event "AUE_openssh"`) {
+		t.Error("unexpected error output: " + errors.String())
+	}
+}
+
+func Test_run_count(t *testing.T) {
+	testData := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--count", "--auditfile", startStop}, "2\n"},
+		{[]string{"--count", "--events", "45001", "--auditfile", startStop}, "1\n"},
+		{[]string{"--count", startStop, startStopGz}, "4\n"},
+	}
+	for _, test := range testData {
+		output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+		if code := run(test.args, nil, output, errors); code != 0 {
+			t.Errorf("%v: unexpected exit code %d: %s", test.args, code, errors.String())
+			continue
+		}
+		if output.String() != test.expected {
+			t.Errorf("%v: expected %q, got %q", test.args, test.expected, output.String())
+		}
+	}
+}
+
+func Test_run_conflictingFlags(t *testing.T) {
+	testData := [][]string{
+		{"--count", "--format", "json"},
+		{"--summary", "--format", "text"},
+		{"--summary", "--count"},
+	}
+	for _, args := range testData {
+		output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+		if code := run(append(args, startStop), nil, output, errors); code != 2 {
+			t.Errorf("%v: expected exit code 2, got %d", args, code)
+		}
+		if !strings.Contains(errors.String(), "conflicting flags: ") {
+			t.Errorf("%v: unexpected error output: %s", args, errors.String())
+		}
+		if output.Len() != 0 {
+			t.Errorf("%v: unexpected output: %s", args, output.String())
+		}
+	}
+}
+
+func Test_filterResults_cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan bsm.ParsingResult) // never sends nor closes
+	output := filterResults(ctx, results, func(bsm.BsmRecord) bool { return true })
+	cancel()
+	if _, ok := <-output; ok {
+		t.Error("expected the output to be closed after cancelling")
+	}
+
+	// pending results aren't sent after cancelling
+	ctx, cancel = context.WithCancel(context.Background())
+	output = filterResults(ctx, bsm.RecordGeneratorContext(ctx, bytes.NewReader(rootLogin(t))), func(bsm.BsmRecord) bool { return true })
+	<-output
+	cancel()
+	for range output {
+	}
+}
+
+func Test_run_writeError(t *testing.T) {
+	for _, format := range []string{"text", "json", "xml", "csv"} {
+		errors := &bytes.Buffer{}
+		if code := run([]string{"--format", format, startStop}, nil, failingWriter{}, errors); code != 1 {
+			t.Errorf("%s: expected exit code 1, got %d", format, code)
+		}
+		if errors.String() != "Could not write output: write failed\n" {
+			t.Errorf("%s: unexpected error output: %s", format, errors.String())
+		}
+	}
+	for _, args := range [][]string{{"--summary"}, {"--count"}} {
+		errors := &bytes.Buffer{}
+		if code := run(append(args, startStop), nil, failingWriter{}, errors); code != 1 {
+			t.Errorf("%v: expected exit code 1, got %d", args, code)
+		}
+		if !strings.HasPrefix(errors.String(), "Could not write output: ") {
+			t.Errorf("%v: unexpected error output: %s", args, errors.String())
+		}
+	}
+}
+
+func Test_run_lenient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bsmprinter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ipc := bsm.RawToken{TokenID: 0x22, Raw: []byte{0x22, 0x01, 0x00, 0x00, 0x00, 0x2a}}
+	record, err := bsm.BuildRecord(bsm.HeaderToken32bit{VersionNumber: 11, EventType: 1}, ipc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trail := filepath.Join(dir, "ipc.bsm")
+	if err = ioutil.WriteFile(trail, record, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run([]string{trail}, nil, output, errors); code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	output.Reset()
+	if code := run([]string{"--lenient", trail}, nil, output, errors); code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, errors.String())
+	}
+	if !strings.Contains(output.String(), "trailer,") {
+		t.Error("expected the complete record:\n" + output.String())
+	}
+}
+
+func Test_run_emptyAndTruncated(t *testing.T) {
+	data, err := ioutil.ReadFile(startStop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "bsmprinter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	empty, truncated := filepath.Join(dir, "empty.bsm"), filepath.Join(dir, "truncated.bsm")
+	if err = ioutil.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(truncated, data[:len(data)-3], 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{{empty}, {"--count", empty}, {"--summary", empty}} {
+		output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+		if code := run(args, nil, output, errors); code != 0 {
+			t.Errorf("%v: unexpected exit code %d: %s", args, code, errors.String())
+		}
+		if errors.Len() != 0 {
+			t.Errorf("%v: unexpected error output: %s", args, errors.String())
+		}
+	}
+	output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+	if run([]string{"--count", empty}, nil, output, errors); output.String() != "0\n" {
+		t.Error("expected 0 records, got " + output.String())
+	}
+
+	for _, args := range [][]string{{truncated}, {"--count", truncated}, {"--count", "--events", "45000", truncated}} {
+		output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+		if code := run(args, nil, output, errors); code == 0 {
+			t.Errorf("%v: expected a non-zero exit code", args)
+		}
+		if !strings.Contains(errors.String(), "unexpected EOF after 1 records") {
+			t.Errorf("%v: unexpected error output: %s", args, errors.String())
+		}
+	}
+}
+
+func Test_run_stdin(t *testing.T) {
+	output, errors := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := run([]string{"--count"}, bytes.NewReader(rootLogin(t)), output, errors); code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, errors.String())
+	}
+	if output.String() != "3\n" {
+		t.Error("expected 3 records, got " + output.String())
+	}
+
+	output.Reset()
+	if code := run([]string{"--format", "csv", "--events", "45001"}, bytes.NewReader(rootLogin(t)), output, errors); code != 0 {
+		t.Fatalf("unexpected exit code %d: %s", code, errors.String())
+	}
+	if lines := strings.Split(strings.TrimSpace(output.String()), "\n"); len(lines) != 2 || !strings.Contains(lines[1], ",45001,") {
+		t.Error("expected the audit shutdown only:\n" + output.String())
+	}
+}
@@ -0,0 +1,141 @@
+// CSV representation of BSM records
+package bsm
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCSVColumns are the columns written by WriteCSV if none are given.
+var DefaultCSVColumns = []string{"timestamp", "event", "uid", "return", "text"}
+
+// csvColumns maps the supported column names to functions extracting
+// the respective value from a record. Values of tokens missing in a
+// record are left empty.
+var csvColumns = map[string]func(BsmRecord) string{
+	"timestamp": func(r BsmRecord) string {
+		return time.Unix(int64(r.Seconds), int64(r.NanoSeconds)).UTC().Format(time.RFC3339Nano)
+	},
+	"event": func(r BsmRecord) string {
+		if event, ok := r.EventType(); ok {
+			return strconv.Itoa(int(event))
+		}
+		return ""
+	},
+	"auid": func(r BsmRecord) string { return csvSubjectField(r, func(s Subject) uint32 { return s.AuditID }) },
+	"uid": func(r BsmRecord) string {
+		return csvSubjectField(r, func(s Subject) uint32 { return s.EffectiveUserID })
+	},
+	"gid": func(r BsmRecord) string {
+		return csvSubjectField(r, func(s Subject) uint32 { return s.EffectiveGroupID })
+	},
+	"ruid": func(r BsmRecord) string { return csvSubjectField(r, func(s Subject) uint32 { return s.RealUserID }) },
+	"rgid": func(r BsmRecord) string { return csvSubjectField(r, func(s Subject) uint32 { return s.RealGroupID }) },
+	"pid":  func(r BsmRecord) string { return csvSubjectField(r, func(s Subject) uint32 { return s.ProcessID }) },
+	"errno": func(r BsmRecord) string {
+		for _, token := range r.Tokens {
+			switch t := token.(type) {
+			case ReturnToken32bit:
+				return strconv.Itoa(int(t.ErrorNumber))
+			case ReturnToken64bit:
+				return strconv.Itoa(int(t.ErrorNumber))
+			}
+		}
+		return ""
+	},
+	"return": func(r BsmRecord) string {
+		for _, token := range r.Tokens {
+			switch t := token.(type) {
+			case ReturnToken32bit:
+				return strconv.FormatInt(int64(int32(t.ReturnValue)), 10) // see Outcome
+			case ReturnToken64bit:
+				return strconv.FormatInt(int64(t.ReturnValue), 10)
+			}
+		}
+		return ""
+	},
+	"text": func(r BsmRecord) string {
+		texts := []string{}
+		for _, token := range r.Tokens {
+			if t, ok := token.(TextToken); ok {
+				texts = append(texts, t.Text)
+			}
+		}
+		return strings.Join(texts, " ")
+	},
+}
+
+// csvSubjectField returns the field of the first subject token selected
+// by the given function.
+func csvSubjectField(r BsmRecord, field func(Subject) uint32) string {
+	if subject, ok := r.Subject(); ok {
+		return strconv.FormatUint(uint64(field(subject)), 10)
+	}
+	return ""
+}
+
+// WriteCSV writes the given records as CSV, one row per record, preceded
+// by a header row with the column names. The columns can be chosen from
+// timestamp, event, auid, uid, gid, ruid, rgid, pid, errno, return and
+// text; DefaultCSVColumns are used if none are given.
+func WriteCSV(w io.Writer, records []BsmRecord, columns ...string) error {
+	writer, err := NewCSVWriter(w, columns...)
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		if err := writer.Write(rec); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// CSVWriter writes records as CSV rows (see WriteCSV), e.g. records
+// received from a channel. The header row is written once when the
+// writer is created.
+type CSVWriter struct {
+	writer     *csv.Writer
+	extractors []func(BsmRecord) string
+}
+
+// NewCSVWriter returns a writer for the given columns (see WriteCSV)
+// after writing the header row.
+func NewCSVWriter(w io.Writer, columns ...string) (*CSVWriter, error) {
+	if 0 == len(columns) {
+		columns = DefaultCSVColumns
+	}
+	extractors := []func(BsmRecord) string{}
+	for _, column := range columns {
+		extractor, ok := csvColumns[column]
+		if !ok {
+			return nil, errors.New("unknown CSV column: " + column)
+		}
+		extractors = append(extractors, extractor)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(columns); err != nil {
+		return nil, err
+	}
+	return &CSVWriter{writer: writer, extractors: extractors}, nil
+}
+
+// Write writes the row of a single record (buffered until Flush).
+func (c *CSVWriter) Write(rec BsmRecord) error {
+	row := []string{}
+	for _, extractor := range c.extractors {
+		row = append(row, extractor(rec))
+	}
+	return c.writer.Write(row)
+}
+
+// Flush writes all buffered rows to the underlying writer.
+func (c *CSVWriter) Flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
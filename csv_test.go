@@ -0,0 +1,51 @@
+// test CSV representation of BSM records
+package bsm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	input := bytes.NewBuffer(rootLogin)
+	records := []BsmRecord{}
+	for i := 0; i < 2; i++ {
+		rec, err := ReadBsmRecord(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+
+	output := &bytes.Buffer{}
+	if err := WriteCSV(output, records[1:]); err != nil {
+		t.Fatal(err)
+	}
+	expected := "timestamp,event,uid,return,text\n" +
+		"2018-03-03T15:41:27.000000505Z,32800,0,0,successful login root\n"
+	if output.String() != expected {
+		t.Error("unexpected CSV output:\n" + output.String())
+	}
+
+	output.Reset()
+	if err := WriteCSV(output, records[:1], "auid", "pid", "errno"); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "auid,pid,errno\n4294967295,754,0\n" {
+		t.Error("unexpected CSV output:\n" + output.String())
+	}
+
+	// failed calls return -1 (see Outcome)
+	output.Reset()
+	failed := BsmRecord{Tokens: []Token{ReturnToken32bit{ErrorNumber: 13, ReturnValue: 0xffffffff}}}
+	if err := WriteCSV(output, []BsmRecord{failed}, "errno", "return"); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "errno,return\n13,-1\n" {
+		t.Error("unexpected CSV output:\n" + output.String())
+	}
+
+	if err := WriteCSV(output, records, "timestamp", "foo"); err == nil {
+		t.Error("expected an error on unknown column")
+	}
+}
@@ -0,0 +1,57 @@
+// Comparison of BSM records
+package bsm
+
+import (
+	"net"
+	"reflect"
+)
+
+// ipType is the type of IP address fields of tokens.
+var ipType = reflect.TypeOf(net.IP{})
+
+// Equal reports whether both records hold the same tokens. Unlike
+// reflect.DeepEqual, IP addresses are compared via net.IP.Equal, so an
+// IPv4 address equals its IPv4-mapped IPv6 (16 byte) form.
+func (r BsmRecord) Equal(other BsmRecord) bool {
+	if r.Seconds != other.Seconds || r.NanoSeconds != other.NanoSeconds || r.Trailer != other.Trailer {
+		return false
+	}
+	if !equalTokens(r.Header, other.Header) || len(r.Tokens) != len(other.Tokens) {
+		return false
+	}
+	for i := range r.Tokens {
+		if !equalTokens(r.Tokens[i], other.Tokens[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalTokens compares two tokens field by field.
+func equalTokens(a, b Token) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	va := reflect.ValueOf(a)
+	vb := reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+	if va.Kind() != reflect.Struct {
+		return reflect.DeepEqual(a, b)
+	}
+	for i := 0; i < va.NumField(); i++ {
+		fa := va.Field(i)
+		fb := vb.Field(i)
+		if fa.Type() == ipType {
+			if !fa.Interface().(net.IP).Equal(fb.Interface().(net.IP)) {
+				return false
+			}
+			continue
+		}
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			return false
+		}
+	}
+	return true
+}
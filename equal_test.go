@@ -0,0 +1,46 @@
+// test comparison of BSM records
+package bsm
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestBsmRecord_Equal(t *testing.T) {
+	subject := SubjectToken32bit{TokenID: 0x24, AuditID: 1001, TerminalMachineAddress: net.IP{93, 184, 216, 38}}
+	a := BsmRecord{
+		Header:  HeaderToken32bit{TokenID: 0x14, EventType: 6152},
+		Tokens:  []Token{subject, TextToken{TokenID: 0x28, Text: "login"}},
+		Trailer: TrailerToken{TokenID: 0x13, TrailerMagic: 0xb105},
+	}
+	b := a
+	b.Tokens = []Token{subject, a.Tokens[1]}
+	mapped := subject
+	mapped.TerminalMachineAddress = net.IPv4(93, 184, 216, 38) // 16 byte form
+	b.Tokens[0] = mapped
+
+	if reflect.DeepEqual(a, b) {
+		t.Fatal("expected reflect.DeepEqual to differ on the address form")
+	}
+	if !a.Equal(b) || !b.Equal(a) {
+		t.Error("expected records with equal addresses to be equal")
+	}
+
+	b.Tokens[1] = TextToken{TokenID: 0x28, Text: "logout"}
+	if a.Equal(b) {
+		t.Error("expected records with different texts to differ")
+	}
+	b.Tokens = b.Tokens[:1]
+	if a.Equal(b) {
+		t.Error("expected records with different token counts to differ")
+	}
+
+	// parsed records
+	first, _ := ParseAll(bytes.NewBuffer(rootLogin))
+	second, _ := ParseAll(bytes.NewBuffer(rootLogin))
+	if !first[1].Equal(second[1]) || first[0].Equal(second[1]) {
+		t.Error("unexpected comparison of parsed records")
+	}
+}
@@ -0,0 +1,113 @@
+// Filters over streams of BSM records
+package bsm
+
+import (
+	"fmt"
+	"time"
+)
+
+// Filter passes through all records for which the given predicate is
+// true, which allows arbitrary queries (e.g. failed records of a user
+// within a time range). The returned channel is closed once the input
+// channel is closed.
+func Filter(records <-chan BsmRecord, pred func(BsmRecord) bool) <-chan BsmRecord {
+	output := make(chan BsmRecord)
+	go func() {
+		defer close(output)
+		for rec := range records {
+			if pred(rec) {
+				output <- rec
+			}
+		}
+	}()
+	return output
+}
+
+// FilterByEventType passes through all records whose header token (of
+// any variant) has one of the given event types. The returned channel
+// is closed once the input channel is closed.
+func FilterByEventType(records <-chan BsmRecord, ids ...uint16) <-chan BsmRecord {
+	return Filter(records, eventTypeFilter(ids...))
+}
+
+// eventTypeFilter returns the predicate of FilterByEventType.
+func eventTypeFilter(ids ...uint16) func(BsmRecord) bool {
+	wanted := map[uint16]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	return func(rec BsmRecord) bool {
+		event, ok := rec.EventType()
+		return ok && wanted[event]
+	}
+}
+
+// UIDKind selects which user ID of a subject token is compared.
+type UIDKind int
+
+// user IDs of subject tokens
+const (
+	AuditUID     UIDKind = iota // audit user ID
+	EffectiveUID                // effective user ID
+	RealUID                     // real user ID
+)
+
+// subjectUIDs maps the user ID kinds to the user IDs of a subject.
+var subjectUIDs = map[UIDKind]func(Subject) uint32{
+	AuditUID:     func(s Subject) uint32 { return s.AuditID },
+	EffectiveUID: func(s Subject) uint32 { return s.EffectiveUserID },
+	RealUID:      func(s Subject) uint32 { return s.RealUserID },
+}
+
+// FilterByUID passes through all records whose subject token (of any
+// variant) has the given user ID of the given kind. Records without a
+// subject token are dropped. The returned channel is closed once the
+// input channel is closed. An unknown kind results in an error (and the
+// input channel is not consumed).
+func FilterByUID(records <-chan BsmRecord, uid uint32, kind UIDKind) (<-chan BsmRecord, error) {
+	subjectUID, known := subjectUIDs[kind]
+	if !known {
+		return nil, fmt.Errorf("unknown user ID kind %d", kind)
+	}
+	return Filter(records, func(rec BsmRecord) bool {
+		subject, ok := rec.Subject()
+		return ok && subjectUID(subject) == uid
+	}), nil
+}
+
+// FilterByTimeRange passes through all records whose header time stamp
+// lies within [start, end). Records without a header token are dropped.
+// The returned channel is closed once the input channel is closed.
+func FilterByTimeRange(records <-chan BsmRecord, start, end time.Time) <-chan BsmRecord {
+	return Filter(records, func(rec BsmRecord) bool {
+		header, ok := rec.Header.(interface {
+			Timestamp() time.Time
+		})
+		if !ok {
+			return false
+		}
+		timestamp := header.Timestamp()
+		return !timestamp.Before(start) && timestamp.Before(end)
+	})
+}
+
+// Dedup passes through all records except those equal (see Equal) to the
+// immediately preceding record, e.g. duplicates emitted by producers on
+// retries. Only the previous record is kept. The returned channel is
+// closed once the input channel is closed.
+func Dedup(records <-chan BsmRecord) <-chan BsmRecord {
+	output := make(chan BsmRecord)
+	go func() {
+		defer close(output)
+		var previous BsmRecord
+		first := true
+		for rec := range records {
+			if !first && rec.Equal(previous) {
+				continue
+			}
+			output <- rec
+			previous, first = rec, false
+		}
+	}()
+	return output
+}
@@ -0,0 +1,147 @@
+// test filters over streams of BSM records
+package bsm
+
+import (
+	"testing"
+	"time"
+)
+
+// recordStream sends the given records over a (closed) channel.
+func recordStream(records ...BsmRecord) <-chan BsmRecord {
+	stream := make(chan BsmRecord, len(records))
+	for _, rec := range records {
+		stream <- rec
+	}
+	close(stream)
+	return stream
+}
+
+func TestFilterByEventType(t *testing.T) {
+	stream := recordStream(
+		BsmRecord{Header: HeaderToken32bit{TokenID: 0x14, EventType: 6152}},
+		BsmRecord{Header: HeaderToken64bit{TokenID: 0x74, EventType: 45000}},
+		BsmRecord{Header: ExpandedHeaderToken32bit{TokenID: 0x15, EventType: 6153}},
+		BsmRecord{Header: ExpandedHeaderToken64bit{TokenID: 0x79, EventType: 6152}},
+		BsmRecord{}, // no header at all
+	)
+	events := []uint16{}
+	for rec := range FilterByEventType(stream, 6152, 6153) {
+		event, _ := rec.EventType()
+		events = append(events, event)
+	}
+	if len(events) != 3 || events[0] != 6152 || events[1] != 6153 || events[2] != 6152 {
+		t.Error("unexpected events after filtering:", events)
+	}
+}
+
+func TestFilterByUID(t *testing.T) {
+	records := []BsmRecord{
+		{Tokens: []Token{SubjectToken32bit{TokenID: 0x24, AuditID: 1001, EffectiveUserID: 0, RealUserID: 1001}}},
+		{Tokens: []Token{TextToken{TokenID: 0x28}, SubjectToken64bit{TokenID: 0x75, AuditID: 1002, EffectiveUserID: 1002, RealUserID: 1002}}},
+		{Tokens: []Token{ExpandedSubjectToken32bit{TokenID: 0x7a, AuditID: 1001, EffectiveUserID: 1001, RealUserID: 1001}}},
+		{Tokens: []Token{ExpandedSubjectToken64bit{TokenID: 0x7c, AuditID: 0, EffectiveUserID: 0, RealUserID: 0}}},
+		{Tokens: []Token{TextToken{TokenID: 0x28}}}, // no subject at all
+	}
+	count := func(uid uint32, kind UIDKind) int {
+		filtered, err := FilterByUID(recordStream(records...), uid, kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for range filtered {
+			n += 1
+		}
+		return n
+	}
+
+	if n := count(1001, AuditUID); n != 2 {
+		t.Error("expected 2 records with audit user 1001, got", n)
+	}
+	if n := count(1001, EffectiveUID); n != 1 {
+		t.Error("expected 1 record with effective user 1001, got", n)
+	}
+	if n := count(0, EffectiveUID); n != 2 {
+		t.Error("expected 2 records with effective user 0, got", n)
+	}
+	if n := count(1002, RealUID); n != 1 {
+		t.Error("expected 1 record with real user 1002, got", n)
+	}
+	if n := count(4242, RealUID); n != 0 {
+		t.Error("expected no records with real user 4242, got", n)
+	}
+
+	if _, err := FilterByUID(recordStream(records...), 1001, UIDKind(42)); err == nil {
+		t.Error("expected an error on unknown user ID kind")
+	}
+}
+
+func TestFilterByTimeRange(t *testing.T) {
+	stream := recordStream(
+		BsmRecord{Header: HeaderToken32bit{TokenID: 0x14, EventType: 1, Seconds: 1000}},
+		BsmRecord{Header: HeaderToken64bit{TokenID: 0x74, EventType: 2, Seconds: 2000}},
+		BsmRecord{Header: HeaderToken32bit{TokenID: 0x14, EventType: 3, Seconds: 3000}},
+		BsmRecord{Seconds: 2000}, // no header
+	)
+	events := []uint16{}
+	for rec := range FilterByTimeRange(stream, time.Unix(1000, 0), time.Unix(3000, 0)) {
+		event, _ := rec.EventType()
+		events = append(events, event)
+	}
+	// inclusive start, exclusive end
+	if len(events) != 2 || events[0] != 1 || events[1] != 2 {
+		t.Error("unexpected events after filtering:", events)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	login := BsmRecord{
+		Header: HeaderToken32bit{TokenID: 0x14, EventType: 6152, Seconds: 1520091878},
+		Tokens: []Token{TextToken{TokenID: 0x28, Text: "successful login"}},
+	}
+	logout := BsmRecord{Header: HeaderToken32bit{TokenID: 0x14, EventType: 6153, Seconds: 1520091879}}
+	events := []uint16{}
+	for rec := range Dedup(recordStream(login, login, logout, login)) {
+		event, _ := rec.EventType()
+		events = append(events, event)
+	}
+	// only consecutive duplicates are dropped
+	if len(events) != 3 || events[0] != 6152 || events[1] != 6153 || events[2] != 6152 {
+		t.Error("unexpected events after de-duplication:", events)
+	}
+
+	// a record equal to the zero value is passed as well
+	count := 0
+	for range Dedup(recordStream(BsmRecord{}, BsmRecord{})) {
+		count += 1
+	}
+	if count != 1 {
+		t.Error("expected a single record, got", count)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	record := func(event uint16, errno uint8) BsmRecord {
+		return BsmRecord{
+			Header: HeaderToken32bit{TokenID: 0x14, EventType: event},
+			Tokens: []Token{ReturnToken32bit{TokenID: 0x27, ErrorNumber: errno}},
+		}
+	}
+	stream := recordStream(record(6152, 0), record(6152, 1), record(6153, 1), BsmRecord{})
+
+	// failed logins
+	failedLogins := func(rec BsmRecord) bool {
+		event, _ := rec.EventType()
+		success, _, _, ok := rec.Outcome()
+		return event == 6152 && ok && !success
+	}
+	records := []BsmRecord{}
+	for rec := range Filter(stream, failedLogins) {
+		records = append(records, rec)
+	}
+	if len(records) != 1 {
+		t.Fatal("expected a single failed login, got", records)
+	}
+	if _, errno, _, _ := records[0].Outcome(); errno != 1 {
+		t.Error("unexpected error number:", errno)
+	}
+}
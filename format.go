@@ -0,0 +1,305 @@
+// Human-readable representation of BSM tokens
+package bsm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tokenTypeNames maps token IDs to short type names.
+var tokenTypeNames = map[byte]string{
+	0x11: "file",
+	0x13: "trailer",
+	0x14: "header32",
+	0x15: "expanded_header32",
+	0x21: "arbitrary",
+	0x22: "sysv_ipc",
+	0x23: "path",
+	0x24: "subject32",
+	0x25: "path_attr",
+	0x26: "process32",
+	0x27: "return32",
+	0x28: "text",
+	0x2a: "in_addr",
+	0x2b: "ip",
+	0x2c: "iport",
+	0x2d: "arg32",
+	0x2e: "socket",
+	0x2f: "seq",
+	0x32: "sysv_ipc_perm",
+	0x34: "groups",
+	0x3c: "exec_args",
+	0x3d: "exec_env",
+	0x3e: "attr32",
+	0x52: "exit",
+	0x60: "zonename",
+	0x71: "arg64",
+	0x72: "return64",
+	0x73: "attr64",
+	0x74: "header64",
+	0x75: "subject64",
+	0x77: "process64",
+	0x79: "expanded_header64",
+	0x7a: "expanded_subject32",
+	0x7b: "expanded_process32",
+	0x7c: "expanded_subject64",
+	0x7d: "expanded_process64",
+	0x7e: "expanded_in_addr",
+	0x7f: "expanded_socket",
+	0x80: "socket_inet32",
+	0x81: "socket_inet128",
+	0x82: "socket_unix",
+}
+
+// TokenTypeName returns the short type name of the token with the given
+// ID (e.g. header32 or expanded_socket), as used for the "type" field of
+// the JSON and XML representations. An empty string is returned for
+// unknown token IDs.
+func TokenTypeName(id byte) string {
+	return tokenTypeNames[id]
+}
+
+func (t ArgToken32bit) String() string {
+	return fmt.Sprintf("arg32 id=%d value=0x%x text=%q", t.ArgumentID, t.ArgumentValue, t.Text)
+}
+
+func (t ArgToken64bit) String() string {
+	return fmt.Sprintf("arg64 id=%d value=0x%x text=%q", t.ArgumentID, t.ArgumentValue, t.Text)
+}
+
+func (t ArbitraryDataToken) String() string {
+	return fmt.Sprintf("arbitrary print=%d unit=%d count=%d data=%x", t.HowToPrint, t.BasicUnit, t.UnitCount, t.DataItems)
+}
+
+func (t AttributeToken32bit) String() string {
+	return fmt.Sprintf("attr32 mode=%o uid=%d gid=%d fsid=%d inode=%d device=%d",
+		t.FileAccessMode, t.OwnerUserID, t.OwnerGroupID, t.FileSystemID, t.FileSystemNodeID, t.Device)
+}
+
+func (t AttributeToken64bit) String() string {
+	return fmt.Sprintf("attr64 mode=%o uid=%d gid=%d fsid=%d inode=%d device=%d",
+		t.FileAccessMode, t.OwnerUserID, t.OwnerGroupID, t.FileSystemID, t.FileSystemNodeID, t.Device)
+}
+
+func (t ExecArgsToken) String() string {
+	return fmt.Sprintf("exec_args count=%d %s", t.Count, quoteAll(t.Text))
+}
+
+func (t ExecEnvToken) String() string {
+	return fmt.Sprintf("exec_env count=%d %s", t.Count, quoteAll(t.Text))
+}
+
+func (t ExitToken) String() string {
+	return fmt.Sprintf("exit status=%d return=%d", t.Status, t.ReturnValue)
+}
+
+func (t FileToken) String() string {
+	return fmt.Sprintf("file time=%d.%06d path=%q", t.Seconds, t.Microseconds, t.PathName)
+}
+
+func (t GroupsToken) String() string {
+	return fmt.Sprintf("groups count=%d %v", t.NumberOfGroups, t.GroupList)
+}
+
+func (t HeaderToken32bit) String() string {
+	return fmt.Sprintf("header32 record=%d version=%d event=%d modifier=%d time=%d.%09d",
+		t.RecordByteCount, t.VersionNumber, t.EventType, t.EventModifier, t.Seconds, t.NanoSeconds)
+}
+
+func (t HeaderToken64bit) String() string {
+	return fmt.Sprintf("header64 record=%d version=%d event=%d modifier=%d time=%d.%09d",
+		t.RecordByteCount, t.VersionNumber, t.EventType, t.EventModifier, t.Seconds, t.NanoSeconds)
+}
+
+func (t ExpandedHeaderToken32bit) String() string {
+	return fmt.Sprintf("expanded_header32 record=%d version=%d event=%d modifier=%d machine=%s time=%d.%09d",
+		t.RecordByteCount, t.VersionNumber, t.EventType, t.EventModifier, t.MachineAddress, t.Seconds, t.NanoSeconds)
+}
+
+func (t ExpandedHeaderToken64bit) String() string {
+	return fmt.Sprintf("expanded_header64 record=%d version=%d event=%d modifier=%d machine=%s time=%d.%09d",
+		t.RecordByteCount, t.VersionNumber, t.EventType, t.EventModifier, t.MachineAddress, t.Seconds, t.NanoSeconds)
+}
+
+func (t InAddrToken) String() string {
+	return fmt.Sprintf("in_addr %s", t.IpAddress)
+}
+
+func (t ExpandedInAddrToken) String() string {
+	return fmt.Sprintf("expanded_in_addr type=%d %s", t.IpAddressType, t.IpAddress)
+}
+
+func (t IpToken) String() string {
+	return fmt.Sprintf("ip version_ihl=0x%02x tos=%d length=%d id=%d offset=%d ttl=%d protocol=%s checksum=0x%04x src=%s dst=%s",
+		t.VersionAndIHL, t.TypeOfService, t.Length, t.ID, t.Offset, t.TTL, ProtocolName(t.Protocol), t.Checksum,
+		t.SourceAddress, t.DestinationAddress)
+}
+
+func (t IPortToken) String() string {
+	return fmt.Sprintf("iport %d", t.PortNumber)
+}
+
+func (t PathToken) String() string {
+	return fmt.Sprintf("path %q", t.Path)
+}
+
+func (t PathAttrToken) String() string {
+	return fmt.Sprintf("path_attr count=%d %s", t.Count, quoteAll(t.Path))
+}
+
+func (t ProcessToken32bit) String() string {
+	return fmt.Sprintf("process32 auid=%d euid=%d egid=%d ruid=%d rgid=%d pid=%d sid=%d port=%d machine=%s",
+		t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+		t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress)
+}
+
+func (t ProcessToken64bit) String() string {
+	return fmt.Sprintf("process64 auid=%d euid=%d egid=%d ruid=%d rgid=%d pid=%d sid=%d port=%d machine=%s",
+		t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+		t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress)
+}
+
+func (t ExpandedProcessToken32bit) String() string {
+	return fmt.Sprintf("expanded_process32 auid=%d euid=%d egid=%d ruid=%d rgid=%d pid=%d sid=%d port=%d machine=%s",
+		t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+		t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress)
+}
+
+func (t ExpandedProcessToken64bit) String() string {
+	return fmt.Sprintf("expanded_process64 auid=%d euid=%d egid=%d ruid=%d rgid=%d pid=%d sid=%d port=%d machine=%s",
+		t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+		t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress)
+}
+
+func (t RawToken) String() string {
+	return fmt.Sprintf("raw id=0x%02x size=%d data=%x", t.TokenID, len(t.Raw), t.Raw)
+}
+
+func (t ReturnToken32bit) String() string {
+	return fmt.Sprintf("return32 errno=%d value=%d", t.ErrorNumber, t.ReturnValue)
+}
+
+func (t ReturnToken64bit) String() string {
+	return fmt.Sprintf("return64 errno=%d value=%d", t.ErrorNumber, t.ReturnValue)
+}
+
+func (t SeqToken) String() string {
+	return fmt.Sprintf("seq %d", t.SequenceNumber)
+}
+
+func (t SocketToken) String() string {
+	return fmt.Sprintf("socket family=%d port=%d address=%s", t.SocketFamily, t.LocalPort, t.SocketAddress)
+}
+
+func (t SocketInet32Token) String() string {
+	return fmt.Sprintf("socket_inet32 family=%d port=%d address=%s", t.SocketFamily, t.LocalPort, t.SocketAddress)
+}
+
+func (t SocketInet128Token) String() string {
+	return fmt.Sprintf("socket_inet128 family=%d port=%d address=%s", t.SocketFamily, t.LocalPort, t.SocketAddress)
+}
+
+func (t SocketUnixToken) String() string {
+	return fmt.Sprintf("socket_unix family=%d path=%q", t.SocketFamily, t.Path)
+}
+
+func (t ExpandedSocketToken) String() string {
+	return fmt.Sprintf("expanded_socket domain=%d type=%d local=%s:%d remote=%s:%d",
+		t.SocketDomain, t.SocketType, t.LocalIpAddress, t.LocalPort, t.RemoteIpAddress, t.RemotePort)
+}
+
+func (t SubjectToken32bit) String() string {
+	return fmt.Sprintf("subject32 auid=%d euid=%d egid=%d ruid=%d rgid=%d pid=%d sid=%d port=%d machine=%s",
+		t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+		t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress)
+}
+
+func (t SubjectToken64bit) String() string {
+	return fmt.Sprintf("subject64 auid=%d euid=%d egid=%d ruid=%d rgid=%d pid=%d sid=%d port=%d machine=%s",
+		t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+		t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress)
+}
+
+func (t ExpandedSubjectToken32bit) String() string {
+	return fmt.Sprintf("expanded_subject32 auid=%d euid=%d egid=%d ruid=%d rgid=%d pid=%d sid=%d port=%d machine=%s",
+		t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+		t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress)
+}
+
+func (t ExpandedSubjectToken64bit) String() string {
+	return fmt.Sprintf("expanded_subject64 auid=%d euid=%d egid=%d ruid=%d rgid=%d pid=%d sid=%d port=%d machine=%s",
+		t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+		t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress)
+}
+
+func (t SystemVIpcToken) String() string {
+	return fmt.Sprintf("sysv_ipc type=%d id=%d", t.ObjectIdType, t.ObjectID)
+}
+
+func (t SystemVIpcPermissionToken) String() string {
+	return fmt.Sprintf("sysv_ipc_perm uid=%d gid=%d cuid=%d cgid=%d mode=%o seq=%d key=0x%x",
+		t.OwnerUserID, t.OwnerGroupID, t.CreatorUserID, t.CreatorGroupID, t.AccessMode, t.SequenceNumber, t.Key)
+}
+
+func (t TextToken) String() string {
+	return fmt.Sprintf("text %q", t.Text)
+}
+
+func (t TrailerToken) String() string {
+	return fmt.Sprintf("trailer magic=0x%04x record=%d", t.TrailerMagic, t.RecordByteCount)
+}
+
+func (t ZonenameToken) String() string {
+	return fmt.Sprintf("zonename %q", t.Zonename)
+}
+
+// FormatFileMode renders the permission bits of the given mode_t (e.g.
+// FileAccessMode of the attribute tokens) in the style of ls(1), i.e.
+// "rwxr-xr-x". The setuid, setgid and sticky bits are shown as s/S
+// and t/T. The attribute tokens store the complete 4 byte mode_t, so
+// all permission bits (07777) are preserved, the file type bits
+// (0170000) are ignored here.
+func FormatFileMode(mode uint32) string {
+	const rwx = "rwxrwxrwx"
+	buf := []byte("---------")
+	for i := 0; i < 9; i++ {
+		if mode&(1<<uint(8-i)) != 0 {
+			buf[i] = rwx[i]
+		}
+	}
+	special := []struct {
+		bit   uint32
+		index int
+		char  byte
+	}{
+		{04000, 2, 's'}, // setuid
+		{02000, 5, 's'}, // setgid
+		{01000, 8, 't'}, // sticky
+	}
+	for _, s := range special {
+		if mode&s.bit == 0 {
+			continue
+		}
+		if buf[s.index] == 'x' {
+			buf[s.index] = s.char
+		} else {
+			buf[s.index] = s.char - ('a' - 'A') // not executable -> upper case
+		}
+	}
+	return string(buf)
+}
+
+// FormatAccessMode returns the permission bits of the IPC object in the
+// style of ls(1), e.g. "rw-rw----" (see FormatFileMode).
+func (t SystemVIpcPermissionToken) FormatAccessMode() string {
+	return FormatFileMode(t.AccessMode)
+}
+
+// quoteAll quotes every given string and joins them with a space.
+func quoteAll(texts []string) string {
+	quoted := make([]string, len(texts))
+	for i, text := range texts {
+		quoted[i] = fmt.Sprintf("%q", text)
+	}
+	return strings.Join(quoted, " ")
+}
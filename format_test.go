@@ -0,0 +1,95 @@
+// test human-readable representation of BSM tokens
+package bsm
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestHeaderToken32bit_String(t *testing.T) {
+	token := HeaderToken32bit{
+		TokenID:         0x14,
+		RecordByteCount: 56,
+		VersionNumber:   11,
+		EventType:       51200,
+		EventModifier:   90,
+		Seconds:         1520092902,
+		NanoSeconds:     769,
+	}
+	expected := "header32 record=56 version=11 event=51200 modifier=90 time=1520092902.000000769"
+	if token.String() != expected {
+		t.Error("unexpected string: " + token.String())
+	}
+}
+
+func TestTextToken_String(t *testing.T) {
+	token := TextToken{
+		TokenID:    0x28,
+		TextLength: 22,
+		Text:       "auditd::Audit startup",
+	}
+	expected := "text \"auditd::Audit startup\""
+	if fmt.Sprint(token) != expected {
+		t.Error("unexpected string: " + fmt.Sprint(token))
+	}
+}
+
+func TestSubjectToken32bit_String(t *testing.T) {
+	token := SubjectToken32bit{
+		TokenID:                0x24,
+		AuditID:                1001,
+		ProcessID:              754,
+		SessionID:              754,
+		TerminalMachineAddress: net.IPv4(93, 184, 216, 38),
+	}
+	expected := "subject32 auid=1001 euid=0 egid=0 ruid=0 rgid=0 pid=754 sid=754 port=0 machine=93.184.216.38"
+	if token.String() != expected {
+		t.Error("unexpected string: " + token.String())
+	}
+}
+
+func TestFormatFileMode(t *testing.T) {
+	testData := map[uint32]string{
+		0644:    "rw-r--r--",
+		0755:    "rwxr-xr-x",
+		04755:   "rwsr-xr-x",
+		02750:   "rwxr-s---",
+		01777:   "rwxrwxrwt",
+		04644:   "rwSr--r--",
+		0100600: "rw-------", // regular file
+		0:       "---------",
+	}
+	for mode, expected := range testData {
+		if FormatFileMode(mode) != expected {
+			t.Errorf("mode %o: expected %s, got %s", mode, expected, FormatFileMode(mode))
+		}
+	}
+}
+
+func TestSystemVIpcPermissionToken_FormatAccessMode(t *testing.T) {
+	token := SystemVIpcPermissionToken{TokenID: 0x32, AccessMode: 0660}
+	if token.FormatAccessMode() != "rw-rw----" {
+		t.Error("unexpected access mode: " + token.FormatAccessMode())
+	}
+}
+
+func TestTokenTypeName(t *testing.T) {
+	testData := map[byte]string{
+		0x13: "trailer",
+		0x14: "header32",
+		0x24: "subject32",
+		0x28: "text",
+		0x74: "header64",
+		0x75: "subject64",
+		0x7f: "expanded_socket",
+		0x82: "socket_unix",
+		0x00: "", // unknown
+		0xff: "",
+	}
+	for id, name := range testData {
+		if TokenTypeName(id) != name {
+			t.Errorf("token 0x%02x: expected %q, got %q", id, name, TokenTypeName(id))
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// fuzz testing of the token parser
+package bsm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+// FuzzTokenFromByteInput makes sure that arbitrary input results in
+// errors, but never in a panic. Run it via
+//
+//	go test -fuzz=FuzzTokenFromByteInput
+func FuzzTokenFromByteInput(f *testing.F) {
+	f.Add(rootLogin)
+	if data, err := ioutil.ReadFile("start_stop.bsm"); err == nil {
+		f.Add(data)
+	}
+	f.Add([]byte{0x28, 0x00, 0x00})                   // empty text
+	f.Add([]byte{0x3c, 0x00, 0x00, 0x00, 0x00})       // exec args without arguments
+	f.Add([]byte{0x82, 0x00, 0x01, 0x2f, 0x00})       // unix socket
+	f.Add([]byte{0x7a, 0x00, 0x00, 0x00, 0x00, 0x10}) // truncated expanded subject
+	f.Add([]byte("-00000\x00\x00"))                   // arg32 with zero length text
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		input := bytes.NewBuffer(data)
+		for {
+			if _, err := TokenFromByteInput(input); err != nil {
+				break
+			}
+		}
+		for result := range RecordGenerator(bytes.NewBuffer(data)) {
+			_ = result
+		}
+	})
+}
@@ -0,0 +1,53 @@
+// IP headers of ip tokens
+package bsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Version returns the IP version (high nibble of VersionAndIHL).
+func (t IpToken) Version() uint8 {
+	return t.VersionAndIHL >> 4
+}
+
+// HeaderLength returns the length of the IP header in bytes. The low
+// nibble of VersionAndIHL holds it in 32 bit words (e.g. 5 for 20 bytes).
+func (t IpToken) HeaderLength() uint8 {
+	return (t.VersionAndIHL & 0x0f) * 4
+}
+
+// VerifyChecksum recomputes the (one's complement) checksum of the IP
+// header held by the token and compares it with Checksum. The parser
+// doesn't verify checksums, as audit records may legitimately hold a
+// zeroed checksum, so this is opt-in (e.g. to detect corrupted captures).
+// Headers with options can't be verified, as the token holds the fixed
+// part of the header only.
+func (t IpToken) VerifyChecksum() error {
+	if t.HeaderLength() != 20 {
+		return fmt.Errorf("can't verify checksum of IP header with options (%d bytes)", t.HeaderLength())
+	}
+	source, destination := t.SourceAddress.To4(), t.DestinationAddress.To4()
+	if source == nil || destination == nil {
+		return errors.New("can't verify checksum of IP header without IPv4 addresses")
+	}
+	header := []byte{t.VersionAndIHL, t.TypeOfService}
+	header = appendUint16(header, t.Length)
+	header = appendUint16(header, t.ID)
+	header = appendUint16(header, t.Offset)
+	header = append(header, t.TTL, t.Protocol)
+	header = appendUint16(header, 0) // checksum field is zero for the computation
+	header = append(append(header, source...), destination...)
+
+	sum := uint32(0)
+	for i := 0; i < len(header); i += 2 {
+		sum += uint32(header[i])<<8 | uint32(header[i+1])
+	}
+	for sum > 0xffff {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	if checksum := ^uint16(sum); checksum != t.Checksum {
+		return fmt.Errorf("IP header checksum mismatch: expected 0x%04x, got 0x%04x", checksum, t.Checksum)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// test IP headers of ip tokens
+package bsm
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIpToken_VersionAndIHL(t *testing.T) {
+	token := IpToken{VersionAndIHL: 0x45}
+	if token.Version() != 4 || token.HeaderLength() != 20 {
+		t.Error("unexpected version or header length:", token.Version(), token.HeaderLength())
+	}
+	token.VersionAndIHL = 0x4f // maximum header length with options
+	if token.Version() != 4 || token.HeaderLength() != 60 {
+		t.Error("unexpected version or header length:", token.Version(), token.HeaderLength())
+	}
+}
+
+func TestIpToken_VerifyChecksum(t *testing.T) {
+	// 4500 0073 0000 4000 4011 b861 c0a8 0001 c0a8 00c7
+	token := IpToken{
+		VersionAndIHL:      0x45,
+		Length:             0x0073,
+		Offset:             0x4000,
+		TTL:                0x40,
+		Protocol:           17,
+		Checksum:           0xb861,
+		SourceAddress:      net.IP{192, 168, 0, 1},
+		DestinationAddress: net.IP{192, 168, 0, 199},
+	}
+	if err := token.VerifyChecksum(); err != nil {
+		t.Error(err)
+	}
+	token.Checksum = 0xb862
+	if err := token.VerifyChecksum(); err == nil {
+		t.Error("expected an error on wrong checksum")
+	}
+	token.Checksum = 0 // zeroed checksum is a mismatch as well
+	if err := token.VerifyChecksum(); err == nil {
+		t.Error("expected an error on zeroed checksum")
+	}
+	token.VersionAndIHL = 0x46
+	if err := token.VerifyChecksum(); err == nil {
+		t.Error("expected an error on header with options")
+	}
+}
@@ -0,0 +1,94 @@
+// JSON representation of BSM records
+package bsm
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// MarshalJSON renders the record as JSON object. All tokens (including
+// header and trailer) are listed in the "tokens" array, each of them
+// tagged with its type in the "type" field. All keys are in snake case
+// (see the json tags of the token structs). IP addresses are rendered
+// as strings and time stamps in RFC3339 format.
+func (r BsmRecord) MarshalJSON() ([]byte, error) {
+	tokens := []map[string]interface{}{}
+	all := []Token{}
+	if r.Header != nil {
+		all = append(all, r.Header)
+	}
+	all = append(all, r.Tokens...)
+	if r.Trailer.TokenID != 0 {
+		all = append(all, r.Trailer)
+	}
+	for _, token := range all {
+		fields, err := tokenToMap(token)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, fields)
+	}
+
+	return json.Marshal(struct {
+		Timestamp string                   `json:"timestamp"`
+		Tokens    []map[string]interface{} `json:"tokens"`
+	}{
+		Timestamp: time.Unix(int64(r.Seconds), int64(r.NanoSeconds)).UTC().Format(time.RFC3339Nano),
+		Tokens:    tokens,
+	})
+}
+
+// WriteJSONLines writes each record received from the channel as JSON
+// object (see MarshalJSON) on a line of its own, until the channel is
+// closed. Each line is written (and flushed if w has a Flush method, e.g.
+// *bufio.Writer) as soon as the record is received. It returns at the
+// first error without receiving further records, so the producer should
+// be cancelled then (e.g. the context given to TailRecords).
+func WriteJSONLines(w io.Writer, records <-chan BsmRecord) error {
+	flusher, canFlush := w.(interface{ Flush() error })
+	for rec := range records {
+		if err := writeJSONLine(w, rec); err != nil {
+			return err
+		}
+		if canFlush {
+			if err := flusher.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeJSONLine writes the given record as JSON object on a line of its own.
+func writeJSONLine(w io.Writer, rec BsmRecord) error {
+	data, err := rec.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// tokenToMap converts the given token into a map of its fields
+// (as they would be marshaled to JSON) plus its type.
+func tokenToMap(token Token) (map[string]interface{}, error) {
+	raw, err := json.Marshal(token)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber() // don't lose precision of 64 bit values
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	fields["type"] = TokenTypeName(token.Type())
+	if stamped, ok := token.(interface {
+		Timestamp() time.Time
+	}); ok {
+		fields["timestamp"] = stamped.Timestamp().Format(time.RFC3339Nano)
+	}
+	return fields, nil
+}
@@ -0,0 +1,147 @@
+// test JSON representation of BSM records
+package bsm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestBsmRecord_MarshalJSON(t *testing.T) {
+	data := []byte{
+		0x14,                   // --- 32bit header token ID
+		0x00, 0x00, 0x00, 0x38, // 56 bytes in record
+		0x0b,       // version number
+		0xaf, 0xc8, // event type
+		0x00, 0x00, // event modifier / sub-type
+		0x5a, 0x9a, 0xc2, 0xe6, // timestamp seconds
+		0x00, 0x00, 0x03, 0x01, // timestamp nanoseconds
+		0x28,       // --- text token ID
+		0x00, 0x16, // string length (22 bytes)
+		0x61, 0x75, 0x64, 0x69, // actual string
+		0x74, 0x64, 0x3a, 0x3a,
+		0x41, 0x75, 0x64, 0x69,
+		0x74, 0x20, 0x73, 0x74,
+		0x61, 0x72, 0x74, 0x75,
+		0x70, 0x00,
+		0x27,                   // --- return token ID
+		0x00,                   // error number
+		0x00, 0x00, 0x00, 0x00, // return value
+		0x13,       // --- trailer token ID
+		0xb1, 0x05, // trailer magic
+		0x00, 0x00, 0x00, 0x38, // record byte count (56 bytes)
+	}
+	rec, err := ReadBsmRecord(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := struct {
+		Timestamp string
+		Tokens    []map[string]interface{}
+	}{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Timestamp != "2018-03-03T15:44:38.000000769Z" {
+		t.Error("unexpected record time stamp: " + decoded.Timestamp)
+	}
+	if len(decoded.Tokens) != 4 {
+		t.Fatal("expected header, text, return and trailer token, got", string(raw))
+	}
+	header := decoded.Tokens[0]
+	if header["type"] != "header32" {
+		t.Error("unexpected header type:", header["type"])
+	}
+	if header["record_byte_count"] != 56.0 {
+		t.Error("unexpected record byte count:", header["record_byte_count"])
+	}
+	if header["event_type"] != 45000.0 {
+		t.Error("unexpected event type:", header["event_type"])
+	}
+	if header["timestamp"] != "2018-03-03T15:44:38.000000769Z" {
+		t.Error("unexpected header time stamp:", header["timestamp"])
+	}
+	text := decoded.Tokens[1]
+	if text["type"] != "text" || text["text"] != "auditd::Audit startup" {
+		t.Error("unexpected text token:", text)
+	}
+	if decoded.Tokens[3]["type"] != "trailer" {
+		t.Error("unexpected trailer token:", decoded.Tokens[3])
+	}
+}
+
+func TestBsmRecord_MarshalJSON_ip(t *testing.T) {
+	rec := BsmRecord{
+		Tokens: []Token{SocketInet32Token{TokenID: 0x80, SocketAddress: []byte{192, 168, 1, 10}}},
+	}
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(raw, []byte(`"socket_address":"192.168.1.10"`)) {
+		t.Error("IP address not rendered as string: " + string(raw))
+	}
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	records, err := ParseAll(bytes.NewBuffer(rootLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := &bytes.Buffer{}
+	if err := WriteJSONLines(output, recordStream(records[0], records[1])); err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSuffix(output.Bytes(), []byte("\n")), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	for i, line := range lines {
+		var object struct {
+			Tokens []map[string]interface{} `json:"tokens"`
+		}
+		if err := json.Unmarshal(line, &object); err != nil {
+			t.Fatal(err)
+		}
+		if len(object.Tokens) != len(records[i].Tokens)+2 {
+			t.Errorf("line %d: unexpected number of tokens %d", i, len(object.Tokens))
+		}
+	}
+}
+
+// failingWriter fails all writes.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestWriteJSONLines_error(t *testing.T) {
+	records, err := ParseAll(bytes.NewBuffer(rootLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the stream never ends (like TailRecords), the error is still returned
+	stream := make(chan BsmRecord)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for {
+			select {
+			case stream <- records[0]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	if err := WriteJSONLines(failingWriter{}, stream); err == nil {
+		t.Error("expected a write error")
+	}
+}
@@ -0,0 +1,391 @@
+// Serialization of BSM tokens
+package bsm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+)
+
+// Marshaler is implemented by all tokens which can be serialized.
+type Marshaler interface {
+	Marshal() ([]byte, error) // serialized token (big endian)
+}
+
+// appendUint16 appends the given value in big endian byte order.
+func appendUint16(output []byte, value uint16) []byte {
+	return append(output, byte(value>>8), byte(value))
+}
+
+// appendUint32 appends the given value in big endian byte order.
+func appendUint32(output []byte, value uint32) []byte {
+	return append(output, byte(value>>24), byte(value>>16), byte(value>>8), byte(value))
+}
+
+// appendUint64 appends the given value in big endian byte order.
+func appendUint64(output []byte, value uint64) []byte {
+	output = appendUint32(output, uint32(value>>32))
+	return appendUint32(output, uint32(value))
+}
+
+// Marshal serializes the 32 bit header token (18 bytes).
+func (t HeaderToken32bit) Marshal() ([]byte, error) {
+	output := make([]byte, 0, 18)
+	output = append(output, 0x14)
+	output = appendUint32(output, t.RecordByteCount)
+	output = append(output, t.VersionNumber)
+	output = appendUint16(output, t.EventType)
+	output = appendUint16(output, t.EventModifier)
+	output = appendUint32(output, t.Seconds)
+	output = appendUint32(output, t.NanoSeconds)
+	return output, nil
+}
+
+// marshalString serializes a token consisting of the token ID, the
+// length of the string (including NUL, 2 bytes) and the NUL-terminated
+// string itself (e.g. text, path and zonename tokens).
+func marshalString(tokenID byte, text string) ([]byte, error) {
+	if len(text)+1 > math.MaxUint16 {
+		return nil, errors.New("string too long to fit into token")
+	}
+	output := make([]byte, 0, 1+2+len(text)+1)
+	output = append(output, tokenID)
+	output = appendUint16(output, uint16(len(text)+1))
+	output = append(output, text...)
+	return append(output, 0x00), nil
+}
+
+// Marshal serializes the text token. The length field is computed from
+// the text (including the terminating NUL).
+func (t TextToken) Marshal() ([]byte, error) {
+	return marshalString(0x28, t.Text)
+}
+
+// Marshal serializes the path token. The length field is computed from
+// the path (including the terminating NUL).
+func (t PathToken) Marshal() ([]byte, error) {
+	return marshalString(0x23, t.Path)
+}
+
+// Marshal serializes the zonename token. The length field is computed
+// from the zonename (including the terminating NUL).
+func (t ZonenameToken) Marshal() ([]byte, error) {
+	return marshalString(0x60, t.Zonename)
+}
+
+// Marshal serializes the 32 bit return token (6 bytes).
+func (t ReturnToken32bit) Marshal() ([]byte, error) {
+	output := make([]byte, 0, 6)
+	output = append(output, 0x27, t.ErrorNumber)
+	return appendUint32(output, t.ReturnValue), nil
+}
+
+// Marshal serializes the 64 bit return token (10 bytes).
+func (t ReturnToken64bit) Marshal() ([]byte, error) {
+	output := make([]byte, 0, 10)
+	output = append(output, 0x72, t.ErrorNumber)
+	return appendUint64(output, t.ReturnValue), nil
+}
+
+// Marshal serializes the exit token (9 bytes). The return value is
+// written in two's complement.
+func (t ExitToken) Marshal() ([]byte, error) {
+	output := make([]byte, 0, 9)
+	output = append(output, 0x52)
+	output = appendUint32(output, t.Status)
+	return appendUint32(output, uint32(t.ReturnValue)), nil
+}
+
+// appendIDs appends the given IDs (audit user, effective user, effective
+// group, real user, real group, process and session ID in subject and
+// process tokens) in big endian byte order.
+func appendIDs(output []byte, ids ...uint32) []byte {
+	for _, id := range ids {
+		output = appendUint32(output, id)
+	}
+	return output
+}
+
+// ipv4Address returns the 4 byte form of the given address. An unset
+// address is treated as 0.0.0.0.
+func ipv4Address(address net.IP) ([]byte, error) {
+	if 0 == len(address) {
+		return []byte{0, 0, 0, 0}, nil
+	}
+	ipv4 := address.To4()
+	if ipv4 == nil {
+		return nil, errors.New("terminal machine address is not an IPv4 address")
+	}
+	return ipv4, nil
+}
+
+// expandedAddress returns the given address in the form given by the
+// length field of the token (4 or 16 bytes), so parsed tokens are written
+// as read (e.g. an IPv4-mapped address keeps its 16 bytes). A length of 0
+// selects the 4 byte form for IPv4 addresses and the 16 byte form for
+// IPv6 addresses. An unset address is treated as 0.0.0.0 (or ::).
+func expandedAddress(length uint32, address net.IP) ([]byte, error) {
+	switch length {
+	case 4:
+		return ipv4Address(address)
+	case 16:
+		if 0 == len(address) {
+			return make([]byte, net.IPv6len), nil
+		}
+		if ipv6 := address.To16(); ipv6 != nil {
+			return ipv6, nil
+		}
+		return nil, errors.New("invalid terminal machine address")
+	case 0:
+	default:
+		return nil, fmt.Errorf("invalid terminal address length %d", length)
+	}
+	if ipv4, err := ipv4Address(address); err == nil {
+		return ipv4, nil
+	}
+	if len(address) != net.IPv6len {
+		return nil, errors.New("invalid terminal machine address")
+	}
+	return address, nil
+}
+
+// Marshal serializes the 32 bit subject token (37 bytes).
+func (t SubjectToken32bit) Marshal() ([]byte, error) {
+	address, err := ipv4Address(t.TerminalMachineAddress)
+	if err != nil {
+		return nil, err
+	}
+	output := make([]byte, 0, 37)
+	output = append(output, 0x24)
+	output = appendIDs(output, t.AuditID, t.EffectiveUserID, t.EffectiveGroupID,
+		t.RealUserID, t.RealGroupID, t.ProcessID, t.SessionID)
+	output = appendUint32(output, t.TerminalPortID)
+	return append(output, address...), nil
+}
+
+// Marshal serializes the 64 bit subject token (41 bytes).
+func (t SubjectToken64bit) Marshal() ([]byte, error) {
+	address, err := ipv4Address(t.TerminalMachineAddress)
+	if err != nil {
+		return nil, err
+	}
+	output := make([]byte, 0, 41)
+	output = append(output, 0x75)
+	output = appendIDs(output, t.AuditID, t.EffectiveUserID, t.EffectiveGroupID,
+		t.RealUserID, t.RealGroupID, t.ProcessID, t.SessionID)
+	output = appendUint64(output, t.TerminalPortID)
+	return append(output, address...), nil
+}
+
+// Marshal serializes the 32 bit expanded subject token (41 or 53 bytes).
+// The address is written in the form given by TerminalAddressLength (see
+// expandedAddress).
+func (t ExpandedSubjectToken32bit) Marshal() ([]byte, error) {
+	address, err := expandedAddress(t.TerminalAddressLength, t.TerminalMachineAddress)
+	if err != nil {
+		return nil, err
+	}
+	output := make([]byte, 0, 37+len(address))
+	output = append(output, 0x7a)
+	output = appendIDs(output, t.AuditID, t.EffectiveUserID, t.EffectiveGroupID,
+		t.RealUserID, t.RealGroupID, t.ProcessID, t.SessionID)
+	output = appendUint32(output, t.TerminalPortID)
+	output = appendUint32(output, uint32(len(address)))
+	return append(output, address...), nil
+}
+
+// Marshal serializes the 64 bit expanded subject token (45 or 57 bytes).
+// The address is written in the form given by TerminalAddressLength (see
+// expandedAddress).
+func (t ExpandedSubjectToken64bit) Marshal() ([]byte, error) {
+	address, err := expandedAddress(t.TerminalAddressLength, t.TerminalMachineAddress)
+	if err != nil {
+		return nil, err
+	}
+	output := make([]byte, 0, 41+len(address))
+	output = append(output, 0x7c)
+	output = appendIDs(output, t.AuditID, t.EffectiveUserID, t.EffectiveGroupID,
+		t.RealUserID, t.RealGroupID, t.ProcessID, t.SessionID)
+	output = appendUint64(output, t.TerminalPortID)
+	output = appendUint32(output, uint32(len(address)))
+	return append(output, address...), nil
+}
+
+// Marshal serializes the 32 bit process token (37 bytes).
+func (t ProcessToken32bit) Marshal() ([]byte, error) {
+	address, err := ipv4Address(t.TerminalMachineAddress)
+	if err != nil {
+		return nil, err
+	}
+	output := make([]byte, 0, 37)
+	output = append(output, 0x26)
+	output = appendIDs(output, t.AuditID, t.EffectiveUserID, t.EffectiveGroupID,
+		t.RealUserID, t.RealGroupID, t.ProcessID, t.SessionID)
+	output = appendUint32(output, t.TerminalPortID)
+	return append(output, address...), nil
+}
+
+// Marshal serializes the 64 bit process token (41 bytes).
+func (t ProcessToken64bit) Marshal() ([]byte, error) {
+	address, err := ipv4Address(t.TerminalMachineAddress)
+	if err != nil {
+		return nil, err
+	}
+	output := make([]byte, 0, 41)
+	output = append(output, 0x77)
+	output = appendIDs(output, t.AuditID, t.EffectiveUserID, t.EffectiveGroupID,
+		t.RealUserID, t.RealGroupID, t.ProcessID, t.SessionID)
+	output = appendUint64(output, t.TerminalPortID)
+	return append(output, address...), nil
+}
+
+// Marshal serializes the 32 bit expanded process token (41 or 53 bytes).
+// The address is written in the form given by TerminalAddressLength (see
+// expandedAddress).
+func (t ExpandedProcessToken32bit) Marshal() ([]byte, error) {
+	address, err := expandedAddress(t.TerminalAddressLength, t.TerminalMachineAddress)
+	if err != nil {
+		return nil, err
+	}
+	output := make([]byte, 0, 37+len(address))
+	output = append(output, 0x7b)
+	output = appendIDs(output, t.AuditID, t.EffectiveUserID, t.EffectiveGroupID,
+		t.RealUserID, t.RealGroupID, t.ProcessID, t.SessionID)
+	output = appendUint32(output, t.TerminalPortID)
+	output = appendUint32(output, uint32(len(address)))
+	return append(output, address...), nil
+}
+
+// Marshal serializes the 64 bit expanded process token (45 or 57 bytes).
+// The address is written in the form given by TerminalAddressLength (see
+// expandedAddress).
+func (t ExpandedProcessToken64bit) Marshal() ([]byte, error) {
+	address, err := expandedAddress(t.TerminalAddressLength, t.TerminalMachineAddress)
+	if err != nil {
+		return nil, err
+	}
+	output := make([]byte, 0, 41+len(address))
+	output = append(output, 0x7d)
+	output = appendIDs(output, t.AuditID, t.EffectiveUserID, t.EffectiveGroupID,
+		t.RealUserID, t.RealGroupID, t.ProcessID, t.SessionID)
+	output = appendUint64(output, t.TerminalPortID)
+	output = appendUint32(output, uint32(len(address)))
+	return append(output, address...), nil
+}
+
+// Marshal serializes the trailer token (7 bytes). The trailer magic is
+// always written as 0xb105.
+func (t TrailerToken) Marshal() ([]byte, error) {
+	output := make([]byte, 0, 7)
+	output = append(output, 0x13)
+	output = appendUint16(output, 0xb105)
+	return appendUint32(output, t.RecordByteCount), nil
+}
+
+// Marshal returns (a copy of) the bytes of the raw token.
+func (t RawToken) Marshal() ([]byte, error) {
+	return append([]byte(nil), t.Raw...), nil
+}
+
+// BuildRecord serializes a complete record consisting of the given
+// header token, the body tokens and a trailer token. The record byte
+// counts of header and trailer are computed from the serialized tokens
+// (similar to au_close(3) of libbsm), so the record byte count of the
+// given header is ignored.
+func BuildRecord(header Token, body ...Token) ([]byte, error) {
+	switch header.(type) {
+	case HeaderToken32bit, HeaderToken64bit, ExpandedHeaderToken32bit, ExpandedHeaderToken64bit:
+	default:
+		return nil, errors.New("record has to start with a header token")
+	}
+	output := []byte{}
+	for _, token := range append([]Token{header}, body...) {
+		marshaler, ok := token.(Marshaler)
+		if !ok {
+			return nil, fmt.Errorf("token 0x%x can't be serialized", token.Type())
+		}
+		data, err := marshaler.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, data...)
+	}
+
+	// record byte count includes the trailer
+	length := len(output) + 7
+	if uint64(length) > math.MaxUint32 {
+		return nil, errors.New("record too long")
+	}
+	trailer, _ := TrailerToken{RecordByteCount: uint32(length)}.Marshal()
+	output = append(output, trailer...)
+
+	// patch record byte count of header (right after token ID)
+	count := appendUint32(nil, uint32(length))
+	copy(output[1:5], count)
+	return output, nil
+}
+
+// WriteBsmRecord serializes the given record (header, tokens and trailer)
+// and writes it to the given output. Nothing is written if the record byte
+// counts of header and trailer don't match the length of the serialized
+// record.
+func WriteBsmRecord(w io.Writer, rec BsmRecord) error {
+	_, err := rec.WriteTo(w)
+	return err
+}
+
+// WriteTo serializes the record (see WriteBsmRecord) and writes it to the
+// given output (io.WriterTo interface). It returns the number of bytes
+// written, which is the record byte count for complete writes.
+func (r BsmRecord) WriteTo(w io.Writer) (int64, error) {
+	output, err := marshalRecord(r)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(output)
+	if err != nil {
+		return int64(n), err
+	}
+	if n != len(output) {
+		return int64(n), io.ErrShortWrite
+	}
+	return int64(n), nil
+}
+
+// marshalRecord serializes the given record. The record byte counts of
+// header and trailer have to match the length of the serialized record.
+func marshalRecord(rec BsmRecord) ([]byte, error) {
+	var headerCount uint32
+	switch header := rec.Header.(type) {
+	case HeaderToken32bit:
+		headerCount = header.RecordByteCount
+	case HeaderToken64bit:
+		headerCount = header.RecordByteCount
+	case ExpandedHeaderToken32bit:
+		headerCount = header.RecordByteCount
+	case ExpandedHeaderToken64bit:
+		headerCount = header.RecordByteCount
+	default:
+		return nil, errors.New("record has to start with a header token")
+	}
+
+	output := []byte{}
+	for _, token := range append(append([]Token{rec.Header}, rec.Tokens...), rec.Trailer) {
+		marshaler, ok := token.(Marshaler)
+		if !ok {
+			return nil, fmt.Errorf("token 0x%x can't be serialized", token.Type())
+		}
+		data, err := marshaler.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, data...)
+	}
+	if uint64(headerCount) != uint64(len(output)) || headerCount != rec.Trailer.RecordByteCount {
+		return nil, fmt.Errorf("record length mismatch: header=%d trailer=%d serialized=%d",
+			headerCount, rec.Trailer.RecordByteCount, len(output))
+	}
+	return output, nil
+}
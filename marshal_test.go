@@ -0,0 +1,434 @@
+// test serialization of BSM tokens
+package bsm
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+func TestHeaderToken32bit_Marshal(t *testing.T) {
+	token := HeaderToken32bit{
+		TokenID:         0x14,
+		RecordByteCount: 56,
+		VersionNumber:   11,
+		EventType:       45000,
+		EventModifier:   0,
+		Seconds:         1520091878,
+		NanoSeconds:     769,
+	}
+	data, err := token.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{
+		0x14,                   // token ID
+		0x00, 0x00, 0x00, 0x38, // 56 bytes in record
+		0x0b,       // version number
+		0xaf, 0xc8, // event type
+		0x00, 0x00, // event modifier
+		0x5a, 0x9a, 0xc2, 0xe6, // seconds
+		0x00, 0x00, 0x03, 0x01, // nanoseconds
+	}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("unexpected serialization: % x", data)
+	}
+
+	parsed, err := ParseHeaderToken32bit(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != token {
+		t.Errorf("round trip failed: %v", parsed)
+	}
+}
+
+func TestTextToken_Marshal(t *testing.T) {
+	data, err := TextToken{TokenID: 0x28, Text: "auditd::Audit startup"}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1+2+22 || data[1] != 0x00 || data[2] != 0x16 || data[len(data)-1] != 0x00 {
+		t.Errorf("unexpected serialization: % x", data)
+	}
+	parsed, err := ParseTextToken(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Text != "auditd::Audit startup" || parsed.TextLength != 22 {
+		t.Errorf("round trip failed: %v", parsed)
+	}
+
+	if _, err := (TextToken{Text: string(make([]byte, 70000))}).Marshal(); err == nil {
+		t.Error("expected an error on overly long text")
+	}
+}
+
+func TestPathToken_Marshal(t *testing.T) {
+	data, err := PathToken{TokenID: 0x23, Path: "/etc/master.passwd"}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := TokenFromByteInput(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, ok := token.(PathToken)
+	if !ok {
+		t.Fatal("asserting PathToken type failed")
+	}
+	if parsed.Path != "/etc/master.passwd" {
+		t.Errorf("round trip failed: %q", parsed.Path)
+	}
+}
+
+func TestZonenameToken_Marshal(t *testing.T) {
+	data, err := ZonenameToken{TokenID: 0x60, Zonename: "www01"}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseZonenameToken(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Zonename != "www01" || parsed.ZonenameLength != 6 {
+		t.Errorf("round trip failed: %v", parsed)
+	}
+}
+
+func TestReturnToken32bit_Marshal(t *testing.T) {
+	token := ReturnToken32bit{TokenID: 0x27, ErrorNumber: 13, ReturnValue: 0xffffffff}
+	data, err := token.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0x27, 0x0d, 0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("unexpected serialization: % x", data)
+	}
+	parsed, err := TokenFromByteInput(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != token {
+		t.Errorf("round trip failed: %v", parsed)
+	}
+}
+
+func TestReturnToken64bit_Marshal(t *testing.T) {
+	token := ReturnToken64bit{TokenID: 0x72, ErrorNumber: 0, ReturnValue: 0x123456789abcdef0}
+	data, err := token.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 10 || data[2] != 0x12 || data[9] != 0xf0 {
+		t.Errorf("unexpected serialization: % x", data)
+	}
+	parsed, err := ParseReturnToken64bit(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != token {
+		t.Errorf("round trip failed: %v", parsed)
+	}
+}
+
+func TestExitToken_Marshal(t *testing.T) {
+	token := ExitToken{TokenID: 0x52, Status: 1, ReturnValue: -2}
+	data, err := token.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0x52, 0x00, 0x00, 0x00, 0x01, 0xff, 0xff, 0xff, 0xfe}) {
+		t.Errorf("unexpected serialization: % x", data)
+	}
+	parsed, err := ParseExitToken(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != token {
+		t.Errorf("round trip failed: %v", parsed)
+	}
+}
+
+func TestSubjectToken32bit_Marshal(t *testing.T) {
+	token := SubjectToken32bit{
+		TokenID:                0x24,
+		AuditID:                0xffffffff,
+		EffectiveUserID:        1001,
+		EffectiveGroupID:       1001,
+		RealUserID:             1001,
+		RealGroupID:            1001,
+		ProcessID:              754,
+		SessionID:              754,
+		TerminalPortID:         7269,
+		TerminalMachineAddress: net.ParseIP("93.184.216.38"), // 16 byte form
+	}
+	data, err := token.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 37 {
+		t.Fatal("unexpected length of serialization:", len(data))
+	}
+	token2, err := TokenFromByteInput(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := token2.(SubjectToken32bit)
+	if !parsed.TerminalMachineAddress.Equal(token.TerminalMachineAddress) {
+		t.Error("round trip failed for address: " + parsed.TerminalMachineAddress.String())
+	}
+	parsed.TerminalMachineAddress = token.TerminalMachineAddress
+	if parsed.String() != token.String() {
+		t.Error("round trip failed: " + parsed.String())
+	}
+
+	token.TerminalMachineAddress = net.ParseIP("2001:db8::1")
+	if _, err := token.Marshal(); err == nil {
+		t.Error("expected an error on IPv6 address in 32 bit subject token")
+	}
+}
+
+func TestExpandedSubjectToken32bit_Marshal(t *testing.T) {
+	token := ExpandedSubjectToken32bit{
+		TokenID:                0x7a,
+		AuditID:                1001,
+		EffectiveUserID:        0,
+		ProcessID:              821,
+		SessionID:              821,
+		TerminalPortID:         7269,
+		TerminalAddressLength:  16,
+		TerminalMachineAddress: net.ParseIP("2001:db8::1"),
+	}
+	data, err := token.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 53 {
+		t.Fatal("unexpected length of serialization:", len(data))
+	}
+	token2, err := TokenFromByteInput(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := token2.(ExpandedSubjectToken32bit)
+	if parsed.TerminalAddressLength != 16 || !parsed.TerminalMachineAddress.Equal(token.TerminalMachineAddress) {
+		t.Error("round trip failed: " + parsed.String())
+	}
+	if parsed.String() != token.String() {
+		t.Error("round trip failed: " + parsed.String())
+	}
+
+	// IPv4 addresses are written in their 4 byte form if no length is set
+	token.TerminalAddressLength = 0
+	token.TerminalMachineAddress = net.ParseIP("93.184.216.38")
+	data, err = token.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 41 || data[36] != 4 {
+		t.Errorf("unexpected serialization: % x", data)
+	}
+
+	// parsed IPv4-mapped addresses keep their 16 bytes
+	data[36] = 16
+	data = append(data[:37], append(net.ParseIP("93.184.216.38").To16(), data[41:]...)...)
+	token2, err = TokenFromByteInput(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token2.(Sizer).Size() != len(data) {
+		t.Error("unexpected size of parsed token:", token2.(Sizer).Size())
+	}
+	again, err := token2.(ExpandedSubjectToken32bit).Marshal()
+	if err != nil || !bytes.Equal(again, data) {
+		t.Errorf("round trip failed: % x (%v)", again, err)
+	}
+
+	// the address has to fit the length
+	token.TerminalAddressLength = 4
+	token.TerminalMachineAddress = net.ParseIP("2001:db8::1")
+	if _, err = token.Marshal(); err == nil {
+		t.Error("expected an error on an IPv6 address of length 4")
+	}
+}
+
+func TestExpandedSubjectToken64bit_Marshal(t *testing.T) {
+	token := ExpandedSubjectToken64bit{
+		TokenID:                0x7c,
+		AuditID:                1001,
+		ProcessID:              821,
+		SessionID:              821,
+		TerminalPortID:         1 << 40,
+		TerminalAddressLength:  4,
+		TerminalMachineAddress: net.ParseIP("93.184.216.38"),
+	}
+	data, err := token.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// libbsm (au_to_subject64_ex) writes a 4 byte address type
+	if len(data) != 45 || data[40] != 4 {
+		t.Fatalf("unexpected serialization: % x", data)
+	}
+	token2, err := TokenFromByteInput(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := token2.(ExpandedSubjectToken64bit)
+	if parsed.String() != token.String() || parsed.Size() != len(data) {
+		t.Error("round trip failed: " + parsed.String())
+	}
+}
+
+func TestProcessToken_Marshal(t *testing.T) {
+	tokens := map[int]interface {
+		Marshal() ([]byte, error)
+	}{
+		37: ProcessToken32bit{TerminalMachineAddress: net.IPv4(10, 0, 0, 1)},
+		41: ProcessToken64bit{},
+		53: ExpandedProcessToken32bit{TerminalMachineAddress: net.ParseIP("2001:db8::1")},
+		45: ExpandedProcessToken64bit{TerminalMachineAddress: net.IPv4(10, 0, 0, 1)},
+		57: ExpandedSubjectToken64bit{TerminalMachineAddress: net.ParseIP("2001:db8::1")},
+	}
+	for length, token := range tokens {
+		data, err := token.Marshal()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if len(data) != length {
+			t.Errorf("expected %d bytes, got % x", length, data)
+		}
+	}
+}
+
+func TestBuildRecord(t *testing.T) {
+	header := HeaderToken32bit{
+		TokenID:       0x14,
+		VersionNumber: 11,
+		EventType:     45000,
+		Seconds:       1520091878,
+		NanoSeconds:   769,
+	}
+	data, err := BuildRecord(header,
+		TextToken{TokenID: 0x28, Text: "auditd::Audit startup"},
+		ReturnToken32bit{TokenID: 0x27})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 56 {
+		t.Fatal("unexpected record length:", len(data))
+	}
+
+	rec, err := ReadBsmRecord(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedHeader := rec.Header.(HeaderToken32bit)
+	if parsedHeader.RecordByteCount != 56 || rec.Trailer.RecordByteCount != 56 {
+		t.Error("unexpected record byte count", parsedHeader.RecordByteCount, rec.Trailer.RecordByteCount)
+	}
+	parsedHeader.RecordByteCount = 0
+	if parsedHeader != header {
+		t.Error("unexpected header: " + parsedHeader.String())
+	}
+	if len(rec.Tokens) != 2 || rec.Tokens[0].(TextToken).Text != "auditd::Audit startup" {
+		t.Error("unexpected tokens in record:", rec.Tokens)
+	}
+
+	if _, err := BuildRecord(TextToken{TokenID: 0x28}); err == nil {
+		t.Error("expected an error on missing header")
+	}
+	if _, err := BuildRecord(header, SeqToken{TokenID: 0x2f}); err == nil {
+		t.Error("expected an error on token which can't be serialized")
+	}
+}
+
+func TestWriteBsmRecord(t *testing.T) {
+	original, err := ioutil.ReadFile("start_stop.bsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := bytes.NewBuffer(original)
+	output := &bytes.Buffer{}
+	records := []BsmRecord{}
+	for {
+		rec, err := ReadBsmRecord(input)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+		if err := WriteBsmRecord(output, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(output.Bytes(), original) {
+		t.Error("written records differ from original file")
+	}
+
+	for _, rec := range records {
+		reread, err := ReadBsmRecord(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reread.Header != rec.Header || reread.Trailer != rec.Trailer || len(reread.Tokens) != len(rec.Tokens) {
+			t.Error("re-read record differs from original")
+		}
+	}
+
+	// inconsistent record byte count
+	broken := records[0]
+	broken.Trailer.RecordByteCount += 1
+	if err := WriteBsmRecord(ioutil.Discard, broken); err == nil {
+		t.Error("expected an error on record length mismatch")
+	}
+
+	// short write
+	if err := WriteBsmRecord(&limitedWriter{limit: 10}, records[0]); err != io.ErrShortWrite {
+		t.Error("expected a short write error, got", err)
+	}
+}
+
+// limitedWriter accepts only a limited number of bytes (without error).
+type limitedWriter struct {
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		return w.limit, nil
+	}
+	return len(p), nil
+}
+
+func TestBsmRecord_WriteTo(t *testing.T) {
+	records, err := ParseAll(bytes.NewBuffer(rootLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := &bytes.Buffer{}
+	var _ io.WriterTo = records[0] // BsmRecord implements io.WriterTo
+	for _, rec := range records {
+		n, err := rec.WriteTo(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(rec.Trailer.RecordByteCount) {
+			t.Errorf("wrote %d bytes, record byte count is %d", n, rec.Trailer.RecordByteCount)
+		}
+	}
+	if !bytes.Equal(output.Bytes(), rootLogin) {
+		t.Error("written records differ from original")
+	}
+
+	// short write
+	if n, err := records[0].WriteTo(&limitedWriter{limit: 10}); err != io.ErrShortWrite || n != 10 {
+		t.Error("expected a short write of 10 bytes, got", n, err)
+	}
+}
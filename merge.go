@@ -0,0 +1,56 @@
+// Merging of streams of BSM records
+package bsm
+
+import (
+	"time"
+)
+
+// recordTimestamp returns the time stamp of the header token of the
+// given record (the zero time for records without header token).
+func recordTimestamp(rec BsmRecord) time.Time {
+	header, ok := rec.Header.(interface {
+		Timestamp() time.Time
+	})
+	if !ok {
+		return time.Time{}
+	}
+	return header.Timestamp()
+}
+
+// MergeByTime merges the given streams into one stream ordered by the
+// header time stamps of the records, e.g. to combine the audit trails
+// of several hosts. Each stream has to be ordered by time already (as
+// audit trails are), the result is not ordered otherwise. Records with
+// equal time stamps are passed in the order of the streams. The returned
+// channel is closed once all input channels are closed.
+func MergeByTime(streams ...<-chan BsmRecord) <-chan BsmRecord {
+	output := make(chan BsmRecord)
+	go func() {
+		defer close(output)
+		// next record of each stream (nil once it is exhausted)
+		heads := make([]*BsmRecord, len(streams))
+		next := func(i int) {
+			heads[i] = nil
+			if rec, ok := <-streams[i]; ok {
+				heads[i] = &rec
+			}
+		}
+		for i := range streams {
+			next(i)
+		}
+		for {
+			earliest := -1
+			for i, head := range heads {
+				if head != nil && (earliest == -1 || recordTimestamp(*head).Before(recordTimestamp(*heads[earliest]))) {
+					earliest = i
+				}
+			}
+			if earliest == -1 {
+				return
+			}
+			output <- *heads[earliest]
+			next(earliest)
+		}
+	}()
+	return output
+}
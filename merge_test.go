@@ -0,0 +1,38 @@
+// test merging of streams of BSM records
+package bsm
+
+import (
+	"testing"
+)
+
+func TestMergeByTime(t *testing.T) {
+	record := func(seconds uint32, event uint16) BsmRecord {
+		return BsmRecord{Header: HeaderToken32bit{TokenID: 0x14, EventType: event, Seconds: seconds}}
+	}
+	hostA := recordStream(record(10, 1), record(20, 2), record(40, 4))
+	hostB := recordStream(record(15, 11), record(20, 12), record(50, 15))
+	empty := recordStream()
+
+	events := []uint16{}
+	for rec := range MergeByTime(hostA, empty, hostB) {
+		event, _ := rec.EventType()
+		events = append(events, event)
+	}
+	expected := []uint16{1, 11, 2, 12, 4, 15} // equal time stamps in stream order
+	if len(events) != len(expected) {
+		t.Fatal("unexpected events after merging:", events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatal("unexpected events after merging:", events)
+		}
+	}
+
+	count := 0
+	for range MergeByTime() {
+		count += 1
+	}
+	if count != 0 {
+		t.Error("expected no records without streams, got", count)
+	}
+}
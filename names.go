@@ -0,0 +1,365 @@
+// Symbolic names for numeric values found in BSM tokens
+package bsm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// EventNames maps event types (as found in header tokens)
+// to their names (e.g. AUE_login).
+type EventNames map[uint16]string
+
+// LoadEventNames reads an event table in the audit_event(5) format
+// (e.g. /etc/security/audit_event). Each line has the form
+// evnum:evname:evdesc:class, comments start with '#'.
+func LoadEventNames(input io.Reader) (EventNames, error) {
+	names := EventNames{}
+	scanner := bufio.NewScanner(input)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber += 1
+		line := strings.TrimSpace(scanner.Text())
+		if 0 == len(line) || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid event definition in line %d", lineNumber)
+		}
+		number, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event number in line %d: %s", lineNumber, err.Error())
+		}
+		names[uint16(number)] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// EventName returns the name of the given event type. The numeric
+// form is returned for unknown event types.
+func (e EventNames) EventName(id uint16) string {
+	if name, ok := e[id]; ok {
+		return name
+	}
+	return strconv.Itoa(int(id))
+}
+
+// EventNumber returns the event type with the given name (e.g.
+// AUE_login). False is returned for unknown names.
+func (e EventNames) EventNumber(name string) (uint16, bool) {
+	for id, n := range e {
+		if n == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// bsmErrnoNames maps the portable BSM error numbers to their names. BSM
+// uses its own (Solaris derived) error numbers instead of the host ones,
+// see audit_errno.h in OpenBSM.
+var bsmErrnoNames = map[uint8]string{
+	0:   "ESUCCESS",
+	1:   "EPERM",
+	2:   "ENOENT",
+	3:   "ESRCH",
+	4:   "EINTR",
+	5:   "EIO",
+	6:   "ENXIO",
+	7:   "E2BIG",
+	8:   "ENOEXEC",
+	9:   "EBADF",
+	10:  "ECHILD",
+	11:  "EAGAIN",
+	12:  "ENOMEM",
+	13:  "EACCES",
+	14:  "EFAULT",
+	15:  "ENOTBLK",
+	16:  "EBUSY",
+	17:  "EEXIST",
+	18:  "EXDEV",
+	19:  "ENODEV",
+	20:  "ENOTDIR",
+	21:  "EISDIR",
+	22:  "EINVAL",
+	23:  "ENFILE",
+	24:  "EMFILE",
+	25:  "ENOTTY",
+	26:  "ETXTBSY",
+	27:  "EFBIG",
+	28:  "ENOSPC",
+	29:  "ESPIPE",
+	30:  "EROFS",
+	31:  "EMLINK",
+	32:  "EPIPE",
+	33:  "EDOM",
+	34:  "ERANGE",
+	35:  "ENOMSG",
+	36:  "EIDRM",
+	37:  "ECHRNG",
+	38:  "EL2NSYNC",
+	39:  "EL3HLT",
+	40:  "EL3RST",
+	41:  "ELNRNG",
+	42:  "EUNATCH",
+	43:  "ENOCSI",
+	44:  "EL2HLT",
+	45:  "EDEADLK",
+	46:  "ENOLCK",
+	47:  "ECANCELED",
+	48:  "ENOTSUP",
+	49:  "EDQUOT",
+	50:  "EBADE",
+	51:  "EBADR",
+	52:  "EXFULL",
+	53:  "ENOANO",
+	54:  "EBADRQC",
+	55:  "EBADSLT",
+	56:  "EDEADLOCK",
+	57:  "EBFONT",
+	58:  "EOWNERDEAD",
+	59:  "ENOTRECOVERABLE",
+	60:  "ENOSTR",
+	61:  "ENODATA",
+	62:  "ETIME",
+	63:  "ENOSR",
+	64:  "ENONET",
+	65:  "ENOPKG",
+	66:  "EREMOTE",
+	67:  "ENOLINK",
+	68:  "EADV",
+	69:  "ESRMNT",
+	70:  "ECOMM",
+	71:  "EPROTO",
+	72:  "ELOCKUNMAPPED",
+	73:  "ENOTACTIVE",
+	74:  "EMULTIHOP",
+	77:  "EBADMSG",
+	78:  "ENAMETOOLONG",
+	79:  "EOVERFLOW",
+	80:  "ENOTUNIQ",
+	81:  "EBADFD",
+	82:  "EREMCHG",
+	83:  "ELIBACC",
+	84:  "ELIBBAD",
+	85:  "ELIBSCN",
+	86:  "ELIBMAX",
+	87:  "ELIBEXEC",
+	88:  "EILSEQ",
+	89:  "ENOSYS",
+	90:  "ELOOP",
+	91:  "ERESTART",
+	92:  "ESTRPIPE",
+	93:  "ENOTEMPTY",
+	94:  "EUSERS",
+	95:  "ENOTSOCK",
+	96:  "EDESTADDRREQ",
+	97:  "EMSGSIZE",
+	98:  "EPROTOTYPE",
+	99:  "ENOPROTOOPT",
+	120: "EPROTONOSUPPORT",
+	121: "ESOCKTNOSUPPORT",
+	122: "EOPNOTSUPP",
+	123: "EPFNOSUPPORT",
+	124: "EAFNOSUPPORT",
+	125: "EADDRINUSE",
+	126: "EADDRNOTAVAIL",
+	127: "ENETDOWN",
+	128: "ENETUNREACH",
+	129: "ENETRESET",
+	130: "ECONNABORTED",
+	131: "ECONNRESET",
+	132: "ENOBUFS",
+	133: "EISCONN",
+	134: "ENOTCONN",
+	143: "ESHUTDOWN",
+	144: "ETOOMANYREFS",
+	145: "ETIMEDOUT",
+	146: "ECONNREFUSED",
+	147: "EHOSTDOWN",
+	148: "EHOSTUNREACH",
+	149: "EALREADY",
+	150: "EINPROGRESS",
+	151: "ESTALE",
+	190: "EPROCLIM",
+	191: "EBADRPC",
+	192: "ERPCMISMATCH",
+	193: "EPROGUNAVAIL",
+	194: "EPROGMISMATCH",
+	195: "EPROCUNAVAIL",
+	196: "EFTYPE",
+	197: "EAUTH",
+	198: "ENEEDAUTH",
+	199: "ENOATTR",
+	200: "EDOOFUS",
+	201: "EJUSTRETURN",
+	202: "ENOIOCTL",
+	203: "EDIRIOCTL",
+	204: "EPWROFF",
+	205: "EDEVERR",
+	206: "EBADEXEC",
+	207: "EBADARCH",
+	208: "ESHLIBVERS",
+	209: "EBADMACHO",
+	210: "EPOLICY",
+	211: "EDOTDOT",
+	212: "EUCLEAN",
+	213: "ENOTNAM",
+	214: "ENAVAIL",
+	215: "EISNAM",
+	216: "EREMOTEIO",
+	217: "ENOMEDIUM",
+	218: "EMEDIUMTYPE",
+	219: "ENOKEY",
+	220: "EKEYEXPIRED",
+	221: "EKEYREVOKED",
+	222: "EKEYREJECTED",
+	223: "ENOTCAPABLE",
+	224: "ECAPMODE",
+}
+
+// ErrnoName returns the name of the given BSM error number (as found
+// in return tokens). Unknown numbers are returned as errno(N).
+func ErrnoName(n uint8) string {
+	if name, ok := bsmErrnoNames[n]; ok {
+		return name
+	}
+	return "errno(" + strconv.Itoa(int(n)) + ")"
+}
+
+// eventModifierFlags holds the flags of the event modifier of header
+// tokens as defined by FreeBSD (see audit_record.h in OpenBSM). All other
+// bits are reserved.
+var eventModifierFlags = []struct {
+	bit  uint16
+	name string
+}{
+	{0x4000, "PAD_NOTATTR"}, // nonattributable event
+	{0x8000, "PAD_FAILURE"}, // failed event
+}
+
+// EventModifierFlags returns the names of the flags set in the given
+// event modifier (as found in header tokens). Unknown bits are returned
+// in hexadecimal form (e.g. 0x0001).
+func EventModifierFlags(m uint16) []string {
+	flags := []string{}
+	for _, flag := range eventModifierFlags {
+		if m&flag.bit != 0 {
+			flags = append(flags, flag.name)
+			m &^= flag.bit
+		}
+	}
+	for bit := uint16(1); m != 0; bit <<= 1 {
+		if m&bit != 0 {
+			flags = append(flags, fmt.Sprintf("0x%04x", bit))
+			m &^= bit
+		}
+	}
+	return flags
+}
+
+// Platform denotes the operating system which wrote an audit trail.
+// Some numeric values (e.g. socket families) differ between platforms.
+type Platform int
+
+// supported platforms
+const (
+	FreeBSD Platform = iota
+	Darwin
+	Solaris
+)
+
+// SocketFamilyPlatform selects the socket family numbering used by
+// SocketFamilyName. It defaults to FreeBSD.
+var SocketFamilyPlatform = FreeBSD
+
+// socketFamilyNames holds the (common) socket families per platform.
+var socketFamilyNames = map[Platform]map[uint16]string{
+	FreeBSD: {
+		0:  "AF_UNSPEC",
+		1:  "AF_UNIX",
+		2:  "AF_INET",
+		17: "AF_ROUTE",
+		18: "AF_LINK",
+		28: "AF_INET6",
+		36: "AF_BLUETOOTH",
+	},
+	Darwin: {
+		0:  "AF_UNSPEC",
+		1:  "AF_UNIX",
+		2:  "AF_INET",
+		17: "AF_ROUTE",
+		18: "AF_LINK",
+		30: "AF_INET6",
+		32: "AF_SYSTEM",
+	},
+	Solaris: {
+		0:  "AF_UNSPEC",
+		1:  "AF_UNIX",
+		2:  "AF_INET",
+		24: "AF_ROUTE",
+		25: "AF_LINK",
+		26: "AF_INET6",
+	},
+}
+
+// SocketFamilyName returns the name of the given socket family (e.g.
+// SocketFamily of the socket tokens) for the platform selected by
+// SocketFamilyPlatform. Unknown families are returned in numeric form.
+func SocketFamilyName(f uint16) string {
+	if name, ok := socketFamilyNames[SocketFamilyPlatform][f]; ok {
+		return name
+	}
+	return strconv.Itoa(int(f))
+}
+
+// protocolNames maps common IANA protocol numbers to their names.
+var protocolNames = map[uint8]string{
+	1:   "ICMP",
+	2:   "IGMP",
+	4:   "IPIP",
+	6:   "TCP",
+	17:  "UDP",
+	41:  "IPV6",
+	47:  "GRE",
+	50:  "ESP",
+	51:  "AH",
+	58:  "ICMPV6",
+	103: "PIM",
+	112: "VRRP",
+	132: "SCTP",
+}
+
+// ProtocolName returns the name of the given IP protocol number (e.g.
+// Protocol of the ip token). Unknown protocols are returned in numeric
+// form.
+func ProtocolName(p uint8) string {
+	if name, ok := protocolNames[p]; ok {
+		return name
+	}
+	return strconv.Itoa(int(p))
+}
+
+// ipcObjectTypeNames maps the System V IPC object types to their names,
+// see AT_IPC_MSG, AT_IPC_SEM and AT_IPC_SHM in audit_record.h of OpenBSM.
+var ipcObjectTypeNames = map[uint8]string{
+	1: "msg", // message queue
+	2: "sem", // semaphore
+	3: "shm", // shared memory
+}
+
+// ObjectTypeName returns the name of the IPC object type (msg, sem or
+// shm). Unknown types are returned in numeric form.
+func (t SystemVIpcToken) ObjectTypeName() string {
+	if name, ok := ipcObjectTypeNames[t.ObjectIdType]; ok {
+		return name
+	}
+	return strconv.Itoa(int(t.ObjectIdType))
+}
@@ -0,0 +1,134 @@
+// test symbolic names for numeric values found in BSM tokens
+package bsm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadEventNames(t *testing.T) {
+	table := `#
+# audit_event - definitions of audit events
+#
+0:AUE_NULL:indir system call:no
+45000:AUE_audit_startup:audit startup:ad
+45001:AUE_audit_shutdown:audit shutdown:ad
+
+6152:AUE_login:login - local:lo
+`
+	names, err := LoadEventNames(strings.NewReader(table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 4 {
+		t.Error("unexpected number of events loaded")
+	}
+	if names.EventName(6152) != "AUE_login" {
+		t.Error("unexpected event name: " + names.EventName(6152))
+	}
+	if names.EventName(45000) != "AUE_audit_startup" {
+		t.Error("unexpected event name: " + names.EventName(45000))
+	}
+	if names.EventName(1234) != "1234" {
+		t.Error("expected numeric fallback, got " + names.EventName(1234))
+	}
+	if number, ok := names.EventNumber("AUE_login"); !ok || number != 6152 {
+		t.Error("unexpected event number:", number)
+	}
+	if _, ok := names.EventNumber("AUE_unknown"); ok {
+		t.Error("expected unknown event name")
+	}
+
+	_, err = LoadEventNames(strings.NewReader("AUE_login:6152:login - local:lo\n"))
+	if err == nil {
+		t.Error("expected an error on invalid event number")
+	}
+}
+
+func TestErrnoName(t *testing.T) {
+	testData := map[uint8]string{
+		0:   "ESUCCESS",
+		1:   "EPERM",
+		2:   "ENOENT",
+		13:  "EACCES",
+		22:  "EINVAL",
+		78:  "ENAMETOOLONG", // differs from host numbering
+		146: "ECONNREFUSED",
+		217: "ENOMEDIUM",
+		223: "ENOTCAPABLE", // Capsicum (FreeBSD)
+		224: "ECAPMODE",
+		225: "errno(225)",
+		250: "errno(250)",
+	}
+	for number, name := range testData {
+		if ErrnoName(number) != name {
+			t.Error("expected " + name + ", got " + ErrnoName(number))
+		}
+	}
+}
+
+func TestEventModifierFlags(t *testing.T) {
+	testData := map[uint16][]string{
+		0x0000: {},
+		0x8000: {"PAD_FAILURE"},
+		0xc000: {"PAD_NOTATTR", "PAD_FAILURE"},
+		0x4005: {"PAD_NOTATTR", "0x0001", "0x0004"},
+	}
+	for modifier, expected := range testData {
+		if flags := EventModifierFlags(modifier); !reflect.DeepEqual(flags, expected) {
+			t.Errorf("modifier 0x%04x: expected %v, got %v", modifier, expected, flags)
+		}
+	}
+}
+
+func TestSocketFamilyName(t *testing.T) {
+	if SocketFamilyName(2) != "AF_INET" {
+		t.Error("expected AF_INET, got " + SocketFamilyName(2))
+	}
+	if SocketFamilyName(28) != "AF_INET6" {
+		t.Error("expected AF_INET6, got " + SocketFamilyName(28))
+	}
+	if SocketFamilyName(1) != "AF_UNIX" {
+		t.Error("expected AF_UNIX, got " + SocketFamilyName(1))
+	}
+	if SocketFamilyName(4242) != "4242" {
+		t.Error("expected numeric fallback, got " + SocketFamilyName(4242))
+	}
+
+	// AF_INET6 differs between platforms
+	SocketFamilyPlatform = Darwin
+	defer func() { SocketFamilyPlatform = FreeBSD }()
+	if SocketFamilyName(30) != "AF_INET6" {
+		t.Error("expected AF_INET6 on Darwin, got " + SocketFamilyName(30))
+	}
+}
+
+func TestProtocolName(t *testing.T) {
+	testData := map[uint8]string{
+		1:   "ICMP",
+		6:   "TCP",
+		17:  "UDP",
+		253: "253", // reserved for experimentation
+	}
+	for number, name := range testData {
+		if ProtocolName(number) != name {
+			t.Error("expected " + name + ", got " + ProtocolName(number))
+		}
+	}
+}
+
+func TestSystemVIpcToken_ObjectTypeName(t *testing.T) {
+	testData := map[uint8]string{
+		1: "msg",
+		2: "sem",
+		3: "shm",
+		9: "9",
+	}
+	for objectType, name := range testData {
+		token := SystemVIpcToken{TokenID: 0x22, ObjectIdType: objectType}
+		if token.ObjectTypeName() != name {
+			t.Error("expected " + name + ", got " + token.ObjectTypeName())
+		}
+	}
+}
@@ -0,0 +1,18 @@
+// Outcomes of BSM records
+package bsm
+
+// Outcome returns the result of the first return token of the record
+// (regardless of its width). Success means an error number of 0. The
+// return value is interpreted as signed (e.g. -1 on failed system
+// calls). False is returned (as ok) if the record has no return token.
+func (r BsmRecord) Outcome() (success bool, errno uint8, value int64, ok bool) {
+	for _, token := range r.Tokens {
+		switch t := token.(type) {
+		case ReturnToken32bit:
+			return t.ErrorNumber == 0, t.ErrorNumber, int64(int32(t.ReturnValue)), true
+		case ReturnToken64bit:
+			return t.ErrorNumber == 0, t.ErrorNumber, int64(t.ReturnValue), true
+		}
+	}
+	return false, 0, 0, false
+}
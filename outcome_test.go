@@ -0,0 +1,39 @@
+// test outcomes of BSM records
+package bsm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBsmRecord_Outcome(t *testing.T) {
+	records, err := ParseAll(bytes.NewBuffer(rootLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	success, errno, value, ok := records[1].Outcome()
+	if !ok || !success || errno != 0 || value != 0 {
+		t.Error("unexpected outcome of root login:", success, errno, value, ok)
+	}
+
+	// failed system call (EACCES)
+	failed := BsmRecord{Tokens: []Token{
+		TextToken{TokenID: 0x28, Text: "open"},
+		ReturnToken32bit{TokenID: 0x27, ErrorNumber: 13, ReturnValue: 0xffffffff},
+	}}
+	success, errno, value, ok = failed.Outcome()
+	if !ok || success || errno != 13 || value != -1 {
+		t.Error("unexpected outcome of failed record:", success, errno, value, ok)
+	}
+
+	// 64 bit return value
+	failed.Tokens[1] = ReturnToken64bit{TokenID: 0x72, ErrorNumber: 2, ReturnValue: 0xffffffffffffffff}
+	success, errno, value, ok = failed.Outcome()
+	if !ok || success || errno != 2 || value != -1 {
+		t.Error("unexpected outcome of failed 64 bit record:", success, errno, value, ok)
+	}
+
+	if _, _, _, ok = (BsmRecord{}).Outcome(); ok {
+		t.Error("expected no outcome without return token")
+	}
+}
@@ -0,0 +1,48 @@
+// Parallel parsing of BSM records
+package bsm
+
+import (
+	"runtime"
+	"sync"
+)
+
+// ParseRecordsParallel parses all records of the given bytes using the
+// given number of goroutines (GOMAXPROCS if less than 1). The records are
+// split first (see SplitRecords) and parsed token by token without an
+// io.Reader (see ReadBsmRecordBytes). They are returned in their original
+// order.
+// Like ParseAll, the records before the first erroneous one are returned
+// together with its error.
+func ParseRecordsParallel(input []byte, workers int) ([]BsmRecord, error) {
+	chunks, offsets, splitErr := splitRecords(input)
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	records := make([]BsmRecord, len(chunks))
+	errs := make([]error, len(chunks))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				records[i], _, errs[i] = readBsmRecordBytes(chunks[i], int64(offsets[i]))
+			}
+		}()
+	}
+	for i := range chunks {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	// report the first error (in order of the records)
+	for i, err := range errs {
+		if err != nil {
+			return records[:i], err
+		}
+	}
+	return records, splitErr
+}
@@ -0,0 +1,74 @@
+// test parallel parsing of BSM records
+package bsm
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestParseRecordsParallel(t *testing.T) {
+	// many records to keep all workers busy
+	data := bytes.Repeat(rootLogin, 50)
+	expected, err := ParseAll(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{0, 1, 4} {
+		records, err := ParseRecordsParallel(data, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != len(expected) {
+			t.Fatalf("%d workers: expected %d records, got %d", workers, len(expected), len(records))
+		}
+		for i := range records {
+			if !records[i].Equal(expected[i]) {
+				t.Errorf("%d workers: record %d differs", workers, i)
+			}
+		}
+	}
+
+	// records before the first error
+	broken := append([]byte{}, data...)
+	broken[len(rootLogin)+5] = 0x2a // version of the 4th record
+	records, err := ParseRecordsParallel(broken, 4)
+	var perr *ParseError
+	if !errors.As(err, &perr) || !errors.Is(err, ErrBadVersion) {
+		t.Fatalf("expected a ParseError with ErrBadVersion, got %v", err)
+	}
+	if perr.Offset != int64(len(rootLogin)) || len(records) != 3 {
+		t.Errorf("unexpected error offset 0x%x or number of records %d", perr.Offset, len(records))
+	}
+
+	// offsets of tokens are relative to the input
+	broken = append([]byte{}, data...)
+	broken[len(rootLogin)+97-6] = 0x00 // trailer magic of the 4th record
+	records, err = ParseRecordsParallel(broken, 4)
+	if !errors.As(err, &perr) || !errors.Is(err, ErrBadTrailerMagic) {
+		t.Fatalf("expected a ParseError with ErrBadTrailerMagic, got %v", err)
+	}
+	if perr.Offset != int64(len(rootLogin)+97-7) || len(records) != 3 {
+		t.Errorf("unexpected error offset 0x%x or number of records %d", perr.Offset, len(records))
+	}
+
+	// truncated input
+	records, err = ParseRecordsParallel(rootLogin[:len(rootLogin)-3], 2)
+	if err != io.ErrUnexpectedEOF || len(records) != 2 {
+		t.Errorf("expected 2 records and io.ErrUnexpectedEOF, got %d, %v", len(records), err)
+	}
+}
+
+func BenchmarkParseRecordsParallel(b *testing.B) {
+	trail := &bytes.Buffer{}
+	if err := GenerateTrail(trail, 100000); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseRecordsParallel(trail.Bytes(), 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Ports of BSM tokens in network byte order
+package bsm
+
+// portBytes encodes a port number in network byte order.
+func portBytes(port uint16) [2]byte {
+	return [2]byte{byte(port >> 8), byte(port)}
+}
+
+// NetworkBytes returns the port number in network byte order (as found
+// in packet captures).
+func (t IPortToken) NetworkBytes() [2]byte {
+	return portBytes(t.PortNumber)
+}
+
+// NetworkBytes returns the local port in network byte order.
+func (t SocketToken) NetworkBytes() [2]byte {
+	return portBytes(t.LocalPort)
+}
+
+// NetworkBytes returns the local port in network byte order.
+func (t SocketInet32Token) NetworkBytes() [2]byte {
+	return portBytes(t.LocalPort)
+}
+
+// NetworkBytes returns the local port in network byte order.
+func (t SocketInet128Token) NetworkBytes() [2]byte {
+	return portBytes(t.LocalPort)
+}
+
+// LocalNetworkBytes returns the local port in network byte order.
+func (t ExpandedSocketToken) LocalNetworkBytes() [2]byte {
+	return portBytes(t.LocalPort)
+}
+
+// RemoteNetworkBytes returns the remote port in network byte order.
+func (t ExpandedSocketToken) RemoteNetworkBytes() [2]byte {
+	return portBytes(t.RemotePort)
+}
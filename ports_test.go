@@ -0,0 +1,25 @@
+// test ports of BSM tokens in network byte order
+package bsm
+
+import (
+	"testing"
+)
+
+func TestIPortToken_NetworkBytes(t *testing.T) {
+	token, err := ParseIPortToken([]byte{0x2c, 0x23, 0x42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.PortNumber != 9026 || token.NetworkBytes() != [2]byte{0x23, 0x42} {
+		t.Errorf("unexpected port: %d %x", token.PortNumber, token.NetworkBytes())
+	}
+
+	socket := SocketInet32Token{LocalPort: 22}
+	if socket.NetworkBytes() != [2]byte{0x00, 0x16} {
+		t.Errorf("unexpected local port: %x", socket.NetworkBytes())
+	}
+	expanded := ExpandedSocketToken{LocalPort: 443, RemotePort: 50000}
+	if expanded.LocalNetworkBytes() != [2]byte{0x01, 0xbb} || expanded.RemoteNetworkBytes() != [2]byte{0xc3, 0x50} {
+		t.Errorf("unexpected ports: %x %x", expanded.LocalNetworkBytes(), expanded.RemoteNetworkBytes())
+	}
+}
@@ -0,0 +1,180 @@
+// Buffered reading of BSM records
+package bsm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// recordReaderBufferSize is the size of the read buffer of a
+// RecordReader. It holds many typical records (~100 bytes each).
+const recordReaderBufferSize = 64 * 1024
+
+// RecordReader reads BSM records from a buffered input. Parsing tokens
+// results in many small reads, so reading directly from an unbuffered
+// input (e.g. *os.File) is slow for large audit trails.
+// The RecordReader consumes its input in large chunks, so don't read
+// from the same input via TokenFromByteInput or ReadBsmRecord once a
+// RecordReader was created.
+type RecordReader struct {
+	// Lenient enables the lenient mode: tokens of known size without a
+	// parser are returned as RawToken instead of failing with
+	// ErrUnknownTokenID, so the rest of the input can still be read.
+	// Tokens of unknown size still result in an error.
+	Lenient bool
+
+	input *bufio.Reader
+}
+
+// NewRecordReader creates a new buffered record reader for the given input.
+func NewRecordReader(input io.Reader) *RecordReader {
+	return &RecordReader{input: bufio.NewReaderSize(input, recordReaderBufferSize)}
+}
+
+// Next reads the next record. It returns io.EOF if no more records are
+// available (see ReadBsmRecord).
+func (r *RecordReader) Next() (BsmRecord, error) {
+	return readBsmRecord(r.input, 0, tokenDecoder{lenient: r.Lenient})
+}
+
+// Records yields all remaining records of the reader like
+// RecordGeneratorContext does. Don't call Next while the channel is open.
+func (r *RecordReader) Records(ctx context.Context) <-chan ParsingResult {
+	return generateRecords(ctx, r.input, tokenDecoder{lenient: r.Lenient})
+}
+
+// CountRecords counts the records of the given input without parsing
+// their tokens. Only the bytes needed to determine the size of a token
+// are read, the remaining bytes are skipped (by seeking if the input
+// supports it). Trailer tokens are read completely, so a truncated
+// record results in io.ErrUnexpectedEOF.
+func CountRecords(input io.Reader) (int, error) {
+	count := 0
+	inRecord := false
+	buffer := []byte{}
+	for {
+		var err error
+		buffer, err = skipToken(input, buffer)
+		if err == io.EOF && inRecord {
+			return count, io.ErrUnexpectedEOF // record ends without trailer
+		}
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		switch buffer[0] {
+		case 0x14, 0x15, 0x74, 0x79: // header tokens
+			if inRecord {
+				return count, errors.New("header token found within record")
+			}
+			inRecord = true
+		case 0x11: // file token
+			if !inRecord {
+				continue // boundary of concatenated trails
+			}
+		case 0x13: // trailer token
+			if !inRecord {
+				return count, errors.New("trailer token found outside of record")
+			}
+			inRecord = false
+			count += 1
+		default:
+			if !inRecord {
+				return count, errors.New("no header token found")
+			}
+		}
+	}
+}
+
+// SplitRecords splits the given bytes into records (from header to
+// trailer token) without parsing their tokens. The returned slices refer
+// to the given bytes, e.g. to hand them to ReadBsmRecord in parallel.
+// File tokens between records are dropped. The records found before an
+// error are returned together with the error.
+func SplitRecords(input []byte) ([][]byte, error) {
+	records, _, err := splitRecords(input)
+	return records, err
+}
+
+// splitRecords splits the given bytes into records (see SplitRecords)
+// and returns the offsets of the records as well.
+func splitRecords(input []byte) ([][]byte, []int, error) {
+	records := [][]byte{}
+	offsets := []int{}
+	start := -1 // beginning of the current record
+	for offset := 0; offset < len(input); {
+		size, err := tokenDecoder{}.sliceTokenSize(input[offset:])
+		if errors.Is(err, ErrShortToken) {
+			return records, offsets, io.ErrUnexpectedEOF // input ends within token
+		}
+		if err != nil {
+			return records, offsets, &ParseError{Offset: int64(offset), TokenID: input[offset], Err: err}
+		}
+
+		switch input[offset] {
+		case 0x14, 0x15, 0x74, 0x79: // header tokens
+			if start != -1 {
+				return records, offsets, &ParseError{Offset: int64(offset), TokenID: input[offset], Err: errors.New("header token found within record")}
+			}
+			start = offset
+		case 0x11: // file token
+		case 0x13: // trailer token
+			if start == -1 {
+				return records, offsets, &ParseError{Offset: int64(offset), TokenID: input[offset], Err: errors.New("trailer token found outside of record")}
+			}
+			end := offset + size
+			records = append(records, input[start:end:end])
+			offsets = append(offsets, start)
+			start = -1
+		default:
+			if start == -1 {
+				return records, offsets, &ParseError{Offset: int64(offset), TokenID: input[offset], Err: errors.New("no header token found")}
+			}
+		}
+		offset += size
+	}
+	if start != -1 {
+		return records, offsets, io.ErrUnexpectedEOF // record ends without trailer
+	}
+	return records, offsets, nil
+}
+
+// skipToken reads the leading bytes of the next token (into the given
+// buffer) until its size is known and skips the remaining bytes.
+// Trailer tokens are read completely.
+func skipToken(input io.Reader, buffer []byte) ([]byte, error) {
+	tokenBuffer, size, err := tokenDecoder{}.readTokenHead(input, buffer)
+	if err != nil {
+		return tokenBuffer, err
+	}
+	if tokenBuffer[0] == 0x13 { // detect truncated records
+		if size > len(tokenBuffer) {
+			tokenBuffer, err = readBytes(input, tokenBuffer, size-len(tokenBuffer))
+		}
+		return tokenBuffer, err
+	}
+	return tokenBuffer, skipBytes(input, size-len(tokenBuffer))
+}
+
+// skipBytes skips the given number of bytes of a started token. It seeks
+// if the input supports it, which does not detect a truncated input.
+func skipBytes(input io.Reader, count int) error {
+	if 0 == count {
+		return nil
+	}
+	if seeker, ok := input.(io.Seeker); ok {
+		_, err := seeker.Seek(int64(count), io.SeekCurrent)
+		return err
+	}
+	_, err := io.CopyN(ioutil.Discard, input, int64(count))
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF // token started already
+	}
+	return err
+}
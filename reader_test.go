@@ -0,0 +1,164 @@
+// test buffered reading of BSM records
+package bsm
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestRecordReader(t *testing.T) {
+	reader := NewRecordReader(bytes.NewBuffer(rootLogin))
+	events := []uint16{}
+	for {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		event, _ := rec.EventType()
+		events = append(events, event)
+	}
+	if len(events) != 3 || events[0] != 6159 || events[1] != 32800 || events[2] != 45001 {
+		t.Error("unexpected events read:", events)
+	}
+}
+
+func TestCountRecords(t *testing.T) {
+	count, err := CountRecords(bytes.NewBuffer(rootLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 records, got %d", count)
+	}
+
+	file, err := os.Open("start_stop.bsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	count, err = CountRecords(file) // skips by seeking
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 records, got %d", count)
+	}
+
+	// truncated trailer
+	count, err = CountRecords(bytes.NewBuffer(rootLogin[:len(rootLogin)-3]))
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 complete records, got %d", count)
+	}
+}
+
+func TestSplitRecords(t *testing.T) {
+	records, err := SplitRecords(rootLogin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	total := 0
+	for _, data := range records {
+		rec, err := ReadBsmRecord(bytes.NewBuffer(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int(rec.Trailer.RecordByteCount) != len(data) {
+			t.Errorf("record of %d bytes has byte count %d", len(data), rec.Trailer.RecordByteCount)
+		}
+		total += len(data)
+	}
+	if total != len(rootLogin) {
+		t.Errorf("records cover %d of %d bytes", total, len(rootLogin))
+	}
+
+	// record without trailer
+	records, err = SplitRecords(rootLogin[:len(rootLogin)-7])
+	if err != io.ErrUnexpectedEOF || len(records) != 2 {
+		t.Errorf("expected 2 records and io.ErrUnexpectedEOF, got %d, %v", len(records), err)
+	}
+
+	// token outside of a record
+	_, err = SplitRecords(rootLogin[1:])
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Errorf("expected a ParseError, got %v", err)
+	}
+}
+
+// writeSyntheticTrail writes a temporary audit file with the given
+// number of (56 byte) records and returns its path.
+func writeSyntheticTrail(b *testing.B, records int) string {
+	file, err := ioutil.TempFile("", "go-bsm")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+	if err := GenerateTrail(file, records); err != nil {
+		b.Fatal(err)
+	}
+	return file.Name()
+}
+
+// benchmarkReading reads all records of a synthetic ~5 MB trail per iteration.
+func benchmarkReading(b *testing.B, next func(io.Reader) func() (BsmRecord, error)) {
+	path := writeSyntheticTrail(b, 100000)
+	defer os.Remove(path)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		file, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		read := next(file)
+		for {
+			if _, err := read(); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+		file.Close()
+	}
+}
+
+func BenchmarkReadBsmRecord_unbuffered(b *testing.B) {
+	benchmarkReading(b, func(input io.Reader) func() (BsmRecord, error) {
+		return func() (BsmRecord, error) { return ReadBsmRecord(input) }
+	})
+}
+
+func BenchmarkRecordReader_buffered(b *testing.B) {
+	benchmarkReading(b, func(input io.Reader) func() (BsmRecord, error) {
+		return NewRecordReader(input).Next
+	})
+}
+
+func BenchmarkCountRecords(b *testing.B) {
+	path := writeSyntheticTrail(b, 100000)
+	defer os.Remove(path)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		file, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := CountRecords(bufio.NewReaderSize(file, recordReaderBufferSize)); err != nil {
+			b.Fatal(err)
+		}
+		file.Close()
+	}
+}
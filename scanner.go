@@ -0,0 +1,80 @@
+// Scanning of BSM tokens with a reusable buffer
+package bsm
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// TokenScanner reads BSM tokens from an input, reusing its internal read
+// buffer across tokens. This avoids most per-token allocations of
+// TokenFromByteInput, which is still the simpler choice for occasional use.
+type TokenScanner struct {
+	// ByteOrder is the byte order of the integer fields of scanned tokens
+	// (including the length fields used to determine token sizes). BSM is
+	// defined in network byte order (big endian, used if nil), so setting
+	// it to binary.LittleEndian is non-standard and only meant for interop
+	// with emulated or cross-ported producers. Port numbers and IP
+	// addresses are always read in network byte order.
+	ByteOrder binary.ByteOrder
+
+	// Lenient enables the lenient mode: tokens of known size without a
+	// parser are returned as RawToken (see RecordReader.Lenient).
+	Lenient bool
+
+	input  io.Reader
+	buffer []byte        // reused for the bytes of each token
+	wanted map[byte]bool // token IDs returned by Scan (nil: all)
+}
+
+// NewTokenScanner creates a new token scanner for the given input.
+func NewTokenScanner(input io.Reader) *TokenScanner {
+	return &TokenScanner{input: input, buffer: make([]byte, 0, 128)}
+}
+
+// Reset makes the scanner read from the given input, keeping its buffer.
+func (s *TokenScanner) Reset(input io.Reader) {
+	s.input = input
+}
+
+// Select restricts Scan to tokens with the given IDs. All other tokens
+// are skipped without being parsed. Their bytes are skipped by seeking if
+// the input implements io.Seeker (e.g. *os.File) and read otherwise.
+// Calling Select without IDs makes Scan return all tokens again.
+func (s *TokenScanner) Select(ids ...byte) {
+	if 0 == len(ids) {
+		s.wanted = nil
+		return
+	}
+	s.wanted = map[byte]bool{}
+	for _, id := range ids {
+		s.wanted[id] = true
+	}
+}
+
+// Scan reads the next (selected) token. It returns io.EOF if the input is
+// exhausted before a new token starts. In lenient mode (see Lenient),
+// tokens of known size without parser are returned as RawToken.
+func (s *TokenScanner) Scan() (Token, error) {
+	decoder := tokenDecoder{order: s.ByteOrder, lenient: s.Lenient}
+	for {
+		var size int
+		var err error
+		s.buffer, size, err = decoder.readTokenHead(s.input, s.buffer)
+		if err != nil {
+			return nil, err
+		}
+		if s.wanted == nil || s.wanted[s.buffer[0]] {
+			if size > len(s.buffer) {
+				s.buffer, err = readBytes(s.input, s.buffer, size-len(s.buffer))
+				if err != nil {
+					return nil, err
+				}
+			}
+			return decoder.parseTokenBuffer(s.buffer)
+		}
+		if err = skipBytes(s.input, size-len(s.buffer)); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// test scanning of BSM tokens with a reusable buffer
+package bsm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestTokenScanner(t *testing.T) {
+	scanner := NewTokenScanner(bytes.NewReader(rootLogin))
+	tokens := []Token{}
+	for {
+		token, err := scanner.Scan()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, token)
+	}
+	if len(tokens) != 14 { // 5 + 5 + 4 tokens in 3 records
+		t.Fatal("unexpected number of tokens:", len(tokens))
+	}
+	// tokens must not share the reused buffer
+	if tokens[2].(TextToken).Text != "successful authentication" ||
+		tokens[7].(TextToken).Text != "successful login root" {
+		t.Error("unexpected text tokens:", tokens[2], tokens[7])
+	}
+
+	scanner.Reset(bytes.NewReader(rootLogin[:10]))
+	if _, err := scanner.Scan(); err == nil || err == io.EOF {
+		t.Error("expected an error on truncated token, got", err)
+	}
+}
+
+// seekCounter counts the calls of Seek.
+type seekCounter struct {
+	*os.File
+	seeks int
+}
+
+func (s *seekCounter) Seek(offset int64, whence int) (int64, error) {
+	s.seeks += 1
+	return s.File.Seek(offset, whence)
+}
+
+func TestTokenScanner_Select(t *testing.T) {
+	scanAll := func(input io.Reader) []Token {
+		scanner := NewTokenScanner(input)
+		scanner.Select(0x14, 0x13) // headers and trailers only
+		tokens := []Token{}
+		for {
+			token, err := scanner.Scan()
+			if err == io.EOF {
+				return tokens
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokens = append(tokens, token)
+		}
+	}
+
+	file, err := os.Open("start_stop.bsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	seekable := &seekCounter{File: file}
+	seeked := scanAll(seekable)
+	if 0 == seekable.seeks {
+		t.Error("expected token bodies to be skipped by seeking")
+	}
+
+	data, err := ioutil.ReadFile("start_stop.bsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := scanAll(bytes.NewBuffer(data)) // not seekable
+
+	if len(seeked) != 4 || !reflect.DeepEqual(seeked, read) {
+		t.Error("unexpected tokens:", seeked, read)
+	}
+	for _, token := range read {
+		if token.Type() != 0x14 && token.Type() != 0x13 {
+			t.Error("unexpected token:", token)
+		}
+	}
+}
+
+func BenchmarkTokenFromByteInput(b *testing.B) {
+	input := bytes.NewReader(rootLogin)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		input.Reset(rootLogin)
+		for {
+			if _, err := TokenFromByteInput(input); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkTokenScanner(b *testing.B) {
+	input := bytes.NewReader(rootLogin)
+	scanner := NewTokenScanner(input)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		input.Reset(rootLogin)
+		for {
+			if _, err := scanner.Scan(); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestTokenScanner_lenient(t *testing.T) {
+	// arbitrary data token (no parser) followed by a text token
+	arbitrary := []byte{0x21, 0x00, 0x02, 0x02, 0xde, 0xad, 0xbe, 0xef}
+	text, _ := TextToken{Text: "next"}.Marshal()
+	scanner := NewTokenScanner(bytes.NewReader(append(append([]byte{}, arbitrary...), text...)))
+	scanner.Lenient = true
+
+	token, err := scanner.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, ok := token.(RawToken)
+	if !ok || raw.Type() != 0x21 || !bytes.Equal(raw.Raw, arbitrary) {
+		t.Error("expected a raw arbitrary data token, got", token)
+	}
+	token, err = scanner.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok = token.(TextToken); !ok {
+		t.Error("expected a text token, got", token)
+	}
+	// the raw token must not share the reused buffer
+	if !bytes.Equal(raw.Raw, arbitrary) {
+		t.Error("raw token changed by subsequent scan:", raw)
+	}
+}
+
+func TestTokenScanner_ByteOrder(t *testing.T) {
+	data := []byte{
+		0x14,                   // --- 32bit header token ID
+		0x38, 0x00, 0x00, 0x00, // 56 bytes in record (little endian)
+		0x0b,       // version number
+		0xc8, 0xaf, // event type (little endian)
+		0x00, 0x00, // event modifier / sub-type
+		0xe6, 0xc2, 0x9a, 0x5a, // timestamp seconds (little endian)
+		0x01, 0x03, 0x00, 0x00, // timestamp nanoseconds (little endian)
+		0x28,       // --- text token ID
+		0x05, 0x00, // string length (little endian)
+		0x74, 0x65, 0x73, 0x74, 0x00, // "test"
+	}
+	scanner := NewTokenScanner(bytes.NewReader(data))
+	scanner.ByteOrder = binary.LittleEndian
+	token, err := scanner.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, ok := token.(HeaderToken32bit)
+	if !ok {
+		t.Fatal("expected a header token, got", token)
+	}
+	if header.RecordByteCount != 56 || header.EventType != 45000 || header.Seconds != 1520091878 || header.NanoSeconds != 769 {
+		t.Error("unexpected header token:", header)
+	}
+	token, err = scanner.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, ok := token.(TextToken); !ok || text.Text != "test" {
+		t.Error("unexpected text token:", token)
+	}
+
+	// port numbers stay in network byte order
+	scanner.Reset(bytes.NewReader([]byte{
+		0x2c,       // --- iport token ID
+		0x00, 0x16, // port 22 (network byte order)
+		0x80,       // --- inet32 socket token ID
+		0x02, 0x00, // AF_INET (little endian)
+		0x01, 0xbb, // port 443 (network byte order)
+		0x7f, 0x00, 0x00, 0x01, // 127.0.0.1
+	}))
+	token, err = scanner.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iport, ok := token.(IPortToken); !ok || iport.PortNumber != 22 {
+		t.Error("unexpected iport token:", token)
+	}
+	token, err = scanner.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if socket, ok := token.(SocketInet32Token); !ok || socket.SocketFamily != 2 || socket.LocalPort != 443 {
+		t.Error("unexpected socket token:", token)
+	}
+
+	// the same bytes are garbage in network byte order
+	scanner.ByteOrder = nil
+	scanner.Reset(bytes.NewReader(data))
+	token, err = scanner.Scan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.(HeaderToken32bit).RecordByteCount == 56 {
+		t.Error("expected a garbled record byte count")
+	}
+}
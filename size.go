@@ -0,0 +1,202 @@
+// Byte sizes of serialized BSM tokens
+package bsm
+
+import (
+	"net"
+)
+
+// Sizer is implemented by all tokens of this package. Size returns the
+// number of bytes of the serialized token, which is the size determined
+// by the parser for the same token.
+type Sizer interface {
+	Size() int // size of the serialized token in bytes
+}
+
+// addressSize returns the number of bytes used to serialize the given
+// address in expanded tokens. A length (type) field of 4 or 16, e.g. as
+// read by the parser, is used as is. Otherwise the size follows from the
+// address: 4 bytes for IPv4 (or unset) addresses and 16 bytes otherwise.
+func addressSize(length uint32, address net.IP) int {
+	if length == 4 || length == 16 {
+		return int(length)
+	}
+	if 0 == len(address) || address.To4() != nil {
+		return 4
+	}
+	return 16
+}
+
+// stringsSize returns the number of bytes of the given strings if
+// each of them is NUL-terminated.
+func stringsSize(text []string) int {
+	size := 0
+	for _, s := range text {
+		size += len(s) + 1
+	}
+	return size
+}
+
+// Size returns the size of the serialized 32 bit arg token.
+func (t ArgToken32bit) Size() int { return 1 + 1 + 4 + 2 + len(t.Text) + 1 }
+
+// Size returns the size of the serialized 64 bit arg token.
+func (t ArgToken64bit) Size() int { return 1 + 1 + 8 + 2 + len(t.Text) + 1 }
+
+// Size returns the size of the serialized arbitrary data token.
+func (t ArbitraryDataToken) Size() int {
+	size := 1 + 1 + 1 + 1
+	for _, item := range t.DataItems {
+		size += len(item)
+	}
+	return size
+}
+
+// Size returns the size of the serialized 32 bit attribute token (29 bytes).
+func (t AttributeToken32bit) Size() int { return 1 + 4 + 4 + 4 + 4 + 8 + 4 }
+
+// Size returns the size of the serialized 64 bit attribute token (33 bytes).
+func (t AttributeToken64bit) Size() int { return 1 + 4 + 4 + 4 + 4 + 8 + 8 }
+
+// Size returns the size of the serialized exec args token.
+func (t ExecArgsToken) Size() int { return 1 + 4 + stringsSize(t.Text) }
+
+// Size returns the size of the serialized exec env token.
+func (t ExecEnvToken) Size() int { return 1 + 4 + stringsSize(t.Text) }
+
+// Size returns the size of the serialized exit token (9 bytes).
+func (t ExitToken) Size() int { return 1 + 4 + 4 }
+
+// Size returns the size of the serialized file token.
+func (t FileToken) Size() int { return 1 + 4 + 4 + 2 + len(t.PathName) + 1 }
+
+// Size returns the size of the serialized groups token.
+func (t GroupsToken) Size() int { return 1 + 2 + 4*len(t.GroupList) }
+
+// Size returns the size of the serialized 32 bit header token (18 bytes).
+func (t HeaderToken32bit) Size() int { return 1 + 4 + 1 + 2 + 2 + 4 + 4 }
+
+// Size returns the size of the serialized 64 bit header token (26 bytes).
+func (t HeaderToken64bit) Size() int { return 1 + 4 + 1 + 2 + 2 + 8 + 8 }
+
+// Size returns the size of the serialized 32 bit expanded header token
+// (26 or 38 bytes).
+func (t ExpandedHeaderToken32bit) Size() int {
+	return 1 + 4 + 1 + 2 + 2 + 4 + addressSize(t.AddressType, t.MachineAddress) + 4 + 4
+}
+
+// Size returns the size of the serialized 64 bit expanded header token
+// (34 or 46 bytes).
+func (t ExpandedHeaderToken64bit) Size() int {
+	return 1 + 4 + 1 + 2 + 2 + 4 + addressSize(t.AddressType, t.MachineAddress) + 8 + 8
+}
+
+// Size returns the size of the serialized in_addr token (5 bytes).
+func (t InAddrToken) Size() int { return 1 + 4 }
+
+// Size returns the size of the serialized expanded in_addr token. It
+// depends on ExpandedInAddrLayout.
+func (t ExpandedInAddrToken) Size() int {
+	if ExpandedInAddrLayout == ManpageLayout {
+		return 1 + 1 + 16
+	}
+	return 1 + 4 + addressSize(uint32(t.IpAddressType), t.IpAddress)
+}
+
+// Size returns the size of the serialized ip token (21 bytes).
+func (t IpToken) Size() int { return 1 + 1 + 1 + 2 + 2 + 2 + 1 + 1 + 2 + 4 + 4 }
+
+// Size returns the size of the serialized iport token (3 bytes).
+func (t IPortToken) Size() int { return 1 + 2 }
+
+// Size returns the size of the serialized path token.
+func (t PathToken) Size() int { return 1 + 2 + len(t.Path) + 1 }
+
+// Size returns the size of the serialized path_attr token.
+func (t PathAttrToken) Size() int { return 1 + 2 + stringsSize(t.Path) }
+
+// Size returns the size of the serialized 32 bit process token (37 bytes).
+func (t ProcessToken32bit) Size() int { return 1 + 4*7 + 4 + 4 }
+
+// Size returns the size of the serialized 64 bit process token (41 bytes).
+func (t ProcessToken64bit) Size() int { return 1 + 4*7 + 8 + 4 }
+
+// Size returns the size of the serialized 32 bit expanded process token
+// (41 or 53 bytes).
+func (t ExpandedProcessToken32bit) Size() int {
+	return 1 + 4*7 + 4 + 4 + addressSize(t.TerminalAddressLength, t.TerminalMachineAddress)
+}
+
+// Size returns the size of the serialized 64 bit expanded process token
+// (45 or 57 bytes).
+func (t ExpandedProcessToken64bit) Size() int {
+	return 1 + 4*7 + 8 + 4 + addressSize(t.TerminalAddressLength, t.TerminalMachineAddress)
+}
+
+// Size returns the size of the raw token.
+func (t RawToken) Size() int { return len(t.Raw) }
+
+// Size returns the size of the serialized 32 bit return token (6 bytes).
+func (t ReturnToken32bit) Size() int { return 1 + 1 + 4 }
+
+// Size returns the size of the serialized 64 bit return token (10 bytes).
+func (t ReturnToken64bit) Size() int { return 1 + 1 + 8 }
+
+// Size returns the size of the serialized seq token (5 bytes).
+func (t SeqToken) Size() int { return 1 + 4 }
+
+// Size returns the size of the serialized socket token (9 bytes).
+func (t SocketToken) Size() int { return 1 + 2 + 2 + 4 }
+
+// Size returns the size of the serialized inet32 socket token (9 bytes).
+func (t SocketInet32Token) Size() int { return 1 + 2 + 2 + 4 }
+
+// Size returns the size of the serialized inet128 socket token (21 bytes).
+func (t SocketInet128Token) Size() int { return 1 + 2 + 2 + 16 }
+
+// Size returns the size of the serialized unix socket token.
+func (t SocketUnixToken) Size() int { return 1 + 2 + len(t.Path) + 1 }
+
+// Size returns the size of the serialized expanded socket token (19 or
+// 43 bytes). Both addresses share the address type, so an IPv6 address
+// on either side makes both of them 16 bytes long.
+func (t ExpandedSocketToken) Size() int {
+	addrlen := addressSize(uint32(t.AddressType), t.LocalIpAddress)
+	if remote := addressSize(uint32(t.AddressType), t.RemoteIpAddress); remote > addrlen {
+		addrlen = remote
+	}
+	return 1 + 2 + 2 + 2 + 2 + addrlen + 2 + addrlen
+}
+
+// Size returns the size of the serialized 32 bit subject token (37 bytes).
+func (t SubjectToken32bit) Size() int { return 1 + 4*7 + 4 + 4 }
+
+// Size returns the size of the serialized 64 bit subject token (41 bytes).
+func (t SubjectToken64bit) Size() int { return 1 + 4*7 + 8 + 4 }
+
+// Size returns the size of the serialized 32 bit expanded subject token
+// (41 or 53 bytes).
+func (t ExpandedSubjectToken32bit) Size() int {
+	return 1 + 4*7 + 4 + 4 + addressSize(t.TerminalAddressLength, t.TerminalMachineAddress)
+}
+
+// Size returns the size of the serialized 64 bit expanded subject token
+// (45 or 57 bytes).
+func (t ExpandedSubjectToken64bit) Size() int {
+	return 1 + 4*7 + 8 + 4 + addressSize(t.TerminalAddressLength, t.TerminalMachineAddress)
+}
+
+// Size returns the size of the serialized System V IPC token (6 bytes).
+func (t SystemVIpcToken) Size() int { return 1 + 1 + 4 }
+
+// Size returns the size of the serialized System V IPC permission token
+// (29 bytes).
+func (t SystemVIpcPermissionToken) Size() int { return 1 + 4*7 }
+
+// Size returns the size of the serialized text token.
+func (t TextToken) Size() int { return 1 + 2 + len(t.Text) + 1 }
+
+// Size returns the size of the serialized trailer token (7 bytes).
+func (t TrailerToken) Size() int { return 1 + 2 + 4 }
+
+// Size returns the size of the serialized zonename token.
+func (t ZonenameToken) Size() int { return 1 + 2 + len(t.Zonename) + 1 }
@@ -0,0 +1,113 @@
+// test sizes of serialized BSM tokens
+package bsm
+
+import (
+	"net"
+	"testing"
+)
+
+// cross-check Size() with the size determined by the parser
+func TestSizer_marshaled(t *testing.T) {
+	ipv4 := net.IP{192, 168, 1, 10}
+	ipv6 := net.ParseIP("2001:db8::1")
+	testData := []Token{
+		HeaderToken32bit{},
+		TextToken{Text: "auditd::Audit startup"},
+		TextToken{},
+		PathToken{Path: "/usr/sbin/auditd"},
+		ZonenameToken{Zonename: "jail"},
+		ReturnToken32bit{},
+		ReturnToken64bit{},
+		ExitToken{},
+		SubjectToken32bit{TerminalMachineAddress: ipv4},
+		SubjectToken64bit{},
+		ExpandedSubjectToken32bit{TerminalMachineAddress: ipv4},
+		ExpandedSubjectToken32bit{TerminalMachineAddress: ipv6},
+		ExpandedSubjectToken64bit{TerminalMachineAddress: ipv4},
+		ExpandedSubjectToken64bit{TerminalMachineAddress: ipv6},
+		ProcessToken32bit{},
+		ProcessToken64bit{TerminalMachineAddress: ipv4},
+		ExpandedProcessToken32bit{TerminalMachineAddress: ipv6},
+		ExpandedProcessToken64bit{TerminalMachineAddress: ipv4},
+		ExpandedProcessToken64bit{TerminalMachineAddress: ipv6},
+		TrailerToken{},
+	}
+	for _, token := range testData {
+		data, err := token.(Marshaler).Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := token.(Sizer).Size()
+		if size != len(data) {
+			t.Errorf("size of token 0x%x (%d) does not match serialized size (%d)", data[0], size, len(data))
+		}
+		dsize, _, err := determineTokenSize(data)
+		if err != nil {
+			t.Error(err)
+		}
+		if size != dsize {
+			t.Errorf("size of token 0x%x (%d) does not match determined size (%d)", data[0], size, dsize)
+		}
+	}
+}
+
+// cross-check Size() of tokens without Marshal() with hand crafted input
+func TestSizer_handcrafted(t *testing.T) {
+	ipv6 := net.ParseIP("2001:db8::1")
+	testData := []struct {
+		token Sizer
+		input []byte
+	}{
+		{ArgToken32bit{Text: "ab"}, []byte{0x2d, 0x01, 0x00, 0x00, 0x00, 0x02, 0x00, 0x03, 'a', 'b', 0x00}},
+		{ArgToken64bit{Text: "ab"}, []byte{0x71, 0x01, 0, 0, 0, 0, 0, 0, 0, 0x02, 0x00, 0x03, 'a', 'b', 0x00}},
+		{ArbitraryDataToken{BasicUnit: 2, UnitCount: 2, DataItems: [][]byte{{1, 2}, {3, 4}}},
+			[]byte{0x21, 0x00, 0x02, 0x02, 1, 2, 3, 4}},
+		{AttributeToken32bit{}, []byte{0x3e}},
+		{AttributeToken64bit{}, []byte{0x73}},
+		{ExecArgsToken{Count: 2, Text: []string{"ls", "-l"}}, []byte{0x3c, 0, 0, 0, 2, 'l', 's', 0, '-', 'l', 0}},
+		{ExecEnvToken{}, []byte{0x3d, 0, 0, 0, 0}},
+		{FileToken{PathName: "trail"}, append([]byte{0x11, 0, 0, 0, 0, 0, 0, 0, 0, 0x00, 0x05}, "trail\x00"...)},
+		{GroupsToken{NumberOfGroups: 2, GroupList: []uint32{0, 5}}, []byte{0x34, 0x00, 0x02}},
+		{HeaderToken64bit{}, []byte{0x74}},
+		{ExpandedHeaderToken32bit{MachineAddress: net.IP{10, 0, 0, 1}},
+			[]byte{0x15, 0, 0, 0, 0, 11, 0, 0, 0, 0, 0, 0, 0, 4, 0}},
+		{ExpandedHeaderToken64bit{MachineAddress: ipv6},
+			[]byte{0x79, 0, 0, 0, 0, 11, 0, 0, 0, 0, 0, 0, 0, 16, 0}},
+		{InAddrToken{}, []byte{0x2a}},
+		{ExpandedInAddrToken{IpAddress: ipv6}, []byte{0x7e, 0, 0, 0, 16}},
+		{IpToken{}, []byte{0x2b}},
+		{IPortToken{}, []byte{0x2c}},
+		{PathAttrToken{Count: 2, Path: []string{"/", "/etc"}}, []byte{0x25, 0, 2, '/', 0, '/', 'e', 't', 'c', 0}},
+		{SeqToken{}, []byte{0x2f}},
+		{SocketToken{}, []byte{0x2e}},
+		{SocketInet32Token{}, []byte{0x80}},
+		{SocketInet128Token{}, []byte{0x81}},
+		{SocketUnixToken{Path: "/tmp/s"}, append([]byte{0x82, 0x00, 0x01}, "/tmp/s\x00"...)},
+		{ExpandedSocketToken{LocalIpAddress: ipv6}, []byte{0x7f, 0, 0, 0, 0, 0, 16}},
+		{ExpandedSocketToken{}, []byte{0x7f, 0, 0, 0, 0, 0, 4}},
+		{SystemVIpcToken{}, []byte{0x22}},
+		{SystemVIpcPermissionToken{}, []byte{0x32}},
+	}
+	for _, test := range testData {
+		dsize, _, err := determineTokenSize(test.input)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if test.token.Size() != dsize {
+			t.Errorf("size of token 0x%x (%d) does not match determined size (%d)", test.input[0], test.token.Size(), dsize)
+		}
+	}
+}
+
+func TestExpandedInAddrToken_Size(t *testing.T) {
+	defer func(layout TokenLayout) { ExpandedInAddrLayout = layout }(ExpandedInAddrLayout)
+	token := ExpandedInAddrToken{IpAddress: net.IP{10, 0, 0, 1}}
+	if token.Size() != 9 {
+		t.Error("unexpected libbsm size:", token.Size())
+	}
+	ExpandedInAddrLayout = ManpageLayout
+	if token.Size() != 18 {
+		t.Error("unexpected manpage size:", token.Size())
+	}
+}
@@ -0,0 +1,24 @@
+// Human-readable strings of BSM records
+package bsm
+
+// Strings returns the strings of all path, path_attr, text, exec_args
+// and exec_env tokens of the record in the order of the tokens. This is
+// handy for finding records which mention a given file or command.
+func (r BsmRecord) Strings() []string {
+	strs := []string{}
+	for _, token := range r.Tokens {
+		switch t := token.(type) {
+		case PathToken:
+			strs = append(strs, t.Path)
+		case PathAttrToken:
+			strs = append(strs, t.Path...)
+		case TextToken:
+			strs = append(strs, t.Text)
+		case ExecArgsToken:
+			strs = append(strs, t.Text...)
+		case ExecEnvToken:
+			strs = append(strs, t.Text...)
+		}
+	}
+	return strs
+}
@@ -0,0 +1,34 @@
+// test human-readable strings of BSM records
+package bsm
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestBsmRecord_Strings(t *testing.T) {
+	records, err := ParseAll(bytes.NewBuffer(rootLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strs := records[1].Strings(); !reflect.DeepEqual(strs, []string{"successful login root"}) {
+		t.Error("unexpected strings of root login:", strs)
+	}
+
+	rec := BsmRecord{Tokens: []Token{
+		ExecArgsToken{TokenID: 0x3c, Count: 2, Text: []string{"cat", "/etc/shadow"}},
+		PathToken{TokenID: 0x23, Path: "/bin/cat"},
+		SubjectToken32bit{TokenID: 0x24},
+		ExecEnvToken{TokenID: 0x3d, Count: 1, Text: []string{"HOME=/root"}},
+		TextToken{TokenID: 0x28, Text: "note"},
+	}}
+	expected := []string{"cat", "/etc/shadow", "/bin/cat", "HOME=/root", "note"}
+	if strs := rec.Strings(); !reflect.DeepEqual(strs, expected) {
+		t.Error("unexpected strings:", strs)
+	}
+
+	if strs := (BsmRecord{}).Strings(); len(strs) != 0 {
+		t.Error("expected no strings:", strs)
+	}
+}
@@ -0,0 +1,58 @@
+// Subjects of BSM records
+package bsm
+
+import (
+	"net"
+	"sort"
+)
+
+// Subject holds the fields common to all variants of subject tokens
+// (32/64 bit, expanded or not).
+type Subject struct {
+	AuditID          uint32 // audit user ID
+	EffectiveUserID  uint32 // effective user ID
+	EffectiveGroupID uint32 // effective group ID
+	RealUserID       uint32 // real user ID
+	RealGroupID      uint32 // real group ID
+	ProcessID        uint32 // process ID
+	SessionID        uint32 // audit session ID
+	TerminalPortID   uint64 // terminal port ID
+	TerminalAddress  net.IP // IP address of the terminal machine
+}
+
+// Subject returns the first subject token of the record (regardless of
+// its variant). False is returned if the record has no subject token.
+func (r BsmRecord) Subject() (Subject, bool) {
+	for _, token := range r.Tokens {
+		switch t := token.(type) {
+		case SubjectToken32bit:
+			return Subject{t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+				t.ProcessID, t.SessionID, uint64(t.TerminalPortID), t.TerminalMachineAddress}, true
+		case SubjectToken64bit:
+			return Subject{t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+				t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress}, true
+		case ExpandedSubjectToken32bit:
+			return Subject{t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+				t.ProcessID, t.SessionID, uint64(t.TerminalPortID), t.TerminalMachineAddress}, true
+		case ExpandedSubjectToken64bit:
+			return Subject{t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+				t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress}, true
+		}
+	}
+	return Subject{}, false
+}
+
+// SortedGroups returns the group IDs of the token sorted in ascending
+// order without duplicates (e.g. for membership checks). GroupList is
+// left unchanged.
+func (t GroupsToken) SortedGroups() []uint32 {
+	groups := append([]uint32(nil), t.GroupList...)
+	sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+	unique := groups[:0]
+	for _, gid := range groups {
+		if 0 == len(unique) || gid != unique[len(unique)-1] {
+			unique = append(unique, gid)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,49 @@
+// test subjects of BSM records
+package bsm
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestBsmRecord_Subject(t *testing.T) {
+	records, err := ParseAll(bytes.NewBuffer(rootLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// root login with expanded 32 bit subject token
+	subject, ok := records[1].Subject()
+	if !ok {
+		t.Fatal("expected a subject")
+	}
+	if subject.AuditID != 0 || subject.EffectiveUserID != 0 || subject.RealUserID != 0 {
+		t.Error("unexpected user IDs:", subject)
+	}
+	if subject.ProcessID != 821 || subject.SessionID != 821 || subject.TerminalPortID != 7269 {
+		t.Error("unexpected process:", subject)
+	}
+	if subject.TerminalAddress.String() != "93.184.216.38" {
+		t.Error("unexpected terminal address: " + subject.TerminalAddress.String())
+	}
+
+	// audit shutdown without subject token
+	if _, ok := records[2].Subject(); ok {
+		t.Error("expected no subject")
+	}
+}
+
+func TestGroupsToken_SortedGroups(t *testing.T) {
+	token := GroupsToken{TokenID: 0x34, NumberOfGroups: 5, GroupList: []uint32{20, 0, 5, 20, 12}}
+	expected := []uint32{0, 5, 12, 20}
+	if groups := token.SortedGroups(); !reflect.DeepEqual(groups, expected) {
+		t.Errorf("expected %v, got %v", expected, groups)
+	}
+	if !reflect.DeepEqual(token.GroupList, []uint32{20, 0, 5, 20, 12}) {
+		t.Error("group list was modified:", token.GroupList)
+	}
+	if groups := (GroupsToken{}).SortedGroups(); len(groups) != 0 {
+		t.Error("expected no groups, got", groups)
+	}
+}
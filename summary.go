@@ -0,0 +1,65 @@
+// Summaries of whole audit trails
+package bsm
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// Summary gives an overview of an audit trail.
+type Summary struct {
+	Records   int            // number of records
+	Events    map[uint16]int // number of records by event type
+	Users     map[uint32]int // number of records by audit user ID (of the subject)
+	Successes int            // number of records with successful return token
+	Failures  int            // number of records with failed return token
+	First     time.Time      // earliest time stamp (UTC)
+	Last      time.Time      // latest time stamp (UTC)
+}
+
+// Summarize reads all records of the given input and counts them by
+// event type, audit user ID (records without subject are not counted)
+// and outcome (records without return token are not counted). The time
+// span covered is determined by the earliest and latest time stamp, so
+// the records don't have to be ordered. The summary of the records read
+// up to the first error is returned together with this error.
+func Summarize(r io.Reader) (Summary, error) {
+	summary := Summary{Events: map[uint16]int{}, Users: map[uint32]int{}}
+	for result := range RecordGenerator(r) {
+		if result.Error != nil && !errors.Is(result.Error, ErrTrailerWarning) {
+			return summary, result.Error
+		}
+		if result.Boundary != nil {
+			continue
+		}
+		rec := result.Record
+		summary.Records += 1
+		if event, ok := rec.EventType(); ok {
+			summary.Events[event] += 1
+		}
+		if subject, ok := rec.Subject(); ok {
+			summary.Users[subject.AuditID] += 1
+		}
+		if success, _, _, ok := rec.Outcome(); ok {
+			if success {
+				summary.Successes += 1
+			} else {
+				summary.Failures += 1
+			}
+		}
+		timestamp := time.Unix(int64(rec.Seconds), int64(rec.NanoSeconds)).UTC()
+		if summary.First.IsZero() || timestamp.Before(summary.First) {
+			summary.First = timestamp
+		}
+		if summary.Last.IsZero() || timestamp.After(summary.Last) {
+			summary.Last = timestamp
+		}
+	}
+	return summary, nil
+}
+
+// Span returns the time span covered by the summarized records.
+func (s Summary) Span() time.Duration {
+	return s.Last.Sub(s.First)
+}
@@ -0,0 +1,38 @@
+// test summaries of whole audit trails
+package bsm
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	summary, err := Summarize(bytes.NewBuffer(rootLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Records != 3 {
+		t.Error("unexpected number of records:", summary.Records)
+	}
+	if len(summary.Events) != 3 || summary.Events[45001] != 1 {
+		t.Error("unexpected events:", summary.Events)
+	}
+	// the one root login
+	if summary.Users[0] != 1 {
+		t.Error("expected one record of root, got", summary.Users)
+	}
+	if summary.Successes != 3 || summary.Failures != 0 {
+		t.Error("unexpected outcomes:", summary.Successes, summary.Failures)
+	}
+	first := time.Date(2018, time.March, 3, 15, 41, 19, 867, time.UTC)
+	if !summary.First.Equal(first) || summary.Span() != 36*time.Second+62 {
+		t.Error("unexpected time span:", summary.First, summary.Last)
+	}
+
+	// partial summary on error
+	summary, err = Summarize(bytes.NewBuffer(rootLogin[:len(rootLogin)-3]))
+	if err == nil || summary.Records != 2 {
+		t.Error("expected 2 records and an error, got", summary.Records, err)
+	}
+}
@@ -0,0 +1,49 @@
+// Reading BSM records from never-ending streams
+package bsm
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// tailPollInterval is the time to wait before reading again once the
+// end of the input was reached.
+var tailPollInterval = 200 * time.Millisecond
+
+// tailReader reads from an input which may grow (e.g. an audit trail
+// being written or /dev/auditpipe). The end of the input is treated as
+// momentary, reading is retried until the context is cancelled.
+type tailReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	for {
+		n, err := t.reader.Read(p)
+		if n > 0 && err == io.EOF {
+			return n, nil
+		}
+		if n > 0 || (err != nil && err != io.EOF) {
+			return n, err
+		}
+		select {
+		case <-t.ctx.Done():
+			return 0, t.ctx.Err()
+		case <-time.After(tailPollInterval):
+		}
+	}
+}
+
+// TailRecords yields the records of a never-ending input, e.g. the
+// audit pipe (/dev/auditpipe) or a trail which is still being written.
+// Unlike RecordGenerator, it does not stop at the end of the input but
+// waits for more data. The channel is closed once the context is
+// cancelled or after a (parsing or read) error was passed on, trailer
+// warnings are passed on like RecordGeneratorContext does. A read
+// blocked within the input only returns after new data arrived, so the
+// context is checked between reads.
+func TailRecords(ctx context.Context, input io.Reader) <-chan ParsingResult {
+	return generateRecords(ctx, &tailReader{ctx: ctx, reader: input}, tokenDecoder{})
+}
@@ -0,0 +1,108 @@
+// test reading BSM records from never-ending streams
+package bsm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTailRecords_pipe(t *testing.T) {
+	reader, writer := io.Pipe()
+	go func() {
+		// deliver the records in small chunks with delays
+		for i := 0; i < len(rootLogin); i += 16 {
+			end := i + 16
+			if end > len(rootLogin) {
+				end = len(rootLogin)
+			}
+			writer.Write(rootLogin[i:end])
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := TailRecords(ctx, reader)
+	for i := 0; i < 3; i++ {
+		res := <-results
+		if res.Error != nil {
+			t.Fatal(res.Error)
+		}
+	}
+	cancel()
+	writer.Close() // unblock the pending read
+	for res := range results {
+		t.Error("unexpected result after cancellation:", res)
+	}
+}
+
+func TestTailRecords_growingFile(t *testing.T) {
+	defer func(interval time.Duration) { tailPollInterval = interval }(tailPollInterval)
+	tailPollInterval = time.Millisecond
+
+	file, err := ioutil.TempFile("", "go-bsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	firstEnd := 0x61 // the first record of the fixture has 97 bytes
+	if _, err := file.Write(rootLogin[:firstEnd]); err != nil {
+		t.Fatal(err)
+	}
+
+	input, err := os.Open(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer input.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	results := TailRecords(ctx, input)
+	if res := <-results; res.Error != nil {
+		t.Fatal(res.Error)
+	}
+
+	// the end of the file is not the end of the records
+	if _, err := file.Write(rootLogin[firstEnd:]); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		res, ok := <-results
+		if !ok || res.Error != nil {
+			t.Fatal("expected another record, got", res.Error)
+		}
+	}
+	cancel()
+	if _, ok := <-results; ok {
+		t.Error("expected the channel to be closed after cancellation")
+	}
+}
+
+func TestTailRecords_trailerWarning(t *testing.T) {
+	defer func(strict bool) { StrictTrailer = strict }(StrictTrailer)
+	StrictTrailer = false
+
+	first := rootLogin[:0x61] // the first record of the fixture has 97 bytes
+	input := append(append([]byte{}, first...), rootLogin[0x61:]...)
+	input[len(first)-6] = 0xff // first byte of trailer magic
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reader, writer := io.Pipe()
+	go writer.Write(input)
+	results := TailRecords(ctx, reader)
+	if res := <-results; !errors.Is(res.Error, ErrTrailerWarning) {
+		t.Fatal("expected a trailer warning, got", res.Error)
+	}
+	// the stream goes on after a warning
+	for i := 0; i < 2; i++ {
+		if res, ok := <-results; !ok || res.Error != nil {
+			t.Fatal("expected another record, got", res.Error)
+		}
+	}
+}
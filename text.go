@@ -0,0 +1,91 @@
+// praudit(1) compatible plain text representation of BSM records
+package bsm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteText writes the given record in the default (comma-delimited,
+// one token per line) format of 'praudit -r' (i.e. numeric values are
+// not resolved to names). Tokens without a praudit counterpart are
+// written in their string representation.
+func WriteText(w io.Writer, rec BsmRecord) error {
+	buffer := &bytes.Buffer{}
+	if rec.Header != nil {
+		writeTextToken(buffer, rec.Header)
+	}
+	for _, token := range rec.Tokens {
+		writeTextToken(buffer, token)
+	}
+	if rec.Trailer.TokenID != 0 {
+		writeTextToken(buffer, rec.Trailer)
+	}
+	_, err := buffer.WriteTo(w)
+	return err
+}
+
+// writeTextToken writes a single token as comma-delimited line.
+func writeTextToken(buffer *bytes.Buffer, token Token) {
+	switch t := token.(type) {
+	case HeaderToken32bit:
+		writeTextLine(buffer, "header", t.RecordByteCount, t.VersionNumber, t.EventType, t.EventModifier, t.Seconds, t.NanoSeconds/1000000)
+	case HeaderToken64bit:
+		writeTextLine(buffer, "header", t.RecordByteCount, t.VersionNumber, t.EventType, t.EventModifier, t.Seconds, t.NanoSeconds/1000000)
+	case ExpandedHeaderToken32bit:
+		writeTextLine(buffer, "header_ex", t.RecordByteCount, t.VersionNumber, t.EventType, t.EventModifier, t.MachineAddress, t.Seconds, t.NanoSeconds/1000000)
+	case ExpandedHeaderToken64bit:
+		writeTextLine(buffer, "header_ex", t.RecordByteCount, t.VersionNumber, t.EventType, t.EventModifier, t.MachineAddress, t.Seconds, t.NanoSeconds/1000000)
+	case SubjectToken32bit:
+		writeTextLine(buffer, "subject", int32(t.AuditID), int32(t.EffectiveUserID), int32(t.EffectiveGroupID),
+			int32(t.RealUserID), int32(t.RealGroupID), t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress)
+	case SubjectToken64bit:
+		writeTextLine(buffer, "subject", int32(t.AuditID), int32(t.EffectiveUserID), int32(t.EffectiveGroupID),
+			int32(t.RealUserID), int32(t.RealGroupID), t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress)
+	case ExpandedSubjectToken32bit:
+		writeTextLine(buffer, "subject_ex", int32(t.AuditID), int32(t.EffectiveUserID), int32(t.EffectiveGroupID),
+			int32(t.RealUserID), int32(t.RealGroupID), t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress)
+	case ExpandedSubjectToken64bit:
+		writeTextLine(buffer, "subject_ex", int32(t.AuditID), int32(t.EffectiveUserID), int32(t.EffectiveGroupID),
+			int32(t.RealUserID), int32(t.RealGroupID), t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress)
+	case ArgToken32bit:
+		writeTextLine(buffer, "argument", t.ArgumentID, "0x"+strconv.FormatUint(uint64(t.ArgumentValue), 16), t.Text)
+	case AttributeToken32bit:
+		writeTextLine(buffer, "attribute", strconv.FormatUint(uint64(t.FileAccessMode), 8), t.OwnerUserID, t.OwnerGroupID,
+			t.FileSystemID, t.FileSystemNodeID, t.Device)
+	case ExitToken:
+		writeTextLine(buffer, "exit", t.Status, t.ReturnValue)
+	case PathToken:
+		writeTextLine(buffer, "path", t.Path)
+	case TextToken:
+		writeTextLine(buffer, "text", t.Text)
+	case ReturnToken32bit: // signed like praudit, e.g. -1 on failure
+		writeTextLine(buffer, "return", t.ErrorNumber, int32(t.ReturnValue))
+	case ReturnToken64bit:
+		writeTextLine(buffer, "return", t.ErrorNumber, int64(t.ReturnValue))
+	case ZonenameToken:
+		writeTextLine(buffer, "zone", t.Zonename)
+	case TrailerToken:
+		writeTextLine(buffer, "trailer", t.RecordByteCount)
+	case RawToken:
+		// hex dump of tokens which can't be decoded (lenient mode)
+		writeTextLine(buffer, "raw", fmt.Sprintf("0x%02x", t.TokenID), len(t.Raw))
+		buffer.WriteString(hex.Dump(t.Raw))
+	default:
+		buffer.WriteString(fmt.Sprint(token) + "\n")
+	}
+}
+
+// writeTextLine writes the token name and the given fields as
+// comma-delimited line.
+func writeTextLine(buffer *bytes.Buffer, name string, fields ...interface{}) {
+	values := []string{name}
+	for _, field := range fields {
+		values = append(values, fmt.Sprint(field))
+	}
+	buffer.WriteString(strings.Join(values, ",") + "\n")
+}
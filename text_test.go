@@ -0,0 +1,92 @@
+// test praudit(1) compatible plain text representation of BSM records
+package bsm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	data := []byte{
+		0x14,                   // --- 32bit header token ID
+		0x00, 0x00, 0x00, 0x38, // 56 bytes in record
+		0x0b,       // version number
+		0xaf, 0xc8, // event type
+		0x00, 0x00, // event modifier / sub-type
+		0x5a, 0x9a, 0xc2, 0xe6, // timestamp seconds
+		0x00, 0x00, 0x03, 0x01, // timestamp nanoseconds
+		0x28,       // --- text token ID
+		0x00, 0x16, // string length (22 bytes)
+		0x61, 0x75, 0x64, 0x69, // actual string
+		0x74, 0x64, 0x3a, 0x3a,
+		0x41, 0x75, 0x64, 0x69,
+		0x74, 0x20, 0x73, 0x74,
+		0x61, 0x72, 0x74, 0x75,
+		0x70, 0x00,
+		0x27,                   // --- return token ID
+		0x00,                   // error number
+		0x00, 0x00, 0x00, 0x00, // return value
+		0x13,       // --- trailer token ID
+		0xb1, 0x05, // trailer magic
+		0x00, 0x00, 0x00, 0x38, // record byte count (56 bytes)
+	}
+	rec, err := ReadBsmRecord(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := &bytes.Buffer{}
+	if err := WriteText(output, rec); err != nil {
+		t.Fatal(err)
+	}
+	expected := "header,56,11,45000,0,1520091878,0\n" +
+		"text,auditd::Audit startup\n" +
+		"return,0,0\n" +
+		"trailer,56\n"
+	if output.String() != expected {
+		t.Error("unexpected text output:\n" + output.String())
+	}
+}
+
+func TestWriteText_subject(t *testing.T) {
+	rec := BsmRecord{Tokens: []Token{SubjectToken32bit{
+		TokenID:                0x24,
+		AuditID:                0xffffffff,
+		ProcessID:              754,
+		SessionID:              754,
+		TerminalMachineAddress: []byte{0, 0, 0, 0},
+	}}}
+	output := &bytes.Buffer{}
+	if err := WriteText(output, rec); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "subject,-1,0,0,0,0,754,754,0,0.0.0.0\n" {
+		t.Error("unexpected text output: " + output.String())
+	}
+}
+
+func TestWriteText_failure(t *testing.T) {
+	rec := BsmRecord{Tokens: []Token{
+		ReturnToken32bit{TokenID: 0x27, ErrorNumber: 13, ReturnValue: 0xffffffff},
+		ReturnToken64bit{TokenID: 0x72, ErrorNumber: 13, ReturnValue: 0xffffffffffffffff},
+	}}
+	output := &bytes.Buffer{}
+	if err := WriteText(output, rec); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "return,13,-1\nreturn,13,-1\n" {
+		t.Error("unexpected text output: " + output.String())
+	}
+}
+
+func TestWriteText_raw(t *testing.T) {
+	rec := BsmRecord{Tokens: []Token{RawToken{TokenID: 0x22, Raw: []byte{0x22, 0x01, 0x00, 0x00, 0x00, 0x2a}}}}
+	output := &bytes.Buffer{}
+	if err := WriteText(output, rec); err != nil {
+		t.Fatal(err)
+	}
+	expected := "raw,0x22,6\n" +
+		"00000000  22 01 00 00 00 2a                                 |\"....*|\n"
+	if output.String() != expected {
+		t.Errorf("unexpected output:\n%s", output.String())
+	}
+}
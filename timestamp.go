@@ -0,0 +1,90 @@
+// Time stamps of BSM tokens
+package bsm
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Timestamp returns the time stamp of the header token (UTC).
+func (t HeaderToken32bit) Timestamp() time.Time {
+	return time.Unix(int64(t.Seconds), int64(t.NanoSeconds)).UTC()
+}
+
+// Timestamp returns the time stamp of the header token (UTC).
+func (t HeaderToken64bit) Timestamp() time.Time {
+	return time.Unix(int64(t.Seconds), int64(t.NanoSeconds)).UTC()
+}
+
+// Timestamp returns the time stamp of the expanded header token (UTC).
+func (t ExpandedHeaderToken32bit) Timestamp() time.Time {
+	return time.Unix(int64(t.Seconds), int64(t.NanoSeconds)).UTC()
+}
+
+// Timestamp returns the time stamp of the expanded header token (UTC).
+func (t ExpandedHeaderToken64bit) Timestamp() time.Time {
+	return time.Unix(int64(t.Seconds), int64(t.NanoSeconds)).UTC()
+}
+
+// splitTimestamp splits the given time into seconds and nanoseconds since
+// the epoch. Times before the epoch and (for 32 bit time stamps) after
+// 2106 can't be stored in header tokens.
+func splitTimestamp(timestamp time.Time, maxSeconds uint64) (uint64, uint64, error) {
+	seconds := timestamp.Unix()
+	if seconds < 0 {
+		return 0, 0, errors.New("time stamp before 1970 can't be stored")
+	}
+	if uint64(seconds) > maxSeconds {
+		return 0, 0, errors.New("time stamp overflows seconds of header token")
+	}
+	return uint64(seconds), uint64(timestamp.Nanosecond()), nil
+}
+
+// SetTimestamp sets the time stamp of the header token. It fails for
+// times which don't fit into 32 bit seconds (after 2106).
+func (t *HeaderToken32bit) SetTimestamp(timestamp time.Time) error {
+	seconds, nanoseconds, err := splitTimestamp(timestamp, math.MaxUint32)
+	if err != nil {
+		return err
+	}
+	t.Seconds, t.NanoSeconds = uint32(seconds), uint32(nanoseconds)
+	return nil
+}
+
+// SetTimestamp sets the time stamp of the header token.
+func (t *HeaderToken64bit) SetTimestamp(timestamp time.Time) error {
+	seconds, nanoseconds, err := splitTimestamp(timestamp, math.MaxUint64)
+	if err != nil {
+		return err
+	}
+	t.Seconds, t.NanoSeconds = seconds, nanoseconds
+	return nil
+}
+
+// SetTimestamp sets the time stamp of the expanded header token. It
+// fails for times which don't fit into 32 bit seconds (after 2106).
+func (t *ExpandedHeaderToken32bit) SetTimestamp(timestamp time.Time) error {
+	seconds, nanoseconds, err := splitTimestamp(timestamp, math.MaxUint32)
+	if err != nil {
+		return err
+	}
+	t.Seconds, t.NanoSeconds = uint32(seconds), uint32(nanoseconds)
+	return nil
+}
+
+// SetTimestamp sets the time stamp of the expanded header token.
+func (t *ExpandedHeaderToken64bit) SetTimestamp(timestamp time.Time) error {
+	seconds, nanoseconds, err := splitTimestamp(timestamp, math.MaxUint64)
+	if err != nil {
+		return err
+	}
+	t.Seconds, t.NanoSeconds = seconds, nanoseconds
+	return nil
+}
+
+// Timestamp returns the time stamp of the file token (UTC). Unlike
+// header tokens, file tokens store microseconds.
+func (t FileToken) Timestamp() time.Time {
+	return time.Unix(int64(t.Seconds), int64(t.Microseconds)*1000).UTC()
+}
@@ -0,0 +1,91 @@
+// test time stamps of BSM tokens
+package bsm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeaderToken_Timestamp(t *testing.T) {
+	expected := time.Date(2018, time.March, 3, 15, 44, 38, 769, time.UTC)
+
+	header32 := HeaderToken32bit{Seconds: 1520091878, NanoSeconds: 769}
+	if !header32.Timestamp().Equal(expected) {
+		t.Error("unexpected time stamp of 32 bit header: " + header32.Timestamp().String())
+	}
+	if header32.Timestamp().Location() != time.UTC {
+		t.Error("time stamp is not in UTC")
+	}
+
+	header64 := HeaderToken64bit{Seconds: 1520091878, NanoSeconds: 769}
+	if !header64.Timestamp().Equal(expected) {
+		t.Error("unexpected time stamp of 64 bit header: " + header64.Timestamp().String())
+	}
+
+	exHeader32 := ExpandedHeaderToken32bit{Seconds: 1520091878, NanoSeconds: 769}
+	if !exHeader32.Timestamp().Equal(expected) {
+		t.Error("unexpected time stamp of 32 bit expanded header: " + exHeader32.Timestamp().String())
+	}
+
+	exHeader64 := ExpandedHeaderToken64bit{Seconds: 1520091878, NanoSeconds: 769}
+	if !exHeader64.Timestamp().Equal(expected) {
+		t.Error("unexpected time stamp of 64 bit expanded header: " + exHeader64.Timestamp().String())
+	}
+
+	epoch := HeaderToken32bit{}
+	if !epoch.Timestamp().Equal(time.Unix(0, 0)) {
+		t.Error("unexpected time stamp for epoch: " + epoch.Timestamp().String())
+	}
+}
+
+func TestFileToken_Timestamp(t *testing.T) {
+	token := FileToken{Seconds: 1520091878, Microseconds: 500}
+	expected := time.Date(2018, time.March, 3, 15, 44, 38, 500000, time.UTC)
+	if !token.Timestamp().Equal(expected) {
+		t.Error("unexpected time stamp of file token: " + token.Timestamp().String())
+	}
+
+	// 250 ms written as microseconds (0x0003d090)
+	data := []byte{0x11, 0x5a, 0x9a, 0xc2, 0xe6, 0x00, 0x03, 0xd0, 0x90, 0x00, 0x00, 0x00}
+	token, err := ParseFileToken(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Timestamp().Nanosecond() != 250000000 {
+		t.Error("unexpected nanoseconds of file token:", token.Timestamp().Nanosecond())
+	}
+}
+
+func TestHeaderToken_SetTimestamp(t *testing.T) {
+	expected := time.Date(2018, time.March, 3, 15, 44, 38, 769, time.UTC)
+
+	header32 := HeaderToken32bit{}
+	if err := header32.SetTimestamp(expected.In(time.FixedZone("CET", 3600))); err != nil {
+		t.Fatal(err)
+	}
+	if header32.Seconds != 1520091878 || header32.NanoSeconds != 769 || !header32.Timestamp().Equal(expected) {
+		t.Error("unexpected time stamp of 32 bit header: " + header32.Timestamp().String())
+	}
+	exHeader32 := ExpandedHeaderToken32bit{}
+	if err := exHeader32.SetTimestamp(expected); err != nil || !exHeader32.Timestamp().Equal(expected) {
+		t.Error("unexpected time stamp of 32 bit expanded header: " + exHeader32.Timestamp().String())
+	}
+
+	// 32 bit seconds overflow after 2106
+	future := time.Date(2107, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if err := header32.SetTimestamp(future); err == nil {
+		t.Error("expected an error on overflow of 32 bit seconds")
+	}
+	if err := header32.SetTimestamp(time.Unix(-1, 0)); err == nil {
+		t.Error("expected an error on time stamp before 1970")
+	}
+
+	header64 := HeaderToken64bit{}
+	if err := header64.SetTimestamp(future); err != nil || !header64.Timestamp().Equal(future) {
+		t.Error("unexpected time stamp of 64 bit header: " + header64.Timestamp().String())
+	}
+	exHeader64 := ExpandedHeaderToken64bit{}
+	if err := exHeader64.SetTimestamp(future); err != nil || !exHeader64.Timestamp().Equal(future) {
+		t.Error("unexpected time stamp of 64 bit expanded header: " + exHeader64.Timestamp().String())
+	}
+}
@@ -0,0 +1,97 @@
+// Structural validation of BSM records
+package bsm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ValidationErrors holds all violations found by BsmRecord.Validate.
+type ValidationErrors []error
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate checks the structural invariants of the record: it starts
+// with a header token, ends with a trailer token, contains exactly one
+// return token, at most one subject and exit token, and the record byte
+// counts of header and trailer match the size of the tokens. All
+// violations are returned as ValidationErrors (nil for a valid record).
+func (r BsmRecord) Validate() error {
+	violations := ValidationErrors{}
+
+	headerCount, ok := uint32(0), false
+	switch h := r.Header.(type) {
+	case HeaderToken32bit:
+		headerCount, ok = h.RecordByteCount, true
+	case HeaderToken64bit:
+		headerCount, ok = h.RecordByteCount, true
+	case ExpandedHeaderToken32bit:
+		headerCount, ok = h.RecordByteCount, true
+	case ExpandedHeaderToken64bit:
+		headerCount, ok = h.RecordByteCount, true
+	}
+	if !ok {
+		violations = append(violations, errors.New("record does not start with a header token"))
+	}
+	if r.Trailer.TokenID != 0x13 {
+		violations = append(violations, errors.New("record does not end with a trailer token"))
+	}
+
+	subjects, returns, exits := 0, 0, 0
+	size, sized := 0, false
+	if sizer, ok := r.Header.(Sizer); ok {
+		size, sized = sizer.Size(), true
+	}
+	for _, token := range r.Tokens {
+		switch token.(type) {
+		case HeaderToken32bit, HeaderToken64bit, ExpandedHeaderToken32bit, ExpandedHeaderToken64bit:
+			violations = append(violations, errors.New("header token within record"))
+		case TrailerToken:
+			violations = append(violations, errors.New("trailer token within record"))
+		case SubjectToken32bit, SubjectToken64bit, ExpandedSubjectToken32bit, ExpandedSubjectToken64bit:
+			subjects += 1
+		case ReturnToken32bit, ReturnToken64bit:
+			returns += 1
+		case ExitToken:
+			exits += 1
+		}
+		if sizer, ok := token.(Sizer); ok {
+			size += sizer.Size()
+		} else {
+			sized = false
+		}
+	}
+	if subjects > 1 {
+		violations = append(violations, fmt.Errorf("%d subject tokens in record", subjects))
+	}
+	if returns != 1 {
+		violations = append(violations, fmt.Errorf("%d return tokens in record (expected 1)", returns))
+	}
+	if exits > 1 {
+		violations = append(violations, fmt.Errorf("%d exit tokens in record", exits))
+	}
+
+	if ok && r.Trailer.RecordByteCount != headerCount {
+		violations = append(violations, fmt.Errorf("record byte count of trailer (%d) does not match header (%d)",
+			r.Trailer.RecordByteCount, headerCount))
+	}
+	if sized {
+		size += r.Trailer.Size()
+		if uint64(r.Trailer.RecordByteCount) != uint64(size) {
+			violations = append(violations, fmt.Errorf("record byte count of trailer (%d) does not match size of tokens (%d)",
+				r.Trailer.RecordByteCount, size))
+		}
+	}
+
+	if 0 == len(violations) {
+		return nil
+	}
+	return violations
+}
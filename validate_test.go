@@ -0,0 +1,66 @@
+// test structural validation of BSM records
+package bsm
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestBsmRecord_Validate(t *testing.T) {
+	records, err := ParseAll(bytes.NewBuffer(rootLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, record := range records {
+		if err := record.Validate(); err != nil {
+			t.Errorf("record %d: %v", i, err)
+		}
+	}
+
+	// IPv4-mapped terminal addresses keep their parsed size
+	subject := ExpandedSubjectToken32bit{TerminalAddressLength: 16, TerminalMachineAddress: net.ParseIP("::ffff:10.0.0.1")}
+	data, err := BuildRecord(HeaderToken32bit{VersionNumber: 11}, subject, ReturnToken32bit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := ReadBsmRecord(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := record.Validate(); err != nil {
+		t.Error("unexpected violation:", err)
+	}
+	output := &bytes.Buffer{}
+	if _, err := record.WriteTo(output); err != nil || !bytes.Equal(output.Bytes(), data) {
+		t.Errorf("round trip failed: % x (%v)", output.Bytes(), err)
+	}
+
+	// record missing its return token
+	data, err = BuildRecord(HeaderToken32bit{VersionNumber: 11}, TextToken{Text: "no return"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err = ReadBsmRecord(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = record.Validate()
+	var violations ValidationErrors
+	if !errors.As(err, &violations) || len(violations) != 1 {
+		t.Fatal("expected a single violation, got", err)
+	}
+	if !strings.Contains(err.Error(), "0 return tokens") {
+		t.Error("unexpected violation:", err)
+	}
+
+	// all violations are reported
+	record.Header = nil
+	record.Tokens = append(record.Tokens, ExitToken{TokenID: 0x52}, ExitToken{TokenID: 0x52})
+	record.Trailer = TrailerToken{}
+	if err = record.Validate(); !errors.As(err, &violations) || len(violations) != 4 {
+		t.Error("expected 4 violations, got", err)
+	}
+}
@@ -0,0 +1,200 @@
+// Visiting the tokens of BSM records
+package bsm
+
+// TokenVisitor is called for each token of a record by BsmRecord.Walk.
+// Every token type of this package has a dedicated method; only raw
+// tokens (see RawToken) and token types defined outside of this package
+// are passed to VisitOther. Embed BaseVisitor to implement only the
+// methods of interest.
+type TokenVisitor interface {
+	VisitHeader32(HeaderToken32bit)
+	VisitHeader64(HeaderToken64bit)
+	VisitExpandedHeader32(ExpandedHeaderToken32bit)
+	VisitExpandedHeader64(ExpandedHeaderToken64bit)
+	VisitSubject32(SubjectToken32bit)
+	VisitSubject64(SubjectToken64bit)
+	VisitExpandedSubject32(ExpandedSubjectToken32bit)
+	VisitExpandedSubject64(ExpandedSubjectToken64bit)
+	VisitProcess32(ProcessToken32bit)
+	VisitProcess64(ProcessToken64bit)
+	VisitExpandedProcess32(ExpandedProcessToken32bit)
+	VisitExpandedProcess64(ExpandedProcessToken64bit)
+	VisitArg32(ArgToken32bit)
+	VisitArg64(ArgToken64bit)
+	VisitArbitraryData(ArbitraryDataToken)
+	VisitAttribute32(AttributeToken32bit)
+	VisitAttribute64(AttributeToken64bit)
+	VisitExecArgs(ExecArgsToken)
+	VisitExecEnv(ExecEnvToken)
+	VisitExit(ExitToken)
+	VisitFile(FileToken)
+	VisitGroups(GroupsToken)
+	VisitInAddr(InAddrToken)
+	VisitExpandedInAddr(ExpandedInAddrToken)
+	VisitIp(IpToken)
+	VisitIPort(IPortToken)
+	VisitPath(PathToken)
+	VisitPathAttr(PathAttrToken)
+	VisitReturn(ReturnToken32bit)
+	VisitReturn64(ReturnToken64bit)
+	VisitSeq(SeqToken)
+	VisitSocket(SocketToken)
+	VisitSocketInet32(SocketInet32Token)
+	VisitSocketInet128(SocketInet128Token)
+	VisitSocketUnix(SocketUnixToken)
+	VisitExpandedSocket(ExpandedSocketToken)
+	VisitSystemVIpc(SystemVIpcToken)
+	VisitSystemVIpcPermission(SystemVIpcPermissionToken)
+	VisitText(TextToken)
+	VisitZonename(ZonenameToken)
+	VisitTrailer(TrailerToken)
+	VisitOther(Token)
+}
+
+// BaseVisitor implements all methods of TokenVisitor as no-ops.
+type BaseVisitor struct{}
+
+func (BaseVisitor) VisitHeader32(HeaderToken32bit)                      {}
+func (BaseVisitor) VisitHeader64(HeaderToken64bit)                      {}
+func (BaseVisitor) VisitExpandedHeader32(ExpandedHeaderToken32bit)      {}
+func (BaseVisitor) VisitExpandedHeader64(ExpandedHeaderToken64bit)      {}
+func (BaseVisitor) VisitSubject32(SubjectToken32bit)                    {}
+func (BaseVisitor) VisitSubject64(SubjectToken64bit)                    {}
+func (BaseVisitor) VisitExpandedSubject32(ExpandedSubjectToken32bit)    {}
+func (BaseVisitor) VisitExpandedSubject64(ExpandedSubjectToken64bit)    {}
+func (BaseVisitor) VisitProcess32(ProcessToken32bit)                    {}
+func (BaseVisitor) VisitProcess64(ProcessToken64bit)                    {}
+func (BaseVisitor) VisitExpandedProcess32(ExpandedProcessToken32bit)    {}
+func (BaseVisitor) VisitExpandedProcess64(ExpandedProcessToken64bit)    {}
+func (BaseVisitor) VisitArg32(ArgToken32bit)                            {}
+func (BaseVisitor) VisitArg64(ArgToken64bit)                            {}
+func (BaseVisitor) VisitArbitraryData(ArbitraryDataToken)               {}
+func (BaseVisitor) VisitAttribute32(AttributeToken32bit)                {}
+func (BaseVisitor) VisitAttribute64(AttributeToken64bit)                {}
+func (BaseVisitor) VisitExecArgs(ExecArgsToken)                         {}
+func (BaseVisitor) VisitExecEnv(ExecEnvToken)                           {}
+func (BaseVisitor) VisitExit(ExitToken)                                 {}
+func (BaseVisitor) VisitFile(FileToken)                                 {}
+func (BaseVisitor) VisitGroups(GroupsToken)                             {}
+func (BaseVisitor) VisitInAddr(InAddrToken)                             {}
+func (BaseVisitor) VisitExpandedInAddr(ExpandedInAddrToken)             {}
+func (BaseVisitor) VisitIp(IpToken)                                     {}
+func (BaseVisitor) VisitIPort(IPortToken)                               {}
+func (BaseVisitor) VisitPath(PathToken)                                 {}
+func (BaseVisitor) VisitPathAttr(PathAttrToken)                         {}
+func (BaseVisitor) VisitReturn(ReturnToken32bit)                        {}
+func (BaseVisitor) VisitReturn64(ReturnToken64bit)                      {}
+func (BaseVisitor) VisitSeq(SeqToken)                                   {}
+func (BaseVisitor) VisitSocket(SocketToken)                             {}
+func (BaseVisitor) VisitSocketInet32(SocketInet32Token)                 {}
+func (BaseVisitor) VisitSocketInet128(SocketInet128Token)               {}
+func (BaseVisitor) VisitSocketUnix(SocketUnixToken)                     {}
+func (BaseVisitor) VisitExpandedSocket(ExpandedSocketToken)             {}
+func (BaseVisitor) VisitSystemVIpc(SystemVIpcToken)                     {}
+func (BaseVisitor) VisitSystemVIpcPermission(SystemVIpcPermissionToken) {}
+func (BaseVisitor) VisitText(TextToken)                                 {}
+func (BaseVisitor) VisitZonename(ZonenameToken)                         {}
+func (BaseVisitor) VisitTrailer(TrailerToken)                           {}
+func (BaseVisitor) VisitOther(Token)                                    {}
+
+// Walk calls the matching method of the visitor for the header, each
+// token and the trailer of the record (in this order).
+func (r BsmRecord) Walk(v TokenVisitor) {
+	if r.Header != nil {
+		visitToken(v, r.Header)
+	}
+	for _, token := range r.Tokens {
+		visitToken(v, token)
+	}
+	v.VisitTrailer(r.Trailer)
+}
+
+// visitToken dispatches a single token to the visitor.
+func visitToken(v TokenVisitor, token Token) {
+	switch t := token.(type) {
+	case HeaderToken32bit:
+		v.VisitHeader32(t)
+	case HeaderToken64bit:
+		v.VisitHeader64(t)
+	case ExpandedHeaderToken32bit:
+		v.VisitExpandedHeader32(t)
+	case ExpandedHeaderToken64bit:
+		v.VisitExpandedHeader64(t)
+	case SubjectToken32bit:
+		v.VisitSubject32(t)
+	case SubjectToken64bit:
+		v.VisitSubject64(t)
+	case ExpandedSubjectToken32bit:
+		v.VisitExpandedSubject32(t)
+	case ExpandedSubjectToken64bit:
+		v.VisitExpandedSubject64(t)
+	case ProcessToken32bit:
+		v.VisitProcess32(t)
+	case ProcessToken64bit:
+		v.VisitProcess64(t)
+	case ExpandedProcessToken32bit:
+		v.VisitExpandedProcess32(t)
+	case ExpandedProcessToken64bit:
+		v.VisitExpandedProcess64(t)
+	case ArgToken32bit:
+		v.VisitArg32(t)
+	case ArgToken64bit:
+		v.VisitArg64(t)
+	case ArbitraryDataToken:
+		v.VisitArbitraryData(t)
+	case AttributeToken32bit:
+		v.VisitAttribute32(t)
+	case AttributeToken64bit:
+		v.VisitAttribute64(t)
+	case ExecArgsToken:
+		v.VisitExecArgs(t)
+	case ExecEnvToken:
+		v.VisitExecEnv(t)
+	case ExitToken:
+		v.VisitExit(t)
+	case FileToken:
+		v.VisitFile(t)
+	case GroupsToken:
+		v.VisitGroups(t)
+	case InAddrToken:
+		v.VisitInAddr(t)
+	case ExpandedInAddrToken:
+		v.VisitExpandedInAddr(t)
+	case IpToken:
+		v.VisitIp(t)
+	case IPortToken:
+		v.VisitIPort(t)
+	case PathToken:
+		v.VisitPath(t)
+	case PathAttrToken:
+		v.VisitPathAttr(t)
+	case ReturnToken32bit:
+		v.VisitReturn(t)
+	case ReturnToken64bit:
+		v.VisitReturn64(t)
+	case SeqToken:
+		v.VisitSeq(t)
+	case SocketToken:
+		v.VisitSocket(t)
+	case SocketInet32Token:
+		v.VisitSocketInet32(t)
+	case SocketInet128Token:
+		v.VisitSocketInet128(t)
+	case SocketUnixToken:
+		v.VisitSocketUnix(t)
+	case ExpandedSocketToken:
+		v.VisitExpandedSocket(t)
+	case SystemVIpcToken:
+		v.VisitSystemVIpc(t)
+	case SystemVIpcPermissionToken:
+		v.VisitSystemVIpcPermission(t)
+	case TextToken:
+		v.VisitText(t)
+	case ZonenameToken:
+		v.VisitZonename(t)
+	case TrailerToken:
+		v.VisitTrailer(t)
+	default:
+		v.VisitOther(token)
+	}
+}
@@ -0,0 +1,88 @@
+// test visiting the tokens of BSM records
+package bsm
+
+import (
+	"bytes"
+	"testing"
+)
+
+// textCounter counts text tokens and remembers their texts.
+type textCounter struct {
+	BaseVisitor
+	texts    []string
+	trailers int
+}
+
+func (c *textCounter) VisitText(t TextToken) {
+	c.texts = append(c.texts, t.Text)
+}
+
+func (c *textCounter) VisitTrailer(TrailerToken) {
+	c.trailers += 1
+}
+
+func TestBsmRecord_Walk(t *testing.T) {
+	records, err := ParseAll(bytes.NewBuffer(rootLogin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := &textCounter{}
+	records[1].Walk(counter) // root login
+	if len(counter.texts) != 1 || counter.texts[0] != "successful login root" {
+		t.Error("unexpected text tokens:", counter.texts)
+	}
+	if counter.trailers != 1 {
+		t.Error("expected the trailer to be visited")
+	}
+
+	for _, rec := range records {
+		rec.Walk(counter)
+	}
+	if len(counter.texts) != 4 || counter.trailers != 4 {
+		t.Error("unexpected number of visited tokens:", len(counter.texts), counter.trailers)
+	}
+}
+
+// subjectCounter counts subject tokens of all widths and unhandled tokens.
+type subjectCounter struct {
+	BaseVisitor
+	subjects int
+	others   int
+}
+
+func (c *subjectCounter) VisitSubject32(SubjectToken32bit)                 { c.subjects += 1 }
+func (c *subjectCounter) VisitSubject64(SubjectToken64bit)                 { c.subjects += 1 }
+func (c *subjectCounter) VisitExpandedSubject32(ExpandedSubjectToken32bit) { c.subjects += 1 }
+func (c *subjectCounter) VisitExpandedSubject64(ExpandedSubjectToken64bit) { c.subjects += 1 }
+func (c *subjectCounter) VisitOther(Token)                                 { c.others += 1 }
+
+func TestBsmRecord_Walk_allTokenTypes(t *testing.T) {
+	rec := BsmRecord{
+		Header: HeaderToken64bit{},
+		Tokens: []Token{
+			SubjectToken32bit{}, SubjectToken64bit{},
+			ExpandedSubjectToken32bit{}, ExpandedSubjectToken64bit{},
+			ProcessToken32bit{}, ProcessToken64bit{},
+			ExpandedProcessToken32bit{}, ExpandedProcessToken64bit{},
+			ArgToken32bit{}, ArgToken64bit{}, ArbitraryDataToken{},
+			AttributeToken32bit{}, AttributeToken64bit{},
+			ExecArgsToken{}, ExecEnvToken{}, ExitToken{}, FileToken{},
+			GroupsToken{}, InAddrToken{}, ExpandedInAddrToken{}, IpToken{},
+			IPortToken{}, PathToken{}, PathAttrToken{},
+			ReturnToken32bit{}, ReturnToken64bit{}, SeqToken{},
+			SocketToken{}, SocketInet32Token{}, SocketInet128Token{},
+			SocketUnixToken{}, ExpandedSocketToken{},
+			SystemVIpcToken{}, SystemVIpcPermissionToken{},
+			TextToken{}, ZonenameToken{},
+			RawToken{},
+		},
+	}
+	counter := &subjectCounter{}
+	rec.Walk(counter)
+	if counter.subjects != 4 {
+		t.Error("expected 4 subject tokens, got", counter.subjects)
+	}
+	if counter.others != 1 { // only the raw token
+		t.Error("expected only the raw token to be unhandled, got", counter.others)
+	}
+}
@@ -0,0 +1,127 @@
+// praudit(1) compatible XML representation of BSM records
+package bsm
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WriteXML writes the given record as XML element in the format of
+// 'praudit -rx' (i.e. numeric values are not resolved to names). Header,
+// subject, text, return and trailer tokens use the tag and attribute
+// names of praudit, all other tokens are written as generic <token>
+// element containing their string representation.
+func WriteXML(w io.Writer, rec BsmRecord) error {
+	buffer := &bytes.Buffer{}
+	if rec.Header != nil {
+		writeXMLToken(buffer, rec.Header)
+	}
+	for _, token := range rec.Tokens {
+		writeXMLToken(buffer, token)
+	}
+	if rec.Trailer.TokenID != 0 {
+		writeXMLToken(buffer, rec.Trailer)
+	}
+	_, err := buffer.WriteTo(w)
+	return err
+}
+
+// writeXMLToken writes a single token as XML element (one per line).
+func writeXMLToken(buffer *bytes.Buffer, token Token) {
+	switch t := token.(type) {
+	case HeaderToken32bit:
+		writeXMLHeader(buffer, t.VersionNumber, t.EventType, t.EventModifier, nil, uint64(t.Seconds), uint64(t.NanoSeconds))
+	case HeaderToken64bit:
+		writeXMLHeader(buffer, t.VersionNumber, t.EventType, t.EventModifier, nil, t.Seconds, t.NanoSeconds)
+	case ExpandedHeaderToken32bit:
+		writeXMLHeader(buffer, t.VersionNumber, t.EventType, t.EventModifier, []string{"host", t.MachineAddress.String()}, uint64(t.Seconds), uint64(t.NanoSeconds))
+	case ExpandedHeaderToken64bit:
+		writeXMLHeader(buffer, t.VersionNumber, t.EventType, t.EventModifier, []string{"host", t.MachineAddress.String()}, t.Seconds, t.NanoSeconds)
+	case SubjectToken32bit:
+		writeXMLSubject(buffer, t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+			t.ProcessID, t.SessionID, uint64(t.TerminalPortID), t.TerminalMachineAddress.String())
+	case SubjectToken64bit:
+		writeXMLSubject(buffer, t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+			t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress.String())
+	case ExpandedSubjectToken32bit:
+		writeXMLSubject(buffer, t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+			t.ProcessID, t.SessionID, uint64(t.TerminalPortID), t.TerminalMachineAddress.String())
+	case ExpandedSubjectToken64bit:
+		writeXMLSubject(buffer, t.AuditID, t.EffectiveUserID, t.EffectiveGroupID, t.RealUserID, t.RealGroupID,
+			t.ProcessID, t.SessionID, t.TerminalPortID, t.TerminalMachineAddress.String())
+	case TextToken:
+		writeXMLText(buffer, "text", t.Text)
+	case ReturnToken32bit:
+		writeXMLElement(buffer, "return",
+			"errval", strconv.Itoa(int(t.ErrorNumber)),
+			"retval", strconv.Itoa(int(int32(t.ReturnValue)))) // signed like praudit
+	case ReturnToken64bit:
+		writeXMLElement(buffer, "return",
+			"errval", strconv.Itoa(int(t.ErrorNumber)),
+			"retval", strconv.FormatInt(int64(t.ReturnValue), 10))
+	case TrailerToken:
+		buffer.WriteString("</record>\n")
+	default:
+		buffer.WriteString("<token type=\"" + TokenTypeName(token.Type()) + "\">")
+		xml.EscapeText(buffer, []byte(fmt.Sprint(token)))
+		buffer.WriteString("</token>\n")
+	}
+}
+
+// writeXMLHeader writes the opening <record> element. praudit keeps
+// the element open until the trailer is found.
+func writeXMLHeader(buffer *bytes.Buffer, version byte, event, modifier uint16, host []string, seconds, nanoseconds uint64) {
+	attributes := []string{
+		"version", strconv.Itoa(int(version)),
+		"event", strconv.Itoa(int(event)),
+		"modifier", strconv.Itoa(int(modifier)),
+	}
+	attributes = append(attributes, host...)
+	attributes = append(attributes,
+		"time", strconv.FormatUint(seconds, 10),
+		"msec", strconv.FormatUint(nanoseconds/1000000, 10))
+	buffer.WriteString("<record")
+	writeXMLAttributes(buffer, attributes)
+	buffer.WriteString(" >\n")
+}
+
+// writeXMLSubject writes a <subject> element. User and group IDs are
+// signed, just like praudit does (e.g. audit-uid="-1" if unset).
+func writeXMLSubject(buffer *bytes.Buffer, auid, euid, egid, ruid, rgid, pid, sid uint32, port uint64, machine string) {
+	writeXMLElement(buffer, "subject",
+		"audit-uid", strconv.Itoa(int(int32(auid))),
+		"uid", strconv.Itoa(int(int32(euid))),
+		"gid", strconv.Itoa(int(int32(egid))),
+		"ruid", strconv.Itoa(int(int32(ruid))),
+		"rgid", strconv.Itoa(int(int32(rgid))),
+		"pid", strconv.FormatUint(uint64(pid), 10),
+		"sid", strconv.FormatUint(uint64(sid), 10),
+		"tid", strconv.FormatUint(port, 10)+" "+machine)
+}
+
+// writeXMLText writes an element with the given (escaped) text as content.
+func writeXMLText(buffer *bytes.Buffer, tag, text string) {
+	buffer.WriteString("<" + tag + ">")
+	xml.EscapeText(buffer, []byte(text))
+	buffer.WriteString("</" + tag + ">\n")
+}
+
+// writeXMLElement writes an empty element with the given attributes
+// (given as name/value pairs).
+func writeXMLElement(buffer *bytes.Buffer, tag string, attributes ...string) {
+	buffer.WriteString("<" + tag)
+	writeXMLAttributes(buffer, attributes)
+	buffer.WriteString(" />\n")
+}
+
+// writeXMLAttributes writes the given name/value pairs as (escaped) attributes.
+func writeXMLAttributes(buffer *bytes.Buffer, attributes []string) {
+	for i := 0; i+1 < len(attributes); i += 2 {
+		buffer.WriteString(" " + attributes[i] + "=\"")
+		xml.EscapeText(buffer, []byte(attributes[i+1]))
+		buffer.WriteString("\"")
+	}
+}
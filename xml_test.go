@@ -0,0 +1,66 @@
+// test praudit(1) compatible XML representation of BSM records
+package bsm
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata/")
+
+func TestWriteXML(t *testing.T) {
+	input := bytes.NewBuffer(rootLogin)
+	output := &bytes.Buffer{}
+	for i := 0; i < 3; i++ {
+		rec, err := ReadBsmRecord(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteXML(output, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// failed login (return values are signed like in praudit)
+	failed, err := BuildRecord(
+		HeaderToken32bit{VersionNumber: 11, EventType: 32800, Seconds: 1520091720},
+		TextToken{Text: "failed login root"},
+		ReturnToken32bit{ErrorNumber: 1, ReturnValue: 0xffffffff},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := ReadBsmRecord(bytes.NewBuffer(failed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteXML(output, rec); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := "testdata/root_login.xml"
+	if *update {
+		if err := ioutil.WriteFile(golden, output.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(output.Bytes(), expected) {
+		t.Error("XML output differs from " + golden + ":\n" + output.String())
+	}
+}
+
+func TestWriteXML_escaping(t *testing.T) {
+	rec := BsmRecord{Tokens: []Token{TextToken{TokenID: 0x28, Text: "a<b & \"c\""}}}
+	output := &bytes.Buffer{}
+	if err := WriteXML(output, rec); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "<text>a&lt;b &amp; &#34;c&#34;</text>\n" {
+		t.Error("unexpected XML: " + output.String())
+	}
+}